@@ -0,0 +1,198 @@
+package rql
+
+import (
+	"reflect"
+	"time"
+)
+
+// unsatisfiable reports if the given expression can never be true. For example:
+//
+//	age > 10 AND age < 5
+//	admin = true AND admin <> true
+//	(age = 1 OR age = 2) AND age > 2
+//
+// The check is conservative, and it reports true only for trivial contradictions
+// between predicates of the same field in one conjunction. Strings are never compared,
+// because their ordering and equality depend on the database collation.
+func unsatisfiable(e *expr) bool {
+	switch {
+	case !e.group():
+		return false
+	case e.op == OR:
+		for _, c := range e.children {
+			if !unsatisfiable(c) {
+				return false
+			}
+		}
+		return len(e.children) > 0
+	}
+	rs := make(map[*field]*valueRange)
+	if conflict(e, rs) {
+		return true
+	}
+	for _, r := range rs {
+		if r.empty() {
+			return true
+		}
+	}
+	return false
+}
+
+// conflict collects the ranges of the predicates in the given conjunction (and its
+// nested conjunctions), and reports if one of its disjunctions is unsatisfiable.
+func conflict(e *expr, rs map[*field]*valueRange) bool {
+	for _, c := range e.children {
+		switch {
+		case c.field != nil:
+			r, ok := rs[c.field]
+			if !ok {
+				r = &valueRange{}
+				rs[c.field] = r
+			}
+			r.add(c)
+		case c.group() && c.op == AND:
+			if conflict(c, rs) {
+				return true
+			}
+		case unsatisfiable(c):
+			return true
+		}
+	}
+	return false
+}
+
+// valueRange describes the values that a field can hold in a conjunction of predicates.
+type valueRange struct {
+	lo, hi       interface{}
+	loInc, hiInc bool
+	// in is the set of allowed values, and it's used only if restricted is true.
+	in         []interface{}
+	restricted bool
+	// nin is the set of disallowed values.
+	nin []interface{}
+}
+
+// add narrows the range by the given predicate.
+func (r *valueRange) add(e *expr) {
+	switch e.op {
+	case GT, GTE:
+		if c, ok := compare(e.value, r.lo); r.lo == nil || ok && (c > 0 || c == 0 && e.op == GT) {
+			r.lo, r.loInc = e.value, e.op == GTE
+		}
+	case LT, LTE:
+		if c, ok := compare(e.value, r.hi); r.hi == nil || ok && (c < 0 || c == 0 && e.op == LT) {
+			r.hi, r.hiInc = e.value, e.op == LTE
+		}
+	case EQ, IN:
+		_, values := e.list()
+		if !r.restricted {
+			r.in, r.restricted = values, true
+			return
+		}
+		var in []interface{}
+		for _, v := range r.in {
+			if mayContain(values, v) {
+				in = append(in, v)
+			}
+		}
+		r.in = in
+	case NEQ, NIN:
+		_, values := e.list()
+		r.nin = append(r.nin, values...)
+	}
+}
+
+// empty reports if there is no value that satisfies the range.
+func (r *valueRange) empty() bool {
+	if r.lo != nil && r.hi != nil {
+		if c, ok := compare(r.lo, r.hi); ok && (c > 0 || c == 0 && !(r.loInc && r.hiInc)) {
+			return true
+		}
+	}
+	if !r.restricted {
+		return false
+	}
+	for _, v := range r.in {
+		if !contains(r.nin, v) && r.within(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// within reports if the given value is in the bounds of the range. Values
+// that can not be compared with the bounds are considered to be within them.
+func (r *valueRange) within(v interface{}) bool {
+	if c, ok := compare(v, r.lo); ok && (c < 0 || c == 0 && !r.loInc) {
+		return false
+	}
+	if c, ok := compare(v, r.hi); ok && (c > 0 || c == 0 && !r.hiInc) {
+		return false
+	}
+	return true
+}
+
+// contains reports if the given value is equal to one of the values in the list.
+func contains(vs []interface{}, v interface{}) bool {
+	for i := range vs {
+		if c, ok := compare(vs[i], v); ok && c == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// mayContain is like contains, but it also reports true if the given
+// value can not be compared with one of the values in the list.
+func mayContain(vs []interface{}, v interface{}) bool {
+	for i := range vs {
+		if c, ok := compare(vs[i], v); !ok || c == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares two filter values of the same type. It returns false if the
+// values are not comparable (e.g. different types, strings or nil values).
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case int:
+		if b, ok := b.(int); ok {
+			return cmp(a < b, a > b), true
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			return cmp(a < b, a > b), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			return cmp(!a && b, a && !b), true
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return cmp(a.Before(b), a.After(b)), true
+		}
+	case int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		// the integers of the other field widths (e.g. int8 or uint64).
+		va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+		if b == nil || va.Type() != vb.Type() {
+			return 0, false
+		}
+		if va.Kind() >= reflect.Uint {
+			return cmp(va.Uint() < vb.Uint(), va.Uint() > vb.Uint()), true
+		}
+		return cmp(va.Int() < vb.Int(), va.Int() > vb.Int()), true
+	}
+	return 0, false
+}
+
+func cmp(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
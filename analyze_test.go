@@ -0,0 +1,56 @@
+package rql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnsatisfiable(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age       int       `rql:"filter"`
+			Level     int8      `rql:"filter"`
+			Views     uint64    `rql:"filter"`
+			Name      string    `rql:"filter"`
+			Admin     bool      `rql:"filter"`
+			CreatedAt time.Time `rql:"filter"`
+		}),
+		DetectContradictions: true,
+		Log:                  t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		input []byte
+		want  bool
+	}{
+		{input: []byte(`{}`)},
+		{input: []byte(`{"filter": {"age": {"$gt": 5, "$lt": 10}}}`)},
+		{input: []byte(`{"filter": {"age": {"$gte": 5, "$lte": 5}}}`)},
+		{input: []byte(`{"filter": {"age": {"$gt": 10, "$lt": 5}}}`), want: true},
+		{input: []byte(`{"filter": {"age": {"$gt": 5, "$lt": 5}}}`), want: true},
+		{input: []byte(`{"filter": {"age": 1, "$and": [{"age": 2}]}}`), want: true},
+		{input: []byte(`{"filter": {"admin": true, "$and": [{"admin": {"$neq": true}}]}}`), want: true},
+		{input: []byte(`{"filter": {"age": {"$in": [1, 2], "$gt": 2}}}`), want: true},
+		{input: []byte(`{"filter": {"age": {"$in": [1, 2], "$nin": [1]}}}`)},
+		{input: []byte(`{"filter": {"$or": [{"age": {"$gt": 10, "$lt": 5}}, {"age": 1}]}}`)},
+		{input: []byte(`{"filter": {"$or": [{"age": {"$gt": 10, "$lt": 5}}, {"age": 1, "$and": [{"age": 2}]}]}}`), want: true},
+		{input: []byte(`{"filter": {"level": {"$gt": 10, "$lt": 5}}}`), want: true},
+		{input: []byte(`{"filter": {"views": {"$gte": 5, "$lte": 5}}}`)},
+		{input: []byte(`{"filter": {"views": {"$in": [1, 2], "$gt": 2}}}`), want: true},
+		{input: []byte(`{"filter": {"name": "a8m", "$and": [{"name": "A8M"}]}}`)},
+		{input: []byte(`{"filter": {"created_at": {"$gt": "2020-01-01T00:00:00Z", "$lt": "2019-01-01T00:00:00Z"}}}`), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.input), func(t *testing.T) {
+			out, err := p.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if out.Unsatisfiable != tt.want {
+				t.Fatalf("unsatisfiable: got %v, want %v", out.Unsatisfiable, tt.want)
+			}
+		})
+	}
+}
@@ -108,3 +108,44 @@ func BenchmarkSmallQuery(b *testing.B) {
 		}
 	}
 }
+
+func TestParseStateChunks(t *testing.T) {
+	inputs := []string{
+		`{"filter": {"name": "foo", "$or": [{"age": {"$gt": 20}}, {"age": {"$lt": 10}}]}}`,
+		`{"filter": {"address.name": "bar", "admin": true, "int": {"$in": [1, 2, 3]}}}`,
+	}
+	var canonical []string
+	var params []*Params
+	for i := 0; i < 3*chunkSize; i++ {
+		out, err := p.Parse([]byte(inputs[i%len(inputs)]))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		params = append(params, out)
+		canonical = append(canonical, out.Canonical())
+	}
+	// the filters of previous queries are not overridden by the nodes of the next ones.
+	for i, out := range params {
+		if c := out.Canonical(); c != canonical[i] || c != canonical[i%len(inputs)] {
+			t.Fatalf("params %d were changed:\n%s\nwant:\n%s", i, c, canonical[i%len(inputs)])
+		}
+	}
+}
+
+func BenchmarkParseInto(b *testing.B) {
+	var out Params
+	for i := 0; i < b.N; i++ {
+		err := p.ParseInto([]byte(`{
+		"filter": {
+			"address.name": "TLV",
+			"admin": true
+		},
+		"sort": ["-age", "name"],
+		"offset": 25,
+		"limit": 10
+	}`), &out)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package rql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cache is the interface of the cache of compiled artifacts, like the queries of Parser.Compile.
+// See Config.Cache. Implementations must be safe for concurrent use. The values are serialized
+// artifacts that don't reference the parser, and the keys are stable between processes that use
+// the same schema. Therefore, the cache can be shared by many processes (e.g. backed by Redis).
+type Cache interface {
+	// Get returns the value that is stored under the given key, and reports if it was
+	// found and did not expire.
+	Get(key string) ([]byte, bool)
+	// Set stores the value under the given key. A zero TTL means the value doesn't expire.
+	Set(key string, v []byte, ttl time.Duration)
+}
+
+var _ Cache = (*MapCache)(nil)
+
+// MapCache is an in-process Cache that is backed by a map. Expired entries are evicted
+// lazily, when they are looked up, or when the map grows.
+type MapCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// sweep is the size of the map that triggers the eviction of the expired entries.
+	sweep int
+}
+
+type cacheEntry struct {
+	value   []byte
+	expires time.Time // zero for entries that don't expire.
+}
+
+// NewMapCache returns a new in-process cache.
+func NewMapCache() *MapCache {
+	return &MapCache{entries: make(map[string]cacheEntry), sweep: 64}
+}
+
+// Get implements the Cache interface.
+func (c *MapCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(time.Now()) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set implements the Cache interface.
+func (c *MapCache) Set(key string, v []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := cacheEntry{value: v}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	c.entries[key] = e
+	if len(c.entries) < c.sweep {
+		return
+	}
+	now := time.Now()
+	for k, e := range c.entries {
+		if e.expired(now) {
+			delete(c.entries, k)
+		}
+	}
+	// the next sweep happens when the live entries are doubled.
+	c.sweep = 2 * len(c.entries)
+}
+
+// Len returns the number of entries in the cache, including the expired entries that
+// were not evicted yet.
+func (c *MapCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (e cacheEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// cacheKey returns the key of the given artifact kind and input in the cache. The key contains
+// the schema fingerprint of the parser, that is changed when its fields are changed. It is called
+// with p.mu held.
+func (p *Parser) cacheKey(kind string, b []byte) string {
+	sum := sha256.Sum256(b)
+	return "rql:" + p.schema + ":" + kind + ":" + hex.EncodeToString(sum[:])
+}
+
+// initSchema computes the schema fingerprint of the parser. It is a stable hash of the fields
+// (their names, columns, types and operators), the relations and the options that affect the
+// format of the query. Unlike a counter, it has the same value in all processes that use the
+// same model. It is called with p.mu held, after the fields are changed.
+func (p *Parser) initSchema() {
+	h := fnv.New64a()
+	write := func(ss ...string) {
+		for _, s := range ss {
+			h.Write([]byte(s))
+			h.Write([]byte{0})
+		}
+	}
+	write(p.conf.OpPrefix, p.conf.FieldSep)
+	names := make([]string, 0, len(p.fields))
+	for name := range p.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := p.fields[name]
+		write(name, f.Name, f.Column, f.column, fmt.Sprint(f.Type), f.Layout, strconv.FormatBool(f.Filterable), strconv.FormatBool(f.Sortable), strconv.FormatBool(f.Searchable))
+		ops := make([]string, 0, len(f.FilterOps))
+		for op := range f.FilterOps {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+		write(ops...)
+	}
+	names = names[:0]
+	for name := range p.relations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		write(name, p.relations[name].Parser.schema)
+	}
+	p.schema = fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,91 @@
+package rql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	type User struct {
+		Age  int    `rql:"filter"`
+		Name string `rql:"filter,since=v2"`
+	}
+	c := NewMapCache()
+	conf := Config{
+		Model:    new(User),
+		Log:      t.Logf,
+		Cache:    c,
+		CacheTTL: time.Hour,
+	}
+	p := MustNewParser(conf)
+	b := []byte(`{"filter": {"age": {"$gt": {"$var": "min_age"}}}, "vars": {"min_age": 18}}`)
+	cq1, err := p.Compile(b)
+	if err != nil {
+		t.Fatalf("failed to compile: %v", err)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("expect the compiled query to be cached, got %d entries", c.Len())
+	}
+	// parsers of other processes with the same model share the cache entries.
+	cq2, err := MustNewParser(conf).Compile(b)
+	if err != nil {
+		t.Fatalf("failed to compile: %v", err)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("expect parsers with the same schema to share the entries, got %d entries", c.Len())
+	}
+	for _, vars := range []map[string]interface{}{nil, {"min_age": 21}} {
+		out1, err := cq1.Exec(vars)
+		if err != nil {
+			t.Fatalf("failed to exec: %v", err)
+		}
+		out2, err := cq2.Exec(vars)
+		if err != nil {
+			t.Fatalf("failed to exec cached query: %v", err)
+		}
+		assertParams(t, out2, out1)
+	}
+	if _, err := p.Compile([]byte(`{"filter": {"email": "a8m"}}`)); err == nil {
+		t.Fatal("expect error for unknown field")
+	}
+	if c.Len() != 1 {
+		t.Fatal("expect errors to not be cached")
+	}
+	// versioned parsers share the cache, but not its entries.
+	if _, err := p.For("v1").Compile(b); err != nil {
+		t.Fatalf("failed to compile: %v", err)
+	}
+	if c.Len() != 2 {
+		t.Fatal("expect versioned parsers to use their own entries")
+	}
+	if err := p.RemoveField("age"); err != nil {
+		t.Fatalf("failed to remove field: %v", err)
+	}
+	if _, err := p.Compile(b); err == nil {
+		t.Fatal("expect error for removed field")
+	}
+	if _, err := NewParser(Config{Model: new(struct{}), CacheTTL: -1}); err == nil {
+		t.Fatal("expect error for negative TTL")
+	}
+}
+
+func TestMapCache(t *testing.T) {
+	c := NewMapCache()
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if v, ok := c.Get("a"); !ok || string(v) != "1" {
+		t.Fatalf("unexpected value: %s", v)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expect entry to expire")
+	}
+	for i := 0; i < 100; i++ {
+		c.Set(string(rune('c'+i)), []byte{byte(i)}, time.Nanosecond)
+	}
+	time.Sleep(time.Millisecond)
+	c.Set("d", []byte("4"), 0)
+	if n := c.Len(); n >= 100 {
+		t.Fatalf("expect expired entries to be evicted, got %d entries", n)
+	}
+}
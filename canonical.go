@@ -0,0 +1,96 @@
+package rql
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Canonical returns a deterministic, multi-line text form of the params, suitable for
+// golden-file (snapshot) tests. The filter values are written inline, and the terms of
+// each conjunction are sorted, so the output does not depend on the order of the keys
+// in the query. For example:
+//
+//	filter: (city = 'NYC' OR city = 'TLV') AND age > 10
+//	sort: name desc
+//	limit: 25
+//	offset: 0
+//
+// Like Explain, the returned string must not be used as an SQL statement.
+func (p *Params) Canonical() string {
+	var b strings.Builder
+	if p.Select != "" {
+		b.WriteString("select: " + p.Select + "\n")
+	}
+	for _, j := range p.Joins {
+		b.WriteString("join: " + j.String() + "\n")
+	}
+	switch {
+	case p.filter != nil && !p.filter.empty():
+		b.WriteString("filter: " + canonical(sorted(p.filter)) + "\n")
+	case p.FilterExp != "":
+		b.WriteString("filter: " + p.FilterExp + "\n")
+	}
+	if p.Unsatisfiable {
+		b.WriteString("unsatisfiable: true\n")
+	}
+	if p.Sort != "" {
+		b.WriteString("sort: " + p.Sort + "\n")
+	}
+	b.WriteString("limit: " + strconv.Itoa(p.Limit) + "\n")
+	b.WriteString("offset: " + strconv.Itoa(p.Offset))
+	return b.String()
+}
+
+// canonical returns the SQL representation of the node with its values written inline.
+func canonical(e *expr) string {
+	b := &bytes.Buffer{}
+	e.write(b, func(b *bytes.Buffer, _ *field, v interface{}) {
+		b.WriteString(literal(v))
+	})
+	return b.String()
+}
+
+// sorted returns a copy of the node, where nested conjunctions of the same kind are
+// flattened, and the terms of each conjunction are sorted by their canonical representation.
+func sorted(e *expr) *expr {
+	c := *e
+	if e.sub != nil {
+		c.sub = sorted(e.sub)
+	}
+	if !e.group() {
+		return &c
+	}
+	c.children = nil
+	for _, t := range e.children {
+		t = sorted(t)
+		if t.group() && t.op == e.op {
+			c.children = append(c.children, t.children...)
+		} else {
+			c.children = append(c.children, t)
+		}
+	}
+	if len(c.children) == 1 {
+		return c.children[0]
+	}
+	keys := make([]string, len(c.children))
+	for i, t := range c.children {
+		keys[i] = canonical(t)
+	}
+	sort.Sort(byKey{keys, c.children})
+	return &c
+}
+
+// byKey sorts nodes by their keys.
+type byKey struct {
+	keys  []string
+	nodes []*expr
+}
+
+func (s byKey) Len() int           { return len(s.keys) }
+func (s byKey) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s byKey) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.nodes[i], s.nodes[j] = s.nodes[j], s.nodes[i]
+}
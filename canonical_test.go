@@ -0,0 +1,51 @@
+package rql
+
+import "testing"
+
+func TestCanonical(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			City string `rql:"filter"`
+			Name string `rql:"filter,sort"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		inputs []string
+		want   string
+	}{
+		{
+			inputs: []string{`{}`},
+			want:   "limit: 25\noffset: 0",
+		},
+		{
+			inputs: []string{
+				`{"filter": {"age": {"$gt": 10}, "$or": [{"city": "TLV"}, {"city": "NYC"}]}, "sort": ["-name"], "select": ["name"]}`,
+				`{"select": ["name"], "sort": ["-name"], "filter": {"$or": [{"city": "NYC"}, {"city": "TLV"}], "age": {"$gt": 10}}}`,
+			},
+			want: "select: name\nfilter: (city = 'NYC' OR city = 'TLV') AND age > 10\nsort: name desc\nlimit: 25\noffset: 0",
+		},
+		{
+			inputs: []string{
+				`{"filter": {"name": "a", "age": 1, "city": "b"}, "limit": 5, "offset": 10}`,
+				`{"filter": {"$and": [{"city": "b"}, {"age": 1}, {"name": "a"}]}, "offset": 10, "limit": 5}`,
+			},
+			want: "filter: age = 1 AND city = 'b' AND name = 'a'\nlimit: 5\noffset: 10",
+		},
+	}
+	for _, tt := range tests {
+		for _, input := range tt.inputs {
+			out, err := p.Parse([]byte(input))
+			if err != nil {
+				t.Fatalf("failed to parse %s: %v", input, err)
+			}
+			if got := out.Canonical(); got != tt.want {
+				t.Errorf("canonical of %s:\n\tgot: %q\n\twant: %q", input, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package rql
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Report describes the configuration of a parser, as it was derived from its model and options.
+// See Parser.Check.
+type Report struct {
+	// Fields are the fields that are accepted by the parser, sorted by their names. They
+	// describe the operators of each field, its layout, its column and its sorting.
+	Fields []FieldMeta
+	// Skipped are the fields, and the tag options, that were ignored by the parser. For
+	// example, unknown tag options (e.g. a typo like "fitler"), or embedded fields that are
+	// not structs.
+	Skipped []Skipped
+}
+
+// Skipped is a field, or a tag option of a field, that was ignored by the parser.
+type Skipped struct {
+	// Field is the name of the struct field, or the name of the field in the query
+	// for options that were ignored by the dialect.
+	Field string
+	// Reason describes why it was ignored. For example, `unknown option "fitler"`.
+	Reason string
+}
+
+// String implements the fmt.Stringer interface.
+func (s Skipped) String() string {
+	return fmt.Sprintf("field %q: %s", s.Field, s.Reason)
+}
+
+// Check returns a report of the parser configuration. It allows tests (e.g. in CI) to verify
+// that the tags of the model are configured as expected, because fields and options that are
+// ignored are only logged when the parser is created. For example:
+//
+//	r := p.Check()
+//	for _, s := range r.Skipped {
+//		t.Errorf("rql: %s", s)
+//	}
+//
+// See the StrictTags option for failing the creation of the parser instead.
+func (p *Parser) Check() *Report {
+	r := &Report{Fields: p.Fields()}
+	p.mu.RLock()
+	r.Skipped = append(r.Skipped, p.skipped...)
+	p.mu.RUnlock()
+	sort.SliceStable(r.Skipped, func(i, j int) bool { return r.Skipped[i].Field < r.Skipped[j].Field })
+	return r
+}
@@ -0,0 +1,72 @@
+package rql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	model := new(struct {
+		int
+		Name      string `rql:"filter,sort,fitler"`
+		Age       int    `rql:"filter"`
+		CreatedAt string `rql:"sort,column=created"`
+	})
+	p, err := NewParser(Config{Model: model, Log: t.Logf})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	r := p.Check()
+	if len(r.Fields) != 3 || r.Fields[0].Name != "age" || !reflect.DeepEqual(r.Fields[0].Ops, []Op{EQ, GT, GTE, IN, LT, LTE, NEQ, NIN}) {
+		t.Fatalf("unexpected fields: %+v", r.Fields)
+	}
+	want := []Skipped{
+		{Field: "Name", Reason: `unknown option "fitler"`},
+		{Field: "int", Reason: "embedded field that is not struct type"},
+	}
+	if !reflect.DeepEqual(r.Skipped, want) {
+		t.Fatalf("skipped:\n\tgot: %v\n\twant %v", r.Skipped, want)
+	}
+	if err := p.AddField(FieldSpec{Name: "color", Type: reflect.TypeOf(""), Options: "filter,srot"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r := p.Check(); len(r.Skipped) != 3 || r.Skipped[1].String() != `field "color": unknown option "srot"` {
+		t.Fatalf("unexpected skipped: %v", r.Skipped)
+	}
+	if err := p.RemoveField("color"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r := p.Check(); len(r.Skipped) != 2 {
+		t.Fatalf("unexpected skipped: %v", r.Skipped)
+	}
+	if _, err := NewParser(Config{Model: model, StrictTags: true, Log: t.Logf}); err == nil || !strings.Contains(err.Error(), `field "Name": unknown option "fitler"`) {
+		t.Fatalf("expect strict tags error, got: %v", err)
+	}
+	p, err = NewParser(Config{
+		Model: new(struct {
+			ID   int    `rql:"filter,partition"`
+			Name string `rql:"filter,sort"`
+		}),
+		Dialect:        DialectCQL,
+		AllowFiltering: true,
+		Log:            t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	if r := p.Check(); len(r.Skipped) != 1 || r.Skipped[0].Field != "name" {
+		t.Fatalf("unexpected skipped: %v", r.Skipped)
+	}
+	strict := MustNewParser(Config{Model: new(struct{}), StrictTags: true})
+	for _, spec := range []FieldSpec{
+		{Name: "color", Type: reflect.TypeOf(""), Options: "filter,srot"},
+	} {
+		if err := strict.AddField(spec); err == nil {
+			t.Fatalf("expect strict tags error for spec: %+v", spec)
+		}
+	}
+	if len(strict.Fields()) != 0 {
+		t.Fatal("expect the field to not be added")
+	}
+}
@@ -0,0 +1,232 @@
+package rql
+
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
+// CompiledQuery is a query that was parsed once, and can be executed many times with
+// different variable values. Variables are referenced in the filter using the "$var"
+// operator. For example:
+//
+//	cq, err := p.Compile([]byte(`{"filter": {"age": {"$gt": {"$var": "min_age"}}, "name": {"$var": "name"}}}`))
+//	if err != nil {
+//		return err
+//	}
+//	params, err := cq.Exec(map[string]interface{}{"min_age": 18, "name": "a8m"})
+//
+// It is useful for saved filters, and for avoiding the parsing of frequently used queries.
+// A CompiledQuery is safe for concurrent use.
+type CompiledQuery struct {
+	p      *Parser
+	params Params
+	vars   []string
+	// values are the variables that were defined in the query.
+	values map[string]interface{}
+}
+
+// Compile parses the given buffer into a compiled query. The query may contain variables.
+// If the parser is configured with a Cache, the canonical queries (see Parser.Query) of the
+// compiled queries are cached by their input. Cached queries skip the decoding of the input,
+// and the resolution of its aliases and presets, but they are still validated against the
+// schema of the parser. Queries with warnings or cursors are not cached.
+func (p *Parser) Compile(b []byte) (*CompiledQuery, error) {
+	var key string
+	if p.conf.Cache != nil {
+		p.mu.RLock()
+		key = p.cacheKey("compile", b)
+		p.mu.RUnlock()
+		if cq, ok := p.cached(key); ok {
+			return cq, nil
+		}
+	}
+	q := &Query{}
+	if err := p.decode(b, q); err != nil {
+		err.msg = "decoding buffer to *Query: " + err.msg
+		return nil, p.reject(context.Background(), err)
+	}
+	cq, err := p.CompileQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" && len(cq.params.Warnings) == 0 && q.After == "" && q.Before == "" {
+		canonical := p.Query(&cq.params)
+		canonical.Vars = q.Vars
+		if v, err := canonical.MarshalJSON(); err == nil {
+			p.conf.Cache.Set(key, v, p.conf.CacheTTL)
+		}
+	}
+	return cq, nil
+}
+
+// cached compiles the canonical query that is stored under the given key in the cache. Entries
+// that can't be compiled (e.g. they were stored by a process with another schema) are ignored.
+func (p *Parser) cached(key string) (*CompiledQuery, bool) {
+	v, ok := p.conf.Cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	q := &Query{}
+	if err := q.UnmarshalJSON(v); err != nil {
+		return nil, false
+	}
+	cq, err := p.CompileQuery(q)
+	return cq, err == nil
+}
+
+// CompileQuery is like Compile, but it accepts a decoded query.
+func (p *Parser) CompileQuery(q *Query) (cq *CompiledQuery, err error) {
+	var pr *Params
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	defer p.catch(context.Background(), &pr, &err)
+	ps := p.newParseState(context.Background(), ParseOptions{})
+	ps.compiling = true
+	pr = ps.parse(q, &Params{})
+	ps.release()
+	cq = &CompiledQuery{p: p, params: *pr, values: q.Vars}
+	seen := make(map[string]bool)
+	walk(pr.filter, func(e *expr) {
+		if e.variable != "" && !seen[e.variable] {
+			seen[e.variable] = true
+			cq.vars = append(cq.vars, e.variable)
+		}
+	})
+	sort.Strings(cq.vars)
+	return cq, nil
+}
+
+// Vars returns the names of the variables that are used in the query, sorted.
+func (c *CompiledQuery) Vars() []string {
+	return append([]string(nil), c.vars...)
+}
+
+// Exec binds the given variables to the query, and returns its params. The variable values
+// follow the types of the JSON input, but Go numeric types are accepted for numbers. Values
+// of time fields are strings in the layout of the field. The given variables take precedence
+// over the "vars" object of the compiled query.
+func (c *CompiledQuery) Exec(vars map[string]interface{}) (pr *Params, err error) {
+	c.p.mu.RLock()
+	defer c.p.mu.RUnlock()
+	defer c.p.catch(context.Background(), &pr, &err)
+	ps := c.p.newParseState(context.Background(), ParseOptions{Vars: vars})
+	ps.queryVars = c.values
+	ps.values = make([]interface{}, 0, 8)
+	pr = new(Params)
+	*pr = c.params
+	pr.Joins = append([]JoinClause(nil), c.params.Joins...)
+	pr.Warnings = append([]Warning(nil), c.params.Warnings...)
+	pr.filter = ps.bindVars(c.params.filter)
+	ps.finish(pr)
+	ps.release()
+	return
+}
+
+// variable returns the variable name, if the given value is a variable reference.
+func (p *parseState) variable(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	ref, ok := m[p.op(VAR)]
+	if !ok {
+		return "", false
+	}
+	name, ok := ref.(string)
+	expect(ok && name != "", CodeInvalidQuery, "", "%s must be a non-empty string", p.op(VAR))
+	p.disabledOp("", VAR)
+	return name, true
+}
+
+// varValue returns the value of the given variable. Server-supplied values take precedence
+// over the values of the query.
+func (p *parseState) varValue(f *field, name string) interface{} {
+	v, ok := p.vars[name]
+	if !ok {
+		v, ok = p.queryVars[name]
+	}
+	expect(ok, CodeInvalidValue, f.Name, "missing value for variable %q", name)
+	return jsonValue(v)
+}
+
+// varPredicate creates a comparison node whose value is bound later.
+func (p *parseState) varPredicate(f *field, r *relation, op Op, name string) *expr {
+	return &expr{
+		op:       op,
+		field:    f,
+		column:   p.column(f, r),
+		sql:      p.opSQL(op),
+		join:     r,
+		variable: name,
+	}
+}
+
+// bindVars returns a copy of the given expression, where the variables are replaced by their values.
+func (p *parseState) bindVars(e *expr) *expr {
+	c := *e
+	switch {
+	case e.variable != "":
+		f := e.field
+		v := p.varValue(f, e.variable)
+		if e.op.list() {
+			vs, ok := v.([]interface{})
+			expect(ok && len(vs) > 0, CodeInvalidValue, f.Name, "variable %q of field %q must be a non-empty array", e.variable, f.Name)
+			values := make([]interface{}, len(vs))
+			for i := range vs {
+				vs[i] = jsonValue(vs[i])
+				must(p.validateElem(f, e.op, vs[i]), f.Name, "invalid datatype or format for variable %q", e.variable)
+				values[i] = p.convert(f, e.op, vs[i])
+			}
+			c.raw, c.value = vs, values
+		} else {
+			must(p.validate(f, e.op, v), f.Name, "invalid datatype or format for variable %q", e.variable)
+			c.raw, c.value = v, p.convert(f, e.op, v)
+		}
+		c.variable = ""
+	case e.sub != nil:
+		c.sub = p.bindVars(e.sub)
+	case e.group():
+		c.children = make([]*expr, len(e.children))
+		for i := range e.children {
+			c.children[i] = p.bindVars(e.children[i])
+		}
+	}
+	return &c
+}
+
+// jsonValue converts Go numeric values to float64, the type of JSON numbers.
+// Slices are converted to []interface{}.
+func jsonValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32:
+		return rv.Float()
+	case reflect.Slice:
+		if _, ok := v.([]interface{}); ok {
+			return append([]interface{}(nil), v.([]interface{})...)
+		}
+		vs := make([]interface{}, rv.Len())
+		for i := range vs {
+			vs[i] = rv.Index(i).Interface()
+		}
+		return vs
+	default:
+		return v
+	}
+}
+
+// walk calls fn for each node in the expression tree.
+func walk(e *expr, fn func(*expr)) {
+	fn(e)
+	if e.sub != nil {
+		walk(e.sub, fn)
+	}
+	for _, c := range e.children {
+		walk(c, fn)
+	}
+}
@@ -0,0 +1,191 @@
+package rql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	cq, err := p.Compile([]byte(`{
+		"filter": {
+			"$and": [
+				{"age": {"$gt": {"$var": "min_age"}}},
+				{"name": {"$in": {"$var": "names"}}},
+				{"$or": [{"age": {"$var": "age"}}, {"age": 0}]}
+			]
+		},
+		"sort": ["-age"],
+		"limit": 10
+	}`))
+	if err != nil {
+		t.Fatalf("failed to compile: %v", err)
+	}
+	if vars := cq.Vars(); !reflect.DeepEqual(vars, []string{"age", "min_age", "names"}) {
+		t.Fatalf("unexpected vars: %v", vars)
+	}
+	tests := []struct {
+		name    string
+		vars    map[string]interface{}
+		wantOut *Params
+		wantErr bool
+	}{
+		{
+			name: "go types",
+			vars: map[string]interface{}{"min_age": 18, "names": []string{"a", "b"}, "age": uint8(5)},
+			wantOut: &Params{
+				Limit:      10,
+				FilterExp:  "(age > ? AND name IN (?, ?) AND (age = ? OR age = ?))",
+				FilterArgs: []interface{}{18, "a", "b", 5, 0},
+				Sort:       "age desc",
+			},
+		},
+		{
+			name: "json types",
+			vars: map[string]interface{}{"min_age": float64(20), "names": []interface{}{"c"}, "age": float64(1)},
+			wantOut: &Params{
+				Limit:      10,
+				FilterExp:  "(age > ? AND name IN (?) AND (age = ? OR age = ?))",
+				FilterArgs: []interface{}{20, "c", 1, 0},
+				Sort:       "age desc",
+			},
+		},
+		{
+			name:    "missing",
+			vars:    map[string]interface{}{"min_age": 18, "names": []string{"a"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid type",
+			vars:    map[string]interface{}{"min_age": "18", "names": []string{"a"}, "age": 1},
+			wantErr: true,
+		},
+		{
+			name:    "empty list",
+			vars:    map[string]interface{}{"min_age": 18, "names": []string{}, "age": 1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := cq.Exec(tt.vars)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("want error: %v, got: %v", tt.wantErr, err)
+			}
+			if err == nil {
+				assertParams(t, out, tt.wantOut)
+			}
+		})
+	}
+	if _, err := p.Parse([]byte(`{"filter": {"age": {"$var": "age"}}}`)); err == nil {
+		t.Fatal("expect variables without values to be rejected")
+	}
+	if _, err := p.Compile([]byte(`{"filter": {"age": {"$var": 1}}}`)); err == nil {
+		t.Fatal("expect invalid variable reference to be rejected")
+	}
+}
+
+func TestCompileInputGuards(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+		}),
+		MaxInputBytes:       128,
+		MaxJSONDepth:        4,
+		RejectDuplicateKeys: true,
+		Log:                 t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	if _, err := p.Compile([]byte(`{"filter": {"name": {"$var": "a"}}}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name  string
+		input string
+		code  ErrorCode
+	}{
+		{name: "size", input: `{"filter": {"name": "` + strings.Repeat("a", 128) + `"}}`, code: CodeInputTooLarge},
+		{name: "depth", input: `{"filter": {"$or": [{"name": {"$var": "a"}}]}}`, code: CodeInputTooLarge},
+		{name: "duplicate keys", input: `{"filter": {"name": {"$var": "a"}, "name": "a8m"}}`, code: CodeInvalidJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Compile([]byte(tt.input))
+			if perr, ok := err.(*ParseError); !ok || perr.Code != tt.code {
+				t.Fatalf("expect error with code %q, got: %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestVars(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter"`
+			Name string `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	input := []byte(`{
+		"vars": {"min_age": 18, "name": "a8m"},
+		"filter": {"$and": [{"age": {"$gte": {"$var": "min_age"}}}, {"name": {"$var": "name"}}]}
+	}`)
+	out, err := p.Parse(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(age >= ? AND name = ?)",
+		FilterArgs: []interface{}{18, "a8m"},
+	})
+	out, err = p.ParseWithOptions(input, ParseOptions{Vars: map[string]interface{}{"min_age": 21}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(age >= ? AND name = ?)",
+		FilterArgs: []interface{}{21, "a8m"},
+	})
+	for _, input := range []string{
+		`{"vars": {"min_age": "18"}, "filter": {"age": {"$gte": {"$var": "min_age"}}}}`,
+		`{"vars": {"min_age": 18}, "filter": {"age": {"$gte": {"$var": "max_age"}}}}`,
+		`{"vars": {"min_age": 1.5}, "filter": {"age": {"$var": "min_age"}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Errorf("expect error for input: %s", input)
+		}
+	}
+	cq, err := p.Compile([]byte(`{"vars": {"min_age": 18}, "filter": {"age": {"$gte": {"$var": "min_age"}}}}`))
+	if err != nil {
+		t.Fatalf("failed to compile: %v", err)
+	}
+	for _, tt := range []struct {
+		vars map[string]interface{}
+		want int
+	}{
+		{nil, 18},
+		{map[string]interface{}{"min_age": 30}, 30},
+	} {
+		out, err := cq.Exec(tt.vars)
+		if err != nil || !reflect.DeepEqual(out.FilterArgs, []interface{}{tt.want}) {
+			t.Errorf("exec with %v: %v, %v", tt.vars, out, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package rql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// initComputed validates the computed fields of the parser.
+func (p *Parser) initComputed() error {
+	for name, c := range p.conf.Computed {
+		if _, ok := p.fields[name]; ok {
+			return fmt.Errorf("rql: computed field %q conflicts with a field with the same name", name)
+		}
+		if strings.TrimSpace(c.SQL) == "" {
+			return fmt.Errorf("rql: computed field %q: missing SQL expression", name)
+		}
+	}
+	return nil
+}
+
+// initExprs maps the fields in Config.Exprs to their SQL expressions.
+func (p *Parser) initExprs() error {
+	for name, expr := range p.conf.Exprs {
+		f, ok := p.fields[name]
+		if !ok {
+			return fmt.Errorf("rql: expr for unknown field %q", name)
+		}
+		if expr = strings.TrimSpace(expr); expr == "" {
+			return fmt.Errorf("rql: empty expr for field %q", name)
+		}
+		f.Expr = expr
+		f.Column = expr
+	}
+	return nil
+}
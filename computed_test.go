@@ -0,0 +1,85 @@
+package rql
+
+import "testing"
+
+func TestComputed(t *testing.T) {
+	model := new(struct {
+		Name   string `rql:"filter"`
+		Active bool   `rql:"filter"`
+	})
+	label := "CASE WHEN active THEN 'Active' ELSE 'Inactive' END"
+	p, err := NewParser(Config{
+		Model: model,
+		Computed: map[string]Computed{
+			"status_label": {SQL: label, Sortable: true},
+			"name_length":  {SQL: "LENGTH(name)"},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"select": ["name", "status_label"], "sort": ["-status_label"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:  25,
+		Select: "name, " + label + " AS status_label",
+		Sort:   label + " desc",
+	})
+	for _, input := range []string{
+		`{"sort": ["name_length"]}`,
+		`{"filter": {"status_label": "Active"}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Errorf("expect error for input: %s", input)
+		}
+	}
+	for _, c := range []map[string]Computed{
+		{"name": {SQL: "UPPER(name)"}},
+		{"empty": {SQL: " "}},
+	} {
+		if _, err := NewParser(Config{Model: model, Computed: c}); err == nil {
+			t.Errorf("expect error for computed fields: %v", c)
+		}
+	}
+}
+
+func TestExprs(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Email    string `rql:"filter,sort"`
+			FullName string `rql:"filter,sort"`
+		}),
+		Exprs: map[string]string{
+			"email":     "LOWER(email)",
+			"full_name": "first_name || ' ' || last_name",
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"email": "a8m@example.com"}, "sort": ["full_name"], "select": ["email"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "LOWER(email) = ?",
+		FilterArgs: []interface{}{"a8m@example.com"},
+		Sort:       "first_name || ' ' || last_name",
+		Select:     "LOWER(email) AS email",
+	})
+	for _, exprs := range []map[string]string{
+		{"unknown": "LOWER(email)"},
+		{"email": " "},
+	} {
+		if _, err := NewParser(Config{Model: new(struct {
+			Email string `rql:"filter"`
+		}), Exprs: exprs}); err == nil {
+			t.Fatalf("expect error for exprs: %v", exprs)
+		}
+	}
+}
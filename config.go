@@ -1,9 +1,14 @@
 package rql
 
 import (
+	"context"
 	"errors"
 	"log"
 	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // Op is a filter operator used by rql.
@@ -14,6 +19,11 @@ func (o Op) SQL() string {
 	return opFormat[o]
 }
 
+// list reports if the operator accepts a list of values.
+func (o Op) list() bool {
+	return o == IN || o == NIN
+}
+
 // Operators that support by rql.
 const (
 	EQ   = Op("eq")   // =
@@ -23,40 +33,135 @@ const (
 	LTE  = Op("lte")  // <=
 	GTE  = Op("gte")  // >=
 	LIKE = Op("like") // LIKE "PATTERN"
+	IN   = Op("in")   // IN (...)
+	NIN  = Op("nin")  // NOT IN (...)
 	OR   = Op("or")   // disjunction
 	AND  = Op("and")  // conjunction
+
+	// CONTAINS is the containment operator of JSON documents and arrays (PostgreSQL).
+	// On range fields, it checks if the range contains an element.
+	CONTAINS = Op("contains") // @>
+	// OVERLAPS checks if a range field overlaps the range of the given bounds (PostgreSQL).
+	OVERLAPS = Op("overlaps") // && tstzrange(?, ?)
+
+	// Operators of relations.
+	COUNT = Op("count") // (SELECT COUNT(*) FROM ...)
+	HAS   = Op("has")   // EXISTS (SELECT 1 FROM ...)
+	NHAS  = Op("nhas")  // NOT EXISTS (SELECT 1 FROM ...)
+	WHERE = Op("where") // the filter of the related rows.
+
+	// PRESET references a named filter that is registered in Config.Presets.
+	PRESET = Op("preset")
+	// VAR references a variable that is bound when a compiled query is executed.
+	VAR = Op("var")
+	// TUPLE compares several fields as one row value. e.g. "(year, month) >= (?, ?)".
+	TUPLE = Op("tuple")
+
+	// Date-part operators of time fields. They compare a part of the time value, and
+	// accept a number or an object of comparisons. e.g. "EXTRACT(DOW FROM created_at) IN (?, ?)".
+	YEAR  = Op("year")  // EXTRACT(YEAR FROM ...)
+	MONTH = Op("month") // EXTRACT(MONTH FROM ...), 1-12
+	WEEK  = Op("week")  // EXTRACT(WEEK FROM ...), ISO week 1-53
+	DAY   = Op("day")   // EXTRACT(DAY FROM ...), 1-31
+	DOW   = Op("dow")   // EXTRACT(DOW FROM ...), 0 (Sunday) - 6 (Saturday)
+	HOUR  = Op("hour")  // EXTRACT(HOUR FROM ...), 0-23
+)
+
+// Converter converts a filter value. See Config.OpTransformers.
+type Converter func(interface{}) interface{}
+
+// QueryKeys are the JSON keys of the query fields. See Config.Keys.
+type QueryKeys struct {
+	Filter string
+	Sort   string
+	Limit  string
+	Offset string
+}
+
+// FieldType describes how the values of a custom field type are handled. See Config.Types.
+type FieldType struct {
+	// Underlying is the type whose validation, conversion and operators are used for the values
+	// of the custom type. For example, time.Time for a timestamp type, or string for a UUID type.
+	Underlying reflect.Type
+	// Validator is an optional validation that is applied on the values, after they were
+	// validated as values of the Underlying type.
+	Validator func(interface{}) error
+	// Converter is an optional conversion that is applied on the values, after they were
+	// converted to the Underlying type.
+	Converter Converter
+}
+
+// EmptyPolicy is the handling of empty strings and empty arrays in filter values. See Config.EmptyValues.
+type EmptyPolicy int
+
+// Policies for empty filter values.
+const (
+	EmptyPass   EmptyPolicy = iota // empty strings are passed to the database, and empty arrays are rejected.
+	EmptyReject                    // empty strings and empty arrays are rejected.
+	EmptyIgnore                    // predicates with empty strings or empty arrays are removed from the filter.
+)
+
+// StringNorm is a set of normalizations that are applied on string values. See Config.NormalizeStrings.
+type StringNorm uint
+
+// Normalizations of string values. They can be combined, like TrimSpace|CollapseSpace.
+const (
+	TrimSpace     StringNorm = 1 << iota // trims leading and trailing whitespace.
+	CollapseSpace                        // replaces runs of whitespace inside the value with one space.
+	NFC                                  // applies Unicode NFC normalization.
+)
+
+// BindStyle is the style of the placeholders (bind variables) used in the generated filter expression.
+type BindStyle int
+
+// Placeholder styles that are supported by rql.
+const (
+	BindQuestion BindStyle = iota // ?
+	BindDollar                    // $1, $2, ... (PostgreSQL)
+	BindAt                        // @p1, @p2, ... (SQL Server, Spanner)
+	BindColon                     // :p1, :p2, ... (Oracle, sqlx)
 )
 
+// named reports if the placeholders of this style are named parameters.
+func (s BindStyle) named() bool {
+	return s == BindAt || s == BindColon
+}
+
 // Default values for configuration.
 const (
 	DefaultTagName  = "rql"
 	DefaultOpPrefix = "$"
 	DefaultFieldSep = "_"
+	DefaultSortAsc  = "+"
+	DefaultSortDesc = "-"
 	DefaultLimit    = 25
 	DefaultMaxLimit = 100
 	Offset          = "offset"
 	Limit           = "limit"
+	// Unlimited can be used as the LimitMaxValue option in order
+	// to accept limits without an upper boundary.
+	Unlimited = -1
 )
 
 var (
-
-	// A sorting expression can be optionally prefixed with + or - to control the
-	// sorting direction, ascending or descending. For example, '+field' or '-field'.
-	// If the predicate is missing or empty then it defaults to '+'
-	sortDirection = map[byte]string{
-		'+': "asc",
-		'-': "desc",
-	}
-	opFormat = map[Op]string{
-		EQ:   "=",
-		NEQ:  "<>",
-		LT:   "<",
-		GT:   ">",
-		LTE:  "<=",
-		GTE:  ">=",
-		LIKE: "LIKE",
-		OR:   "OR",
-		AND:  "AND",
+	// sortPrefix matches the characters that are accepted as sort direction prefixes.
+	sortPrefix = regexp.MustCompile(`^[[:punct:]]+$`)
+	opFormat   = map[Op]string{
+		EQ:       "=",
+		NEQ:      "<>",
+		LT:       "<",
+		GT:       ">",
+		LTE:      "<=",
+		GTE:      ">=",
+		LIKE:     "LIKE",
+		IN:       "IN",
+		NIN:      "NOT IN",
+		CONTAINS: "@>",
+		OVERLAPS: "&&",
+		OR:       "OR",
+		AND:      "AND",
+		HAS:      "EXISTS",
+		NHAS:     "NOT EXISTS",
 	}
 )
 
@@ -109,6 +214,23 @@ type Config struct {
 	// 		Name 	string	`rql:"filter,column=full_name"`
 	// 	}
 	//
+	// For models that are backed by SQL views or joins, the column can be qualified with its table name.
+	// In this case, the name of the field in the query is not changed ("total" in the example below),
+	// and the qualified column is used in the filter, sort and select expressions. Each part of the
+	// column is quoted by the dialect ("orders"."total", or `orders`.`total` in MySQL and Spanner):
+	//
+	//	type OrderView struct {
+	// 		Total 	float64	`rql:"filter,sort,column=orders.total"`
+	// 	}
+	//
+	// A field can also be mapped to an SQL expression, like a subquery, using the "expr" option.
+	// The expression is controlled by the server, and the struct field type is used for validating
+	// the filter values. The "expr" option must be the last option in the tag:
+	//
+	//	type User struct {
+	// 		MaxOrder 	float64	`rql:"filter,sort,expr=(SELECT MAX(total) FROM orders o WHERE o.user_id = users.id)"`
+	// 	}
+	//
 	FieldSep string
 	// ColumnFn is the function that translate the struct field string into a table column.
 	// For example, given the following fields and their column names:
@@ -124,18 +246,774 @@ type Config struct {
 	// 	})
 	//
 	ColumnFn func(string) string
+	// FieldNameFn is an optional function that translates the struct field name into the name of the
+	// field in the query, when it should be different from its column. ColumnFn is still used for
+	// the generated columns. For example, the CamelCase function accepts camelCase query fields for
+	// snake_case columns:
+	//
+	//	FieldNameFn: rql.CamelCase,
+	//
+	//	AddressName => "addressName" in the query, and "address_name" in the database.
+	//
+	// In this mode, the "column" option of the field changes only its column.
+	FieldNameFn func(string) string
+	// TableName is the table (or the schema-qualified table) that the columns of the model are
+	// qualified with, in the filter, sort and select expressions. It is required when the caller
+	// adds joins to the query, and the joined tables have columns with the same names. For example:
+	//
+	//	TableName: "users"
+	//
+	//	{"filter": {"name": "a8m"}, "sort": ["-age"]} => "users.name = ?", "users.age desc"
+	//
+	// It is overridden by the table alias of ParseOptions.Alias. See Parser.ParseWithAlias for
+	// the columns that are not qualified.
+	TableName string
 	// Log the the logging function used to log debug information in the initialization of the parser.
 	// It defaults `to log.Printf`.
 	Log func(string, ...interface{})
 	// DefaultLimit is the default value for the `Limit` field that returns when no limit supplied by the caller.
 	// It defaults to 25.
 	DefaultLimit int
+	// NoDefaultLimit disables the default limit. When no limit is supplied by the caller, the `Limit` field
+	// of the Params is 0, which means that no implicit LIMIT should be applied to the query. It can not be
+	// used together with the DefaultLimit option.
+	NoDefaultLimit bool
 	// LimitMaxValue is the upper boundary for the limit field. User will get an error if the given value is greater
-	// than this value. It defaults to 100.
+	// than this value. It defaults to 100. Use Unlimited in order to accept any positive limit.
 	LimitMaxValue int
 	// DefaultSort is the default value for the 'Sort' field that returns when no sort expression is supplied by the caller.
 	// It defaults to an empty string slice.
 	DefaultSort []string
+	// SortAsc and SortDesc are the sets of characters that can prefix the sort fields, in order to
+	// control the sort direction. They default to "+" and "-". For example, with SortDesc set to
+	// "-!", both "-name" and "!name" sort by name in descending order. Sort fields that are
+	// prefixed with spaces are sorted in ascending order, because "+" is decoded as a space in
+	// query strings (e.g. "?sort=+name").
+	SortAsc  string
+	SortDesc string
+	// SortWords makes the parser accept the "asc" and "desc" words (case-insensitive) as prefixes
+	// or suffixes of the sort fields, separated by a space. For example, "name desc" or "desc name".
+	SortWords bool
+	// Normalize enables the normalization of the filter before it is rendered. Nested conjunctions
+	// of the same kind are flattened, duplicate predicates are dropped, and equality checks on the
+	// same field in a disjunction are collapsed into one IN predicate. For example:
+	//
+	//	{"$or": [{"city": "TLV"}, {"city": "NYC"}, {"$or": [{"city": "TLV"}]}]}
+	//
+	// Is rendered as "city IN (?, ?)" instead of "(city = ? OR city = ? OR city = ?)".
+	Normalize bool
+	// FilterPriority orders the top-level terms of the filter by the priorities of their fields
+	// (or relations), from the highest to the lowest, instead of the order of the JSON object. It
+	// allows putting the indexed or the most selective fields first, and it makes the generated SQL
+	// deterministic, for databases and ORMs that cache plans by the query text. For example:
+	//
+	//	FilterPriority: map[string]int{"tenant_id": 10, "status": 5}
+	//	{"filter": {"name": "a8m", "status": 1, "tenant_id": 2}}
+	//	=> "tenant_id = ? AND status = ? AND name = ?"
+	//
+	// Terms with the same priority (fields that are not in the map have a priority of 0) are ordered
+	// by name, and the nested "$or" and "$and" terms are placed after them.
+	FilterPriority map[string]int
+	// DetectContradictions enables the detection of trivially unsatisfiable filters at parse time,
+	// like "age > 10 AND age < 5". If the filter can never be true, the Unsatisfiable field of the
+	// returned Params is set, and the caller can skip the pointless database round-trip.
+	DetectContradictions bool
+	// BindStyle is the style of the placeholders in the generated filter expression. It defaults to
+	// BindQuestion ("?"). When using named parameters (BindAt or BindColon), the FilterArgs field of
+	// the Params holds values of type sql.NamedArg.
+	BindStyle BindStyle
+	// ReuseArgs makes the parser use one bind parameter per distinct value, instead of one for each
+	// occurrence of the value in the filter. It's common in large generated "$or" trees, and it keeps
+	// the number of arguments below the driver limits. For example, "(a = $1 OR b = $1)".
+	// This option is allowed only with numbered or named placeholders (i.e. not with BindQuestion).
+	ReuseArgs bool
+	// MaxArgs is the maximum number of arguments (placeholders) that a filter can have. Queries that
+	// exceed this limit are rejected at parse time, instead of failing at execution time (for example,
+	// PostgreSQL is limited to 65535 parameters). It defaults to 0, which means no limit.
+	MaxArgs int
+	// MaxBranches is the maximum number of terms in a single "$or" or "$and" array. Unlike MaxArgs,
+	// it limits the fan-out of the logical operators, like ORs with thousands of branches that are
+	// expensive to plan, even if each branch has a few arguments. Queries that exceed this limit
+	// are rejected with CodeTooManyBranches. It defaults to 0, which means no limit.
+	MaxBranches int
+	// MaxSQLLength is the maximum length (in bytes) of the rendered filter expression (FilterExp).
+	// It protects log pipelines and statement caches from huge WHERE clauses. Queries that exceed
+	// this limit are rejected with CodeSQLTooLong. In Sanitize mode, the last terms of the filter
+	// conjunction are dropped until it fits the limit, and a WarnTruncatedFilter warning is added.
+	// It defaults to 0, which means no limit.
+	MaxSQLLength int
+	// InChunkSize splits the lists of "$in" and "$nin" operators that are longer than this size into
+	// groups of this size. For example, with InChunkSize set to 2, "id IN (?, ?, ?)" is rendered as
+	// "(id IN (?, ?) OR id IN (?))". It defaults to 0, which means no splitting.
+	InChunkSize int
+	// Relations registers the has-many relations of the model that can be used in the filter. The
+	// keys are the names of the relations in the query, and the fields of a relation can be used
+	// in the filter and sort expressions by joining them with FieldSep (e.g. "orders.total"). In
+	// this case, the relation is returned in the Joins field of the Params.
+	//
+	// For example, given the following config:
+	//
+	//	rql.Config{
+	//		Model: User{},
+	//		Relations: map[string]rql.Relation{
+	//			"orders": {
+	//				Table: "orders",
+	//				On:    "orders.user_id = users.id",
+	//				Model: Order{},
+	//			},
+	//		},
+	//	}
+	//
+	// Users with at least 5 paid orders can be queried as follows:
+	//
+	//	{
+	//		"filter": {
+	//			"orders": {
+	//				"$count": { "$gte": 5 },
+	//				"$where": { "status": "paid" }
+	//			}
+	//		}
+	//	}
+	//
+	// The "$has" and "$nhas" operators check if there are related rows, and they accept
+	// either a boolean or the filter of the related rows. Users without paid orders are
+	// queried as follows:
+	//
+	//	{
+	//		"filter": {
+	//			"orders": { "$nhas": { "status": "paid" } }
+	//		}
+	//	}
+	//
+	Relations map[string]Relation
+	// OnReject is an optional hook that is called for every rejected query with the error code,
+	// and the field that caused the rejection (if any). It can be used for exporting metrics of
+	// unknown fields, type mismatches and limit violations, in order to spot misbehaving clients.
+	OnReject func(code ErrorCode, field string)
+	// OnRejectContext is like OnReject, but it's called with the context of the call (see
+	// Parser.ParseContext). For example, for tagging the metrics with the tenant of the request.
+	OnRejectContext func(ctx context.Context, code ErrorCode, field string)
+	// AllowOp is an optional hook that is called at parse time for every operator that is applied
+	// on a field (including the implicit equality of {"name": "a8m"}), with the context of the call
+	// (see Parser.ParseContext). Operators that are not allowed are rejected with CodeInvalidOp,
+	// or dropped in Sanitize mode. It allows toggling operators dynamically. For example:
+	//
+	//	AllowOp: func(ctx context.Context, f *rql.FieldMeta, op rql.Op) bool {
+	//		// LIKE queries on the name field are allowed only for internal callers.
+	//		return op != rql.LIKE || f.Name != "name" || internal(ctx)
+	//	}
+	//
+	// Operators that are not supported by the field type are rejected before the hook is called.
+	// The search term (see Query.Search) skips the searchable fields that LIKE is not allowed on.
+	AllowOp func(ctx context.Context, f *FieldMeta, op Op) bool
+	// ValidateValue is an optional hook that is called at parse time for every value that is
+	// compared with a field (and for every element of list values), after it was validated by
+	// the field type, with the context of the call. Values that are rejected by the hook fail
+	// the query with CodeInvalidValue, or are dropped in Sanitize mode. It allows validations
+	// that depend on the request, like tenant configuration or feature flags. For example:
+	//
+	//	ValidateValue: func(ctx context.Context, f *rql.FieldMeta, op rql.Op, v interface{}) error {
+	//		if f.Name == "region" && !tenant(ctx).HasRegion(v.(string)) {
+	//			return errors.New("unknown region")
+	//		}
+	//		return nil
+	//	}
+	//
+	ValidateValue func(ctx context.Context, f *FieldMeta, op Op, v interface{}) error
+	// ConvertValue is an optional hook that is applied at parse time on every value that is
+	// compared with a field (and on every element of list values), after it was converted to
+	// the field type and transformed by the OpTransformers, with the context of the call. For
+	// example, for mapping public identifiers to the internal ones of the request's tenant.
+	ConvertValue func(ctx context.Context, f *FieldMeta, op Op, v interface{}) interface{}
+	// DisabledOps are the operators (without the OpPrefix) that are rejected on all fields and
+	// relations, with CodeDisabledOp. It applies to the default operators, to the custom ones
+	// (see ExtraOps), and to the logical and relation operators, like OR and COUNT. Disabling
+	// LIKE also disables the search term (see Query.Search). For example, a parser of a public
+	// endpoint in production:
+	//
+	//	DisabledOps: []rql.Op{rql.LIKE, "regex"},
+	//
+	// The disabled operators are omitted from FieldMeta.Ops. In Sanitize mode, they are dropped.
+	DisabledOps []Op
+	// Sanitize enables the strip-and-continue mode. In this mode, filter terms, operators,
+	// sort and select keys that are unknown or disallowed (or that have a value of the wrong
+	// type) are removed from the query instead of failing it. The removed parts are reported
+	// in Params.Dropped. Note that dropping a term from a conjunction broadens the results,
+	// and dropping it from a disjunction narrows them. Malformed queries (e.g. invalid JSON or
+	// a "$or" that is not an array) and invalid limit or offset are still rejected.
+	Sanitize bool
+	// ClampLimit makes the parser clamp limits that are greater than LimitMaxValue to
+	// LimitMaxValue, instead of rejecting the query. A warning is added to Params.Warnings.
+	ClampLimit bool
+	// OnDeprecated is an optional hook that is called when a query uses a field that was
+	// marked with the "deprecated" option. For example:
+	//
+	//	CreatedAt time.Time `rql:"filter,sort"`
+	//	Created   time.Time `rql:"filter,deprecated=use created_at"`
+	//
+	// The usage is also reported in Params.Warnings. It can be used for tracking the clients
+	// that need to migrate before the field is removed.
+	OnDeprecated func(field string)
+	// Presets are named filter fragments (in JSON format) that can be referenced by queries
+	// using the "$preset" key. Presets are expanded and validated at parse time, and they are
+	// combined with the rest of the filter using AND. For example:
+	//
+	//	Presets: map[string]string{
+	//		"active_admins": `{"admin": true, "deleted_at": {"$gt": "2020-01-01T00:00:00Z"}}`,
+	//	}
+	//
+	//	{"filter": {"$preset": "active_admins", "name": {"$like": "a%"}}}
+	//
+	// A preset can reference other presets, but not itself. The presets are validated when the
+	// parser is created.
+	Presets map[string]string
+	// SortExprs are named SQL expressions that clients can order by, using an object in the sort
+	// array. Only the registered names are accepted, so no SQL comes from the client. Unlike the
+	// Computed expressions, they can be used only in the sort. For example:
+	//
+	//	SortExprs: map[string]string{
+	//		"priority": "CASE status WHEN 'urgent' THEN 0 WHEN 'high' THEN 1 ELSE 2 END",
+	//	}
+	//
+	//	{"sort": [{"expr": "priority"}, {"expr": "priority", "dir": "desc"}, "-created_at"]}
+	SortExprs map[string]string
+	// Windows are named window-function expressions that clients may include in the select
+	// expression, like "rank within group". The partition and order fields are validated when
+	// the parser is created, and no SQL comes from the client. For example:
+	//
+	//	Windows: map[string]Window{
+	//		"rank_in_city": {Func: "ROW_NUMBER()", PartitionBy: []string{"city"}, OrderBy: []string{"-score"}},
+	//	}
+	//
+	//	{"select": ["name", "rank_in_city"]}
+	//
+	// Produces: "name, ROW_NUMBER() OVER (PARTITION BY city ORDER BY score desc) AS rank_in_city".
+	Windows map[string]Window
+	// Computed are named SQL expressions (e.g. CASE expressions) that can be used in the select,
+	// and in the sort if they are marked as sortable. The client references them by name only,
+	// so no SQL comes from the client. For example:
+	//
+	//	Computed: map[string]Computed{
+	//		"status_label": {SQL: "CASE WHEN active THEN 'Active' ELSE 'Inactive' END", Sortable: true},
+	//	}
+	//
+	//	{"select": ["name", "status_label"], "sort": ["status_label"]}
+	Computed map[string]Computed
+	// Exprs maps model fields to SQL expressions, and it is the config equivalent of the "expr"
+	// tag option. The field keeps its name and type, and the expression is used instead of its
+	// column in the filter, sort and select expressions. For example:
+	//
+	//	Exprs: map[string]string{
+	//		"email":     "LOWER(email)",
+	//		"full_name": "first_name || ' ' || last_name",
+	//	}
+	//
+	// Fields that are mapped to an expression are treated as virtual columns. Therefore, they
+	// usually require an expression index for being filtered efficiently.
+	Exprs map[string]string
+	// Collate is an optional hook that returns the collation of a sortable field. The collation
+	// is appended to the sort expression of the field (e.g. "name COLLATE \"de_DE\" desc"), so
+	// alphabetical ordering is correct for non-ASCII locales. An empty string means no collation.
+	// Fields can also set their collation with the "collate" option, and it takes precedence
+	// over this hook. For example:
+	//
+	//	Name string `rql:"filter,sort,collate=utf8mb4_german2_ci"`
+	//
+	Collate func(f *FieldMeta) string
+	// Cast is an optional hook that returns the type that the placeholders of a field are cast
+	// to, for drivers that don't infer the types of the arguments. The cast is appended to the
+	// placeholders in the PostgreSQL syntax (e.g. "id = $1::uuid"). An empty string means no cast.
+	// Fields can also set their cast with the "cast" option, and it takes precedence over this
+	// hook. For example:
+	//
+	//	ID   string          `rql:"filter,cast=uuid"`
+	//	Meta json.RawMessage `rql:"filter,cast=jsonb"`
+	//
+	Cast func(f *FieldMeta) string
+	// Placeholder is an optional function for formatting the placeholders of the filter arguments,
+	// for drivers with a parameter syntax that is not covered by BindStyle. It is called with the
+	// 1-based index of the argument, and the field it is applied on (nil for values that don't belong
+	// to a field, like "$count" values). If set, it overrides the BindStyle option, and the arguments
+	// are not wrapped with sql.Named. For example:
+	//
+	//	Placeholder: func(i int, f *rql.FieldMeta) string {
+	//		if f != nil && f.Name == "id" {
+	//			return fmt.Sprintf("$%d::uuid", i)
+	//		}
+	//		return fmt.Sprintf("$%d", i)
+	//	}
+	//
+	Placeholder func(argIndex int, f *FieldMeta) string
+	// Dialect restricts the parser to the features that are supported by the target database.
+	// It defaults to DialectSQL. See the Dialect constants for details.
+	Dialect Dialect
+	// AllowFiltering allows filtering by non-key fields in the CQL dialect. Queries that
+	// use such fields are marked with Params.AllowFiltering.
+	AllowFiltering bool
+	// ExtraOps are custom filter operators that are added on top of the default operators.
+	// For example, a trigram similarity operator for string fields:
+	//
+	//	ExtraOps: []rql.OpSpec{
+	//		{
+	//			Name: "fuzzy",
+	//			SQL:  "%",
+	//			AppliesTo: func(f *rql.FieldMeta) bool {
+	//				return f.Type.Kind() == reflect.String
+	//			},
+	//		},
+	//	}
+	//
+	//	{"filter": {"name": {"$fuzzy": "a8m"}}}
+	//
+	// Produces: "name % ?".
+	ExtraOps []OpSpec
+	// OpAliases registers alternative spellings (without the OpPrefix) for the field operators,
+	// so the parser tolerates the operator names used by MongoDB and various frontend libraries
+	// without client-side translation. For example:
+	//
+	//	OpAliases: map[string]rql.Op{
+	//		"ne": rql.NEQ,
+	//		"le": rql.LTE,
+	//		"ge": rql.GTE,
+	//	}
+	//
+	//	{"filter": {"age": {"$ge": 18}, "status": {"$ne": "banned"}}}
+	//
+	// Aliases can also reference the operators of ExtraOps.
+	OpAliases map[string]Op
+	// OpTransformers are per-operator value transformers that are applied on the filter values
+	// after they were converted to the field type. They are useful for operator-specific value
+	// munging without changing the conversion of the fields. For example:
+	//
+	//	OpTransformers: map[rql.Op]rql.Converter{
+	//		rql.LIKE: func(v interface{}) interface{} {
+	//			if s, ok := v.(string); ok {
+	//				return strings.ToLower(s)
+	//			}
+	//			return v
+	//		},
+	//	}
+	//
+	// For list operators (e.g. $in), the transformer is applied on each value in the list.
+	OpTransformers map[Op]Converter
+	// Types maps custom field types, like the types of database drivers, to their handling.
+	// Without it, fields of types that are not supported by rql fail the creation of the
+	// parser. For example:
+	//
+	//	Types: map[reflect.Type]rql.FieldType{
+	//		reflect.TypeOf(uuid.UUID{}): {Underlying: reflect.TypeOf(""), Validator: validateUUID},
+	//	}
+	//
+	// See the rqlpgx package for the types of pgx.
+	Types map[reflect.Type]FieldType
+	// Converters are named value converters that can be assigned to fields using the "convert"
+	// option of the struct tag. They are applied on the filter values after they were converted
+	// to the field type, and before the OpTransformers. For example:
+	//
+	//	type Product struct {
+	//		Price float64 `rql:"filter,convert=cents"` // stored in cents.
+	//	}
+	//
+	//	Converters: map[string]rql.Converter{
+	//		"cents": func(v interface{}) interface{} {
+	//			return int64(math.Round(v.(float64) * 100))
+	//		},
+	//	}
+	//
+	// Fields that reference an unknown converter fail the creation of the parser.
+	Converters map[string]Converter
+	// Enums are named mappings of enum names to their integer values, that can be assigned to
+	// integer fields using the "enummap" option of the struct tag. Filter values of these fields
+	// can be either the names or the integer values, and the integer values are passed to the
+	// database. For example:
+	//
+	//	type User struct {
+	//		Status int `rql:"filter,enummap=status"`
+	//	}
+	//
+	//	Enums: map[string]map[string]int64{
+	//		"status": {"active": 1, "suspended": 2},
+	//	}
+	//
+	// Integer types that implement encoding.TextUnmarshaler are mapped automatically, and the
+	// names of other enum types (e.g. the ones that are generated by stringer) are declared
+	// using this option. Fields that reference an unknown mapping fail the creation of the parser.
+	Enums map[string]map[string]int64
+	// MaxInputBytes and MaxJSONDepth limit the size, and the nesting depth of objects and arrays,
+	// of the inputs of Parse. Inputs that exceed them are rejected with CodeInputTooLarge before
+	// they are decoded, so callers don't need to wrap request bodies with io.LimitReader, and
+	// pathological deeply-nested inputs can't exhaust the stack or the memory. The query object
+	// itself is at depth 1. Zero means no limit.
+	MaxInputBytes int
+	MaxJSONDepth  int
+	// EmptyValues is the policy for empty strings and empty arrays in filter values, like
+	// {"name": ""} or {"id": {"$in": []}}. It defaults to EmptyPass. Empty arrays are never passed
+	// to the database, because "IN ()" is invalid SQL. Ignored predicates are reported in
+	// Params.Warnings.
+	EmptyValues EmptyPolicy
+	// NormalizeStrings is the set of normalizations that are applied on string values before
+	// they are validated and converted. For example, with TrimSpace|CollapseSpace the value
+	// " foo   bar " is passed to the database as "foo bar". When whitespace is trimmed, values
+	// that contain only whitespace are rejected for the LIKE operator (search), and are handled
+	// by the EmptyValues policy for the other operators.
+	NormalizeStrings StringNorm
+	// NullAsIsNull makes the parser translate JSON null values in equality checks into NULL checks,
+	// instead of rejecting them. For example:
+	//
+	//	{"manager_id": null}             => "manager_id IS NULL"
+	//	{"manager_id": {"$eq": null}}    => "manager_id IS NULL"
+	//	{"manager_id": {"$neq": null}}   => "manager_id IS NOT NULL"
+	//
+	// Null values are still rejected for the other operators.
+	NullAsIsNull bool
+	// GormModel makes the parser register the fields of an embedded gorm.Model (of both
+	// github.com/jinzhu/gorm and gorm.io/gorm) as filterable and sortable, instead of ignoring
+	// them because they have no tags. The DeletedAt field is handled as a soft-delete marker:
+	// it accepts null values, and "deleted_at IS NULL" is added to filters that don't reference
+	// it. For example:
+	//
+	//	{"id": {"$gt": 10}}                 => "id > ? AND deleted_at IS NULL"
+	//	{"deleted_at": {"$neq": null}}      => "deleted_at IS NOT NULL"
+	GormModel bool
+	// CoerceStrings makes the parser accept string values for bool and numeric fields, and
+	// coerce them to the field type. For example, "true", "42" and "3.14". It is useful for
+	// queries that are built from query-string parameters, where clients can't easily send
+	// typed JSON values.
+	CoerceStrings bool
+	// RelativeTime makes time fields accept values that are relative to the current time, in
+	// addition to values in the field layout. A relative value is "now", optionally followed by
+	// a signed duration in the format of time.ParseDuration. For example:
+	//
+	//	{"created_at": {"$gte": "now-24h"}}   => "created_at >= ?" with the time of a day ago
+	//	{"expires_at": {"$lt": "now+1h30m"}}  => "expires_at < ?"
+	//
+	// The value is resolved using the Now function, and then formatted and parsed with the field
+	// layout. Hence, "now" on a field with the "2006-01-02" layout is the current date.
+	RelativeTime bool
+	// Now returns the current time that is used for resolving relative time values. Defaults to
+	// time.Now. It can be replaced in tests and replay tools in order to produce deterministic
+	// values for time-based filters.
+	Now func() time.Time
+	// FloatPrecision is the maximum number of decimal places that are accepted in the values of
+	// float fields (e.g. 2 for prices). Values with more decimal places are rejected instead of
+	// being rounded by the database. Zero means no limit. Note that NaN and infinite values are
+	// always rejected.
+	FloatPrecision int
+	// RejectDuplicateKeys makes the parser reject inputs with objects that have repeated keys,
+	// like {"age": 1, "age": 2}, instead of using the last value. Such inputs usually indicate
+	// client bugs or smuggling attempts. The error's Field holds the repeated key.
+	RejectDuplicateKeys bool
+	// CaseInsensitiveFields makes the parser resolve the field keys in the filter, sort and select
+	// expressions case-insensitively. For example, "Name", "name" and "NAME" resolve to the same
+	// field. Exact matches take precedence, and fields whose names differ only in case are rejected
+	// when the parser is created.
+	CaseInsensitiveFields bool
+	// StrictIdentifiers makes the parser re-validate every column it emits in the filter, sort and
+	// select expressions (after ColumnFn, the "column" option and relation qualifying were applied)
+	// against the pattern ^[a-zA-Z_][a-zA-Z0-9_.]*$, and reject the query if it doesn't match. It is
+	// a defense-in-depth layer against misconfigured ColumnFn implementations that may inject SQL.
+	// Fields that are explicitly mapped to SQL expressions (see Exprs and Computed) are not checked.
+	StrictIdentifiers bool
+	// SearchOp is the operator that combines the search term of the query (see Query.Search)
+	// with its filter. It can be AND (the default), for narrowing the filtered rows by the
+	// search term, or OR, for returning the rows that match either of them. For example:
+	//
+	//	{"filter": {"status": "active"}, "search": "a8m"}
+	//	AND => "status = ? AND (email LIKE ? OR name LIKE ?)"
+	//	OR  => "status = ? OR (email LIKE ? OR name LIKE ?)"
+	//
+	// The search term is matched against the fields that have the "search" option in their
+	// tag, and it is escaped, so its "%" and "_" characters are matched literally.
+	SearchOp Op
+	// DisableSearch makes the parser reject queries with a search term, even if the model
+	// has searchable fields. For example, for endpoints that share a model with the search
+	// endpoints, but don't have indexes for searching.
+	DisableSearch bool
+	// StrictTags makes NewParser (and AddField) fail if a field or a tag option of the model is
+	// ignored, instead of logging it. For example, unknown tag options (e.g. a typo like
+	// "fitler"), or sortable fields that are not clustering columns in the CQL dialect. It
+	// allows catching misconfigured tags in CI. See Parser.Check.
+	StrictTags bool
+	// Suggest adds the closest names (by edit distance) of the registered fields and operators to
+	// the errors of unrecognized fields and operators. The names are added to the message and to
+	// ParseError.Suggestions. For example:
+	//
+	//	{"filter": {"nmae": "a8m"}}
+	//	=> unrecognized key "nmae" for filtering, did you mean "name"?
+	//
+	// Only the fields that are allowed in the context of the error are suggested, e.g. sortable
+	// fields for sort keys.
+	Suggest bool
+	// CursorKey is the secret key that is used for signing the cursor tokens of keyset pagination.
+	// Cursors (i.e. the "after" and "before" fields) are rejected if it is empty. See Parser.EncodeCursor.
+	CursorKey []byte
+	// Cache is the cache of the compiled queries (see Parser.Compile). When it is set, Compile looks
+	// up the input in the cache before decoding it, and stores its canonical query for CacheTTL. Zero
+	// means the entries don't expire. It allows replacing the in-process MapCache with a shared or a
+	// bounded implementation. For example:
+	//
+	//	Cache:    rql.NewMapCache(),
+	//	CacheTTL: time.Hour,
+	//
+	// The cache keys are built from a fingerprint of the parser schema, and they are the same in all
+	// processes that use the same model. Entries that were stored before AddField or RemoveField are
+	// not used after the call.
+	Cache    Cache
+	CacheTTL time.Duration
+	// Keys renames the JSON keys of the query fields, for APIs whose public contract uses another
+	// naming convention, like Prisma or OData. Empty keys keep their default names. For example:
+	//
+	//	Keys: rql.QueryKeys{Filter: "where", Sort: "order", Limit: "take", Offset: "skip"},
+	//	{"where": {"age": {"$gt": 10}}, "order": ["-age"], "take": 10, "skip": 20}
+	//
+	// The default names of the renamed keys are rejected. Note that the keys apply only to the
+	// inputs of the parser, and Query values are always encoded with the default names.
+	Keys QueryKeys
+}
+
+// Computed is a server-registered SQL expression that clients can select, and optionally
+// sort by, using its name. See Config.Computed.
+type Computed struct {
+	// SQL is the expression. For example, "CASE WHEN active THEN 'Active' ELSE 'Inactive' END".
+	SQL string
+	// Sortable reports if the expression can be used in the sort.
+	Sortable bool
+}
+
+// Window is a named window-function expression. See Config.Windows.
+type Window struct {
+	// Func is the window function. For example, "ROW_NUMBER()", "RANK()" or "SUM(total)".
+	Func string
+	// PartitionBy and OrderBy are the model fields of the window. Fields in OrderBy can
+	// be prefixed with "-" or "+" for descending or ascending order.
+	PartitionBy []string
+	OrderBy     []string
+}
+
+// Relation is a has-many relation of the model, and it's used for
+// filtering rows by their related rows in another table.
+type Relation struct {
+	// Table is the name of the related table. For example, "orders".
+	Table string
+	// On is the condition that correlates the related rows with the parent row.
+	// For example, "orders.user_id = users.id".
+	On string
+	// Model is the definition of the related resource. Its fields (and their
+	// tags) are used for validating the filter applied on the related rows.
+	Model interface{}
+	// Parser is a pre-built parser of the related resource, and it can be set instead of
+	// the Model field. In this case, its filter rules, column naming and relations are used
+	// for the related rows, instead of being derived from the model with the configuration
+	// of the parent parser. It must use the same OpPrefix and BindStyle as the parent parser.
+	Parser *Parser
+	// Alias is an optional alias for the related table when it's joined to the query.
+	Alias string
+}
+
+// JoinClause describes a relation that needs to be joined to the query. It's returned in the
+// Params when the fields of a relation are used in the filter or sort expressions. For example:
+//
+//	{
+//		"filter": { "orders.status": "paid" },
+//		"sort": ["-orders.total"]
+//	}
+//
+// Note that joining a has-many relation may return the parent rows more than once.
+type JoinClause struct {
+	Table string
+	Alias string
+	On    string
+}
+
+// String returns the JOIN clause. For example, "JOIN orders ON orders.user_id = users.id".
+func (j JoinClause) String() string {
+	s := "JOIN " + j.Table
+	if j.Alias != "" {
+		s += " AS " + j.Alias
+	}
+	return s + " ON " + j.On
+}
+
+// configCheckRate is the rate of the parse calls in which the exported Config is checked
+// for changes, because comparing the configuration is as expensive as parsing a small query.
+const configCheckRate = 64
+
+// checkConfig reports the changes of the exported Config of the parser after its creation.
+// The changes are ignored, because the parser uses its own copy of the configuration, and
+// they are reported once, in order to not flood the log.
+func (p *Parser) checkConfig() {
+	if atomic.LoadInt32(&p.changed) == 1 || atomic.AddUint32(&p.calls, 1)%configCheckRate != 1 {
+		return
+	}
+	if !sameValue(reflect.ValueOf(&p.Config).Elem(), reflect.ValueOf(&p.conf).Elem()) && atomic.CompareAndSwapInt32(&p.changed, 0, 1) {
+		p.conf.Log("rql: the configuration of the parser was changed after NewParser. The changes are ignored")
+	}
+}
+
+// sameValue reports if the two values are deeply equal. Unlike reflect.DeepEqual, functions
+// are equal if they point to the same code, and pointers are equal only if they are identical.
+func sameValue(a, b reflect.Value) bool {
+	if a.Kind() != b.Kind() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Func, reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return a.Pointer() == b.Pointer()
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return a.Elem().Type() == b.Elem().Type() && sameValue(a.Elem(), b.Elem())
+	case reflect.Slice, reflect.Array:
+		if a.Kind() == reflect.Slice && a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !sameValue(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			v := b.MapIndex(iter.Key())
+			if !v.IsValid() || !sameValue(iter.Value(), v) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !sameValue(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	// the configuration has no complex numbers.
+	return true
+}
+
+// clone returns a copy of the configuration that doesn't share
+// its mutable fields (slices and maps) with the original one.
+func (c Config) clone() Config {
+	if c.DefaultSort != nil {
+		c.DefaultSort = append([]string(nil), c.DefaultSort...)
+	}
+	if c.DisabledOps != nil {
+		c.DisabledOps = append([]Op(nil), c.DisabledOps...)
+	}
+	if c.Relations != nil {
+		rs := make(map[string]Relation, len(c.Relations))
+		for k, r := range c.Relations {
+			rs[k] = r
+		}
+		c.Relations = rs
+	}
+	if c.Computed != nil {
+		cs := make(map[string]Computed, len(c.Computed))
+		for k, v := range c.Computed {
+			cs[k] = v
+		}
+		c.Computed = cs
+	}
+	if c.Windows != nil {
+		ws := make(map[string]Window, len(c.Windows))
+		for k, w := range c.Windows {
+			w.PartitionBy = append([]string(nil), w.PartitionBy...)
+			w.OrderBy = append([]string(nil), w.OrderBy...)
+			ws[k] = w
+		}
+		c.Windows = ws
+	}
+	if c.ExtraOps != nil {
+		c.ExtraOps = append([]OpSpec(nil), c.ExtraOps...)
+	}
+	if c.CursorKey != nil {
+		c.CursorKey = append([]byte(nil), c.CursorKey...)
+	}
+	if c.FilterPriority != nil {
+		ps := make(map[string]int, len(c.FilterPriority))
+		for k, v := range c.FilterPriority {
+			ps[k] = v
+		}
+		c.FilterPriority = ps
+	}
+	if c.OpAliases != nil {
+		as := make(map[string]Op, len(c.OpAliases))
+		for k, v := range c.OpAliases {
+			as[k] = v
+		}
+		c.OpAliases = as
+	}
+	if c.OpTransformers != nil {
+		ts := make(map[Op]Converter, len(c.OpTransformers))
+		for k, v := range c.OpTransformers {
+			ts[k] = v
+		}
+		c.OpTransformers = ts
+	}
+	if c.Types != nil {
+		ts := make(map[reflect.Type]FieldType, len(c.Types))
+		for k, v := range c.Types {
+			ts[k] = v
+		}
+		c.Types = ts
+	}
+	if c.Converters != nil {
+		cs := make(map[string]Converter, len(c.Converters))
+		for k, v := range c.Converters {
+			cs[k] = v
+		}
+		c.Converters = cs
+	}
+	if c.Enums != nil {
+		es := make(map[string]map[string]int64, len(c.Enums))
+		for k, v := range c.Enums {
+			vs := make(map[string]int64, len(v))
+			for name, n := range v {
+				vs[name] = n
+			}
+			es[k] = vs
+		}
+		c.Enums = es
+	}
+	if c.Exprs != nil {
+		es := make(map[string]string, len(c.Exprs))
+		for k, v := range c.Exprs {
+			es[k] = v
+		}
+		c.Exprs = es
+	}
+	if c.Presets != nil {
+		ps := make(map[string]string, len(c.Presets))
+		for k, v := range c.Presets {
+			ps[k] = v
+		}
+		c.Presets = ps
+	}
+	if c.SortExprs != nil {
+		es := make(map[string]string, len(c.SortExprs))
+		for k, v := range c.SortExprs {
+			es[k] = v
+		}
+		c.SortExprs = es
+	}
+	return c
 }
 
 // defaults sets the default configuration of Config.
@@ -149,13 +1027,59 @@ func (c *Config) defaults() error {
 	if c.Log == nil {
 		c.Log = log.Printf
 	}
+	if c.ReuseArgs && c.BindStyle == BindQuestion && c.Placeholder == nil {
+		return errors.New("rql: 'ReuseArgs' requires numbered or named placeholders")
+	}
+	if c.MaxArgs < 0 || c.InChunkSize < 0 {
+		return errors.New("rql: 'MaxArgs' and 'InChunkSize' must be greater than or equal to 0")
+	}
+	if c.MaxBranches < 0 {
+		return errors.New("rql: 'MaxBranches' must be greater than or equal to 0")
+	}
+	if c.MaxSQLLength < 0 {
+		return errors.New("rql: 'MaxSQLLength' must be greater than or equal to 0")
+	}
+	if c.CacheTTL < 0 {
+		return errors.New("rql: 'CacheTTL' must be greater than or equal to 0")
+	}
+	if c.TableName != "" && !tableName.MatchString(c.TableName) {
+		return errors.New("rql: 'TableName' must be a table name, optionally qualified with its schema")
+	}
+	if c.FloatPrecision < 0 {
+		return errors.New("rql: 'FloatPrecision' must be greater than or equal to 0")
+	}
+	if c.MaxInputBytes < 0 || c.MaxJSONDepth < 0 {
+		return errors.New("rql: 'MaxInputBytes' and 'MaxJSONDepth' must be greater than or equal to 0")
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	if err := c.Keys.defaults(); err != nil {
+		return err
+	}
 	if c.ColumnFn == nil {
 		c.ColumnFn = Column
 	}
 	defaultString(&c.TagName, DefaultTagName)
 	defaultString(&c.OpPrefix, DefaultOpPrefix)
 	defaultString(&c.FieldSep, DefaultFieldSep)
-	defaultInt(&c.DefaultLimit, DefaultLimit)
+	if c.SearchOp != "" && c.SearchOp != AND && c.SearchOp != OR {
+		return errors.New("rql: 'SearchOp' must be AND or OR")
+	}
+	defaultString(&c.SortAsc, DefaultSortAsc)
+	defaultString(&c.SortDesc, DefaultSortDesc)
+	if strings.ContainsAny(c.SortAsc, c.SortDesc) || !sortPrefix.MatchString(c.SortAsc+c.SortDesc) {
+		return errors.New("rql: 'SortAsc' and 'SortDesc' must be disjoint sets of punctuation characters")
+	}
+	if c.NoDefaultLimit && c.DefaultLimit != 0 {
+		return errors.New("rql: 'NoDefaultLimit' can not be used with 'DefaultLimit'")
+	}
+	if c.LimitMaxValue < 0 && c.LimitMaxValue != Unlimited {
+		return errors.New("rql: 'LimitMaxValue' must be greater than 0, or Unlimited")
+	}
+	if !c.NoDefaultLimit {
+		defaultInt(&c.DefaultLimit, DefaultLimit)
+	}
 	defaultInt(&c.LimitMaxValue, DefaultMaxLimit)
 	return nil
 }
@@ -0,0 +1,199 @@
+package rql
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned by DecodeCursor for tokens that are malformed, were
+// tampered with, or were issued for a different sort specification.
+var ErrInvalidCursor = errors.New("rql: invalid cursor")
+
+// EncodeCursor returns an opaque token that holds the given values of the last row in a page
+// (one value per sort field, in the sort order). The token is signed using the given key, and
+// it is bound to the sort specification (i.e. Params.Sort), so it can't be tampered with, or
+// reused with a differently-sorted query. For example:
+//
+//	last := users[len(users)-1]
+//	next, err := rql.EncodeCursor(key, params.Sort, last.Age, last.ID)
+//
+// Values are encoded as JSON. Therefore, when decoded, numbers are returned as json.Number
+// (in order to keep the precision of 64-bit integers), and times are returned as strings in
+// the RFC 3339 format. Parser.EncodeCursor formats times in the layouts of their fields.
+func EncodeCursor(key []byte, sort string, values ...interface{}) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("rql: cursor key must not be empty")
+	}
+	payload, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	mac := cursorMAC(key, sort, payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+// DecodeCursor verifies the given token using the given key and sort specification,
+// and returns the values that were passed to EncodeCursor.
+func DecodeCursor(key []byte, sort string, token string) ([]interface{}, error) {
+	i := strings.IndexByte(token, '.')
+	if len(key) == 0 || i == -1 {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(mac, cursorMAC(key, sort, payload)) {
+		return nil, ErrInvalidCursor
+	}
+	var values []interface{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return values, nil
+}
+
+// cursorMAC returns the signature of the given cursor payload and sort specification.
+func cursorMAC(key []byte, sort string, payload []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(sort))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// EncodeCursor returns a cursor token for the given params that holds the values of the last
+// (or the first, for the previous page) row in the page. The values should be given in the
+// order of the sort fields, and they are signed with Config.CursorKey. For example:
+//
+//	params, err := p.Parse([]byte(`{"sort": ["-age", "id"], "limit": 10}`))
+//	...
+//	last := users[len(users)-1]
+//	next, err := p.EncodeCursor(params, last.Age, last.ID)
+//
+// Note that the last sort field should be unique (e.g. the primary key), and that sort fields
+// must not be NULL, otherwise rows may be skipped between pages. The values of time fields
+// (time.Time) are formatted in the layout of their fields.
+func (p *Parser) EncodeCursor(pr *Params, values ...interface{}) (string, error) {
+	values = append([]interface{}(nil), values...)
+	p.mu.RLock()
+	ps := &parseState{Parser: p}
+	for i := 0; i < len(values) && i < len(pr.sort); i++ {
+		name, _ := p.sortToken(pr.sort[i])
+		f, _ := ps.lookupPath(name)
+		if t, ok := values[i].(time.Time); ok && f != nil && f.Layout != "" {
+			values[i] = t.Format(f.Layout)
+		}
+	}
+	p.mu.RUnlock()
+	return EncodeCursor(p.conf.CursorKey, pr.cursorSort, values...)
+}
+
+// cursor adds the keyset condition of the given cursor to the filter of the params.
+// For the "before" cursor, the sort order is reversed and the params are marked with
+// the Reverse flag.
+func (p *parseState) cursor(pr *Params, after, before string) {
+	expect(after == "" || before == "", CodeInvalidQuery, "", "after and before cursors can not be used together")
+	expect(len(p.conf.CursorKey) > 0, CodeInvalidQuery, "", "cursors are not enabled")
+	expect(len(pr.sort) > 0, CodeInvalidQuery, "", "cursors require a sort order")
+	token := after
+	if before != "" {
+		token = before
+	}
+	values, err := DecodeCursor(p.conf.CursorKey, pr.cursorSort, token)
+	expect(err == nil, CodeInvalidQuery, "", "invalid cursor")
+	expect(len(values) == len(pr.sort), CodeInvalidQuery, "", "invalid cursor: expect %d values, got %d", len(pr.sort), len(values))
+	// the keyset condition of sort (a, -b) after (x, y) is: a > x OR (a = x AND b < y).
+	keyset := &expr{op: OR, paren: true, implicit: true}
+	eqs := make([]*expr, 0, len(pr.sort))
+	for i, s := range pr.sort {
+		name, d := p.sortToken(s)
+		desc := d == "desc"
+		f, r := p.lookupPath(name)
+		expect(f != nil, CodeInvalidQuery, name, "cursors can not be used with sort field %q", name)
+		v := values[i]
+		if n, ok := v.(json.Number); ok {
+			v, _ = n.Float64()
+		}
+		must(f.ValidateFn(v), name, "invalid cursor value for field %q", name)
+		exact := exactInt(f, values[i], v)
+		op := GT
+		if desc != (before != "") {
+			op = LT
+		}
+		term := &expr{op: AND, paren: true}
+		for _, eq := range eqs {
+			term.add(eq)
+		}
+		term.add(p.cursorPredicate(f, r, op, v, exact))
+		keyset.add(term)
+		eqs = append(eqs, p.cursorPredicate(f, r, EQ, v, exact))
+	}
+	pr.filter.add(keyset)
+	if before != "" {
+		reversed := make([]string, len(pr.sort))
+		for i, s := range pr.sort {
+			if name, d := p.sortToken(s); d == "desc" {
+				reversed[i] = name
+			} else {
+				reversed[i] = p.conf.SortDesc[:1] + name
+			}
+		}
+		pr.Sort, _ = p.sort(reversed)
+		pr.Reverse = true
+	}
+}
+
+// exactInt returns the exact value of an integer field from its json.Number, or nil if the value is
+// not a number or the field is not an integer. Integers are converted from their text, because 64-bit
+// keys (e.g. snowflake IDs) lose their precision in float64. v is the validated float64 value.
+func exactInt(f *field, n, v interface{}) interface{} {
+	s, ok := n.(json.Number)
+	if !ok || f.Type == nil {
+		return nil
+	}
+	var exact reflect.Value
+	switch k := f.Type.Kind(); {
+	case k >= reflect.Int && k <= reflect.Int64:
+		i, err := strconv.ParseInt(s.String(), 10, f.Type.Bits())
+		if err != nil {
+			return nil
+		}
+		exact = reflect.ValueOf(i)
+	case k >= reflect.Uint && k <= reflect.Uintptr:
+		u, err := strconv.ParseUint(s.String(), 10, f.Type.Bits())
+		if err != nil {
+			return nil
+		}
+		exact = reflect.ValueOf(u)
+	default:
+		return nil
+	}
+	// the exact value is used only if the field is converted to a Go integer (e.g. int64),
+	// and not by a custom converter.
+	t := reflect.TypeOf(f.CovertFn(v))
+	if t == nil || t.PkgPath() != "" || t.Kind() < reflect.Int || t.Kind() > reflect.Uintptr {
+		return nil
+	}
+	return exact.Convert(t).Interface()
+}
+
+// cursorPredicate is like predicate, but it uses the exact value of integer fields, if it is set.
+func (p *parseState) cursorPredicate(f *field, r *relation, op Op, v, exact interface{}) *expr {
+	e := p.predicate(f, r, op, v)
+	if exact != nil {
+		e.value = exact
+	}
+	return e
+}
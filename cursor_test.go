@@ -0,0 +1,197 @@
+package rql
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	key := []byte("secret")
+	token, err := EncodeCursor(key, "age desc, id", 30, "a8m")
+	if err != nil {
+		t.Fatalf("failed to encode cursor: %v", err)
+	}
+	values, err := DecodeCursor(key, "age desc, id", token)
+	if err != nil {
+		t.Fatalf("failed to decode cursor: %v", err)
+	}
+	if want := []interface{}{json.Number("30"), "a8m"}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("values:\n\tgot: %v\n\twant %v", values, want)
+	}
+	i := strings.IndexByte(token, '.')
+	tampered, err := EncodeCursor([]byte("other"), "age desc, id", 0, "a8m")
+	if err != nil {
+		t.Fatalf("failed to encode cursor: %v", err)
+	}
+	tests := []struct {
+		name  string
+		key   []byte
+		sort  string
+		token string
+	}{
+		{name: "sort", key: key, sort: "age, id", token: token},
+		{name: "key", key: []byte("other"), sort: "age desc, id", token: token},
+		{name: "empty key", sort: "age desc, id", token: token},
+		{name: "payload", key: key, sort: "age desc, id", token: tampered[:strings.IndexByte(tampered, '.')] + token[i:]},
+		{name: "signature", key: key, sort: "age desc, id", token: token[:i]},
+		{name: "encoding", key: key, sort: "age desc, id", token: "!" + token},
+		{name: "empty", key: key, sort: "age desc, id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.key, tt.sort, tt.token); err != ErrInvalidCursor {
+				t.Fatalf("expect ErrInvalidCursor, got: %v", err)
+			}
+		})
+	}
+	if _, err := EncodeCursor(nil, "id", 1); err == nil {
+		t.Fatal("expect error for empty key")
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			ID   int    `rql:"filter,sort"`
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter,sort"`
+		}),
+		CursorKey: []byte("secret"),
+		Log:       t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	first, err := p.Parse([]byte(`{"sort": ["-age", "id"], "filter": {"name": "a8m"}}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	token, err := p.EncodeCursor(first, 30, 7)
+	if err != nil {
+		t.Fatalf("failed to encode cursor: %v", err)
+	}
+	tests := []struct {
+		name    string
+		input   string
+		wantOut *Params
+		reverse bool
+		wantErr bool
+	}{
+		{
+			name:  "after",
+			input: `{"sort": ["-age", "id"], "filter": {"name": "a8m"}, "after": "` + token + `"}`,
+			wantOut: &Params{
+				Limit:      25,
+				Sort:       "age desc, id",
+				FilterExp:  "name = ? AND (age < ? OR (age = ? AND id > ?))",
+				FilterArgs: []interface{}{"a8m", 30, 30, 7},
+			},
+		},
+		{
+			name:  "before",
+			input: `{"sort": ["-age", "id"], "filter": {"name": "a8m"}, "before": "` + token + `"}`,
+			wantOut: &Params{
+				Limit:      25,
+				Sort:       "age, id desc",
+				FilterExp:  "name = ? AND (age > ? OR (age = ? AND id < ?))",
+				FilterArgs: []interface{}{"a8m", 30, 30, 7},
+			},
+			reverse: true,
+		},
+		{
+			name:    "different sort",
+			input:   `{"sort": ["age", "id"], "after": "` + token + `"}`,
+			wantErr: true,
+		},
+		{
+			name:    "after and before",
+			input:   `{"sort": ["-age", "id"], "after": "` + token + `", "before": "` + token + `"}`,
+			wantErr: true,
+		},
+		{
+			name:    "tampered",
+			input:   `{"sort": ["-age", "id"], "after": "x` + token + `"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := p.Parse([]byte(tt.input))
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("want: %v\ngot:%v\nerr: %v", tt.wantErr, err != nil, err)
+			}
+			if err != nil {
+				return
+			}
+			assertParams(t, out, tt.wantOut)
+			if out.Reverse != tt.reverse {
+				t.Fatalf("reverse: got: %v want %v", out.Reverse, tt.reverse)
+			}
+			// cursors of the next pages are bound to the original sort order.
+			if next, err := p.EncodeCursor(out, 20, 3); err != nil || next == token {
+				t.Fatalf("unexpected cursor: %q, %v", next, err)
+			} else if _, err := p.Parse([]byte(`{"sort": ["-age", "id"], "after": "` + next + `"}`)); err != nil {
+				t.Fatalf("failed to parse next page: %v", err)
+			}
+		})
+	}
+	// the cursor is taken from the first document that sets it in ParseAll.
+	out, err := p.ParseAll(
+		[]byte(`{"filter": {"name": "a8m"}}`),
+		[]byte(`{"sort": ["-age", "id"], "before": "`+token+`"}`),
+		[]byte(`{"after": "`+token+`"}`),
+	)
+	if err != nil {
+		t.Fatalf("failed to parse all: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		Sort:       "age, id desc",
+		FilterExp:  "name = ? AND (age > ? OR (age = ? AND id < ?))",
+		FilterArgs: []interface{}{"a8m", 30, 30, 7},
+	})
+	if !out.Reverse {
+		t.Fatal("expect the params of the before cursor to be reversed")
+	}
+	noKey := MustNewParser(Config{Model: new(struct {
+		ID int `rql:"filter,sort"`
+	})})
+	if _, err := noKey.Parse([]byte(`{"sort": ["id"], "after": "` + token + `"}`)); err == nil {
+		t.Fatal("expect error for parser without cursor key")
+	}
+}
+
+func TestCursorValues(t *testing.T) {
+	p := MustNewParser(Config{
+		Model: new(struct {
+			ID  int64     `rql:"filter,sort"`
+			Day time.Time `rql:"filter,sort,layout=2006-01-02"`
+		}),
+		CursorKey: []byte("secret"),
+		Log:       t.Logf,
+	})
+	first, err := p.Parse([]byte(`{"sort": ["day", "id"]}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	// 2^53 + 1 is not representable in float64.
+	id := int64(1)<<53 + 1
+	token, err := p.EncodeCursor(first, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), id)
+	if err != nil {
+		t.Fatalf("failed to encode cursor: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"sort": ["day", "id"], "after": "` + token + `"}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	assertParams(t, out, &Params{
+		Limit:      25,
+		Sort:       "day, id",
+		FilterExp:  "(day > ? OR (day = ? AND id > ?))",
+		FilterArgs: []interface{}{day, day, id},
+	})
+}
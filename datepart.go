@@ -0,0 +1,96 @@
+package rql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// datePartRange holds the valid values of the date-part operators. The year is not limited.
+var datePartRange = map[Op][2]int{
+	MONTH: {1, 12},
+	WEEK:  {1, 53},
+	DAY:   {1, 31},
+	DOW:   {0, 6},
+	HOUR:  {0, 23},
+}
+
+// dateParts adds the date-part operators to the given time field. For example:
+//
+//	{"created_at": {"$dow": {"$in": [0, 6]}}}   => "EXTRACT(DOW FROM created_at) IN (?, ?)"
+//	{"created_at": {"$year": 2024}}             => "EXTRACT(YEAR FROM created_at) = ?"
+//
+// The operators are not added in dialects without date functions (i.e. CQL), and they are
+// rejected as unsupported operators of the field.
+func (p *Parser) dateParts(f *field) {
+	if _, ok := datePart(p.conf.Dialect, YEAR, ""); !ok {
+		return
+	}
+	f.parts = make(map[Op]*field)
+	for _, op := range []Op{YEAR, MONTH, WEEK, DAY, DOW, HOUR} {
+		pf := &field{
+			Name:       f.Name,
+			Filterable: true,
+			FilterOps:  make(map[string]bool),
+			ValidateFn: validatePart(op),
+			CovertFn:   convertInt,
+			Type:       reflect.TypeOf(0),
+			partOf:     f,
+			part:       op,
+		}
+		for _, op := range []Op{EQ, NEQ, LT, LTE, GT, GTE, IN, NIN} {
+			pf.FilterOps[p.op(op)] = true
+		}
+		pf.meta = p.meta(pf)
+		pf.meta.Column, _ = datePart(p.conf.Dialect, op, p.fieldColumn(f))
+		f.parts[op] = pf
+		f.FilterOps[p.op(op)] = true
+	}
+}
+
+// validatePart returns the validation function of the given date-part operator.
+func validatePart(op Op) func(interface{}) error {
+	return func(v interface{}) error {
+		if err := validateInt(v); err != nil {
+			return err
+		}
+		if r, ok := datePartRange[op]; ok && (v.(float64) < float64(r[0]) || v.(float64) > float64(r[1])) {
+			return fmt.Errorf("%s must be between %d and %d", op, r[0], r[1])
+		}
+		return nil
+	}
+}
+
+// datePart returns the SQL expression that extracts the given part from the column, and
+// reports if the dialect supports it. The days of the week start from 0 (Sunday), and the
+// weeks are ISO weeks in all dialects. For example, the "$dow" operator is rendered as:
+//
+//	SQL (PostgreSQL)	EXTRACT(DOW FROM created_at)
+//	Spanner			(EXTRACT(DAYOFWEEK FROM created_at) - 1)
+//	MySQL			(DAYOFWEEK(created_at) - 1)
+func datePart(d Dialect, op Op, column string) (string, bool) {
+	switch d {
+	case DialectSQL:
+		return "EXTRACT(" + strings.ToUpper(string(op)) + " FROM " + column + ")", true
+	case DialectSpanner:
+		switch op {
+		case DOW:
+			return "(EXTRACT(DAYOFWEEK FROM " + column + ") - 1)", true
+		case WEEK:
+			return "EXTRACT(ISOWEEK FROM " + column + ")", true
+		}
+		return "EXTRACT(" + strings.ToUpper(string(op)) + " FROM " + column + ")", true
+	case DialectMySQL:
+		switch op {
+		case DOW:
+			return "(DAYOFWEEK(" + column + ") - 1)", true
+		case WEEK:
+			// mode 3 of WEEK is the ISO week, that starts on Monday.
+			return "WEEK(" + column + ", 3)", true
+		case DAY:
+			return "DAYOFMONTH(" + column + ")", true
+		}
+		return strings.ToUpper(string(op)) + "(" + column + ")", true
+	}
+	return "", false
+}
@@ -0,0 +1,130 @@
+package rql
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestDatePart(t *testing.T) {
+	type Order struct {
+		CreatedAt time.Time `rql:"filter"`
+		Day       time.Time `rql:"filter,layout=2006-01-02"`
+	}
+	p := MustNewParser(Config{
+		Model: Order{},
+		Log:   t.Logf,
+	})
+	tests := []struct {
+		input string
+		want  *Params
+	}{
+		{
+			input: `{"filter": {"created_at": {"$dow": {"$in": [0, 6]}}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "EXTRACT(DOW FROM created_at) IN (?, ?)",
+				FilterArgs: []interface{}{0, 6},
+			},
+		},
+		{
+			input: `{"filter": {"created_at": {"$year": 2024, "$month": {"$gte": 6}}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "(EXTRACT(YEAR FROM created_at) = ? AND EXTRACT(MONTH FROM created_at) >= ?)",
+				FilterArgs: []interface{}{2024, 6},
+			},
+		},
+		{
+			input: `{"filter": {"$or": [{"day": {"$week": 1}}, {"day": {"$week": 53}}]}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "(EXTRACT(WEEK FROM day) = ? OR EXTRACT(WEEK FROM day) = ?)",
+				FilterArgs: []interface{}{1, 53},
+			},
+		},
+		{
+			input: `{"filter": {"created_at": {"$gte": "2024-01-01T00:00:00Z", "$hour": {"$lt": 9}}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "(created_at >= ? AND EXTRACT(HOUR FROM created_at) < ?)",
+				FilterArgs: []interface{}{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 9},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			out, err := p.Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertParams(t, out, tt.want)
+			b, err := p.Query(out).MarshalJSON()
+			if err != nil {
+				t.Fatalf("failed to marshal query: %v", err)
+			}
+			again, err := p.Parse(b)
+			if err != nil {
+				t.Fatalf("failed to parse formatted query %s: %v", b, err)
+			}
+			assertParams(t, again, tt.want)
+		})
+	}
+	for _, input := range []string{
+		`{"filter": {"created_at": {"$dow": 7}}}`,
+		`{"filter": {"created_at": {"$month": 0}}}`,
+		`{"filter": {"created_at": {"$day": 1.5}}}`,
+		`{"filter": {"created_at": {"$year": "2024"}}}`,
+		`{"filter": {"created_at": {"$dow": {"$like": 1}}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Errorf("expected error for input: %s", input)
+		}
+	}
+	out, err := p.Parse([]byte(`{"filter": {"created_at": {"$year": {"$gt": 2025, "$lt": 2020}}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u := out.Usage(); len(u.Filter["created_at"]) != 1 || u.Filter["created_at"][0] != YEAR {
+		t.Errorf("unexpected usage of date-part: %v", u.Filter)
+	}
+
+	spanner := MustNewParser(Config{
+		Model:   Order{},
+		Dialect: DialectSpanner,
+	})
+	out, err = spanner.Parse([]byte(`{"filter": {"$and": [{"created_at": {"$dow": 0}}, {"created_at": {"$week": 10}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "((EXTRACT(DAYOFWEEK FROM created_at) - 1) = @p1 AND EXTRACT(ISOWEEK FROM created_at) = @p2)",
+		FilterArgs: []interface{}{sql.Named("p1", 0), sql.Named("p2", 10)},
+	})
+
+	mysql := MustNewParser(Config{
+		Model:   Order{},
+		Dialect: DialectMySQL,
+	})
+	out, err = mysql.Parse([]byte(`{"filter": {"created_at": {"$dow": {"$in": [0, 6]}, "$week": 10, "$day": 1, "$year": 2024}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(DAYOFMONTH(created_at) = ? AND (DAYOFWEEK(created_at) - 1) IN (?, ?) AND WEEK(created_at, 3) = ? AND YEAR(created_at) = ?)",
+		FilterArgs: []interface{}{1, 0, 6, 10, 2024},
+	})
+
+	cql := MustNewParser(Config{
+		Model: new(struct {
+			TenantID  string    `rql:"filter,partition"`
+			CreatedAt time.Time `rql:"filter,clustering"`
+		}),
+		Dialect: DialectCQL,
+	})
+	if _, err := cql.Parse([]byte(`{"filter": {"tenant_id": "a8m", "created_at": {"$dow": 0}}}`)); err == nil {
+		t.Fatal("expect date-part operators to be rejected by the CQL dialect")
+	}
+}
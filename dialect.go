@@ -0,0 +1,159 @@
+package rql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Dialect is the database dialect of the parser.
+type Dialect int
+
+// Dialects that are supported by the parser.
+const (
+	// DialectSQL is the default dialect, and it supports all features of the parser.
+	DialectSQL Dialect = iota
+	// DialectCQL is the Cassandra Query Language dialect. Fields are marked as part of
+	// the primary key using the "partition" and "clustering" options:
+	//
+	//	type Event struct {
+	//		TenantID  string    `rql:"filter,partition"`
+	//		CreatedAt time.Time `rql:"filter,sort,clustering"`
+	//		Kind      string    `rql:"filter"`
+	//	}
+	//
+	// Partition key fields accept only equality checks ($eq and $in), and clustering fields
+	// accept range checks as well. Sorting is allowed only by clustering fields, and $or,
+	// $neq, $like, $nin and relations are not supported. Filtering by regular fields requires
+	// the AllowFiltering option, and sets Params.AllowFiltering.
+	DialectCQL
+	// DialectSpanner is the Google Cloud Spanner dialect. It uses named parameters (@p1, @p2, ...),
+	// and binds the values of $in and $nin as one ARRAY parameter ("col IN UNNEST(@p1)"). Index
+	// hints can be passed through using the ParseOptions.ForceIndex option.
+	DialectSpanner
+	// DialectMySQL is the MySQL dialect. It is like DialectSQL, but the date-part operators
+	// are rendered using the date functions of MySQL (e.g. "(DAYOFWEEK(created_at) - 1)"),
+	// and the PostgreSQL features (range fields and distinct_on) are not supported.
+	DialectMySQL
+)
+
+// quoteColumn quotes each part of a qualified column (e.g. "orders.total") with the
+// identifier quotes of the dialect. Parts that are already quoted are kept as is.
+func quoteColumn(d Dialect, column string) string {
+	q := `"`
+	if d == DialectMySQL || d == DialectSpanner {
+		q = "`"
+	}
+	parts := strings.Split(column, ".")
+	for i, s := range parts {
+		if len(s) < 2 || !strings.HasPrefix(s, q) || !strings.HasSuffix(s, q) {
+			parts[i] = q + strings.ReplaceAll(s, q, q+q) + q
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// KeyKind is the role of a field in the primary key of a CQL table.
+type KeyKind int
+
+// Key kinds.
+const (
+	RegularColumn KeyKind = iota
+	PartitionKey
+	ClusteringKey
+)
+
+// cqlOps are the operators that are supported by CQL for each kind of field.
+var cqlOps = map[KeyKind][]Op{
+	PartitionKey:  {EQ, IN},
+	ClusteringKey: {EQ, IN, LT, LTE, GT, GTE},
+	RegularColumn: {EQ, LT, LTE, GT, GTE},
+}
+
+// initDialect applies the restrictions of the configured dialect on the parser fields.
+func (p *Parser) initDialect() error {
+	switch p.conf.Dialect {
+	case DialectSQL, DialectMySQL:
+		return nil
+	case DialectSpanner:
+		switch p.conf.BindStyle {
+		case BindQuestion:
+			p.conf.BindStyle = BindAt
+		case BindAt:
+		default:
+			return errors.New("rql: the Spanner dialect supports only the BindAt style")
+		}
+		return nil
+	case DialectCQL:
+	default:
+		return fmt.Errorf("rql: unknown dialect %d", p.conf.Dialect)
+	}
+	if len(p.conf.Relations) > 0 {
+		return errors.New("rql: relations are not supported by the CQL dialect")
+	}
+	if p.conf.BindStyle != BindQuestion && p.conf.Placeholder == nil {
+		return errors.New("rql: the CQL dialect supports only the BindQuestion style")
+	}
+	for name, f := range p.fields {
+		// aliases share the field with their canonical name.
+		if name == f.Name {
+			if r := p.cqlField(f); r != "" {
+				p.skipped = append(p.skipped, Skipped{Field: f.Name, Reason: r})
+			}
+		}
+	}
+	return nil
+}
+
+// cqlField restricts the operators and the sorting of the given field to what CQL supports.
+// It returns the reason, if the sort option of the field was ignored.
+func (p *Parser) cqlField(f *field) (skipped string) {
+	ops := make(map[string]bool)
+	for _, op := range cqlOps[f.Key] {
+		if f.FilterOps[p.op(op)] {
+			ops[p.op(op)] = true
+		}
+	}
+	f.FilterOps = ops
+	if f.Sortable && f.Key != ClusteringKey {
+		p.conf.Log("field %q is not sortable in the CQL dialect, because it is not a clustering column", f.Name)
+		f.Sortable = false
+		skipped = "sort option of a field that is not a clustering column"
+	}
+	f.meta = p.meta(f)
+	return skipped
+}
+
+// array returns the values of a list node as a typed slice (e.g. []string), for
+// binding them as one ARRAY parameter. Integers are bound as []int64, which is the
+// only integer type Spanner supports.
+func (e *expr) array() interface{} {
+	vs := e.value.([]interface{})
+	if len(vs) == 0 {
+		return vs
+	}
+	t := reflect.TypeOf(vs[0])
+	if t.Kind() == reflect.Int {
+		t = reflect.TypeOf(int64(0))
+	}
+	a := reflect.MakeSlice(reflect.SliceOf(t), len(vs), len(vs))
+	for i, v := range vs {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != t.Kind() && rv.Kind() != reflect.Int {
+			return vs
+		}
+		a.Index(i).Set(rv.Convert(t))
+	}
+	return a.Interface()
+}
+
+// forceIndex returns the Spanner table hint for the given index.
+func forceIndex(index string) string {
+	expect(identifier.MatchString(index), CodeInvalidQuery, "", "invalid index name %q", index)
+	return "@{FORCE_INDEX=" + index + "}"
+}
+
+// identifier matches valid index names.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
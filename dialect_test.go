@@ -0,0 +1,139 @@
+package rql
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestDialectCQL(t *testing.T) {
+	type Event struct {
+		TenantID  string    `rql:"filter,partition"`
+		CreatedAt time.Time `rql:"filter,sort,clustering"`
+		Kind      string    `rql:"filter,sort"`
+	}
+	tests := []struct {
+		name    string
+		conf    Config
+		input   string
+		wantOut *Params
+		wantErr bool
+	}{
+		{
+			name:  "keys",
+			input: `{"filter": {"tenant_id": {"$in": ["a", "b"]}, "created_at": {"$gte": "2020-01-01T00:00:00Z"}}, "sort": ["-created_at"]}`,
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "tenant_id IN (?, ?) AND created_at >= ?",
+				FilterArgs: []interface{}{"a", "b", mustParseTime(time.RFC3339, "2020-01-01T00:00:00Z")},
+				Sort:       "created_at desc",
+			},
+		},
+		{
+			name:    "range on partition key",
+			input:   `{"filter": {"tenant_id": {"$gt": "a"}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "like",
+			input:   `{"filter": {"tenant_id": {"$like": "a%"}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "or",
+			input:   `{"filter": {"$or": [{"tenant_id": "a"}, {"tenant_id": "b"}]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "sort by regular column",
+			input:   `{"sort": ["kind"]}`,
+			wantErr: true,
+		},
+		{
+			name:    "regular column",
+			input:   `{"filter": {"tenant_id": "a", "kind": "click"}}`,
+			wantErr: true,
+		},
+		{
+			name:  "allow filtering",
+			conf:  Config{AllowFiltering: true},
+			input: `{"filter": {"tenant_id": "a", "kind": "click"}}`,
+			wantOut: &Params{
+				Limit:          25,
+				FilterExp:      "tenant_id = ? AND kind = ?",
+				FilterArgs:     []interface{}{"a", "click"},
+				AllowFiltering: true,
+			},
+		},
+		{
+			name:    "bind style",
+			conf:    Config{BindStyle: BindDollar},
+			input:   `{}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.conf.Model = Event{}
+			tt.conf.Dialect = DialectCQL
+			tt.conf.Log = t.Logf
+			p, err := NewParser(tt.conf)
+			if err == nil {
+				var out *Params
+				out, err = p.Parse([]byte(tt.input))
+				if err == nil {
+					assertParams(t, out, tt.wantOut)
+					if out.AllowFiltering != tt.wantOut.AllowFiltering {
+						t.Fatalf("allow filtering: got %v", out.AllowFiltering)
+					}
+				}
+			}
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("want error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDialectSpanner(t *testing.T) {
+	type Singer struct {
+		ID   int64  `rql:"filter"`
+		Name string `rql:"filter,sort"`
+	}
+	p, err := NewParser(Config{
+		Model:   Singer{},
+		Dialect: DialectSpanner,
+		Log:     t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.ParseQueryWithOptions(&Query{
+		Filter: map[string]interface{}{
+			"$and": []interface{}{
+				map[string]interface{}{"id": map[string]interface{}{"$in": []interface{}{1.0, 2.0}}},
+				map[string]interface{}{"name": map[string]interface{}{"$nin": []interface{}{"a", "b"}}},
+			},
+		},
+	}, ParseOptions{ForceIndex: "singers_by_name"})
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(id IN UNNEST(@p1) AND name NOT IN UNNEST(@p2))",
+		FilterArgs: []interface{}{sql.Named("p1", []int64{1, 2}), sql.Named("p2", []string{"a", "b"})},
+		TableHint:  "@{FORCE_INDEX=singers_by_name}",
+	})
+	for _, opts := range []ParseOptions{{ForceIndex: "idx; DROP TABLE singers"}} {
+		if _, err := p.ParseQueryWithOptions(&Query{}, opts); err == nil {
+			t.Fatalf("expect error for options: %+v", opts)
+		}
+	}
+	if _, err := NewParser(Config{Model: Singer{}, Dialect: DialectSpanner, BindStyle: BindDollar}); err == nil {
+		t.Fatal("expect error for unsupported bind style")
+	}
+	if _, err := MustNewParser(Config{Model: Singer{}}).ParseQueryWithOptions(&Query{}, ParseOptions{ForceIndex: "idx"}); err == nil {
+		t.Fatal("expect error for index hint in the default dialect")
+	}
+}
@@ -0,0 +1,91 @@
+package rql
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Equal reports if the two params are structurally equal. Unlike comparing their fields,
+// the order of the terms in the filter conjunctions (AND and OR) is ignored, because it
+// follows the order of the keys in the query. Therefore, it can be used for implementing
+// caching layers or idempotency checks on top of parsed queries. See Params.Diff.
+func (p *Params) Equal(other *Params) bool {
+	return len(p.Diff(other)) == 0
+}
+
+// Diff returns the differences between the two params, one line for each difference.
+// Filter terms that exist only in one of the params are prefixed with "-" (only in p)
+// or "+" (only in other). For example:
+//
+//	limit: 25 => 10
+//	filter: - age > 10
+//	filter: + name = 'a8m'
+//
+// Like Canonical, the filter values are written inline, and the order of the terms in the
+// filter conjunctions is ignored. An empty result means that the params are equal.
+func (p *Params) Diff(other *Params) []string {
+	var diff []string
+	add := func(name, a, b string) {
+		if a != b {
+			diff = append(diff, name+": "+a+" => "+b)
+		}
+	}
+	add("distinct on", strconv.Quote(p.DistinctOn), strconv.Quote(other.DistinctOn))
+	add("select", strconv.Quote(p.Select), strconv.Quote(other.Select))
+	add("joins", fmt.Sprint(p.Joins), fmt.Sprint(other.Joins))
+	add("table hint", strconv.Quote(p.TableHint), strconv.Quote(other.TableHint))
+	diff = append(diff, diffFilter(p, other)...)
+	add("unsatisfiable", strconv.FormatBool(p.Unsatisfiable), strconv.FormatBool(other.Unsatisfiable))
+	add("allow filtering", strconv.FormatBool(p.AllowFiltering), strconv.FormatBool(other.AllowFiltering))
+	add("sort", strconv.Quote(p.Sort), strconv.Quote(other.Sort))
+	add("reverse", strconv.FormatBool(p.Reverse), strconv.FormatBool(other.Reverse))
+	add("limit", strconv.Itoa(p.Limit), strconv.Itoa(other.Limit))
+	add("offset", strconv.Itoa(p.Offset), strconv.Itoa(other.Offset))
+	return diff
+}
+
+// diffFilter returns the differences between the filters of the two params. The top-level
+// terms of the filters are compared as sets. Params that were not created by the parser are
+// compared by their filter expression and arguments.
+func diffFilter(a, b *Params) []string {
+	if a.filter == nil || b.filter == nil {
+		if a.FilterExp != b.FilterExp || !reflect.DeepEqual(a.FilterArgs, b.FilterArgs) {
+			return []string{fmt.Sprintf("filter: %q %v => %q %v", a.FilterExp, a.FilterArgs, b.FilterExp, b.FilterArgs)}
+		}
+		return nil
+	}
+	ta, tb := filterTerms(a.filter), filterTerms(b.filter)
+	return append(missing("filter: - ", ta, tb), missing("filter: + ", tb, ta)...)
+}
+
+// missing returns the terms of a that are missing in b, sorted and prefixed with the given prefix.
+func missing(prefix string, a, b map[string]int) []string {
+	var terms []string
+	for t, n := range a {
+		for i := b[t]; i < n; i++ {
+			terms = append(terms, prefix+t)
+		}
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// filterTerms returns the canonical representations of the top-level terms of the given
+// filter, and the number of their occurrences.
+func filterTerms(e *expr) map[string]int {
+	terms := make(map[string]int)
+	if e.empty() {
+		return terms
+	}
+	e = sorted(e)
+	if !e.group() || e.op != AND {
+		terms[canonical(e)]++
+		return terms
+	}
+	for _, c := range e.children {
+		terms[canonical(c)]++
+	}
+	return terms
+}
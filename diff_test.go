@@ -0,0 +1,50 @@
+package rql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParamsDiff(t *testing.T) {
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter"`
+			City string `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	parse := func(s string) *Params {
+		out, err := p.Parse([]byte(s))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return out
+	}
+	a := parse(`{"filter": {"age": {"$gt": 10}, "$or": [{"city": "TLV"}, {"city": "NYC"}]}, "sort": ["age"]}`)
+	b := parse(`{"filter": {"$or": [{"city": "NYC"}, {"city": "TLV"}], "age": {"$gt": 10}}, "sort": ["age"]}`)
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Fatalf("expect params to be equal: %v", a.Diff(b))
+	}
+	c := parse(`{"filter": {"$or": [{"city": "NYC"}, {"city": "TLV"}], "name": "a8m"}, "limit": 10}`)
+	want := []string{
+		"filter: - age > 10",
+		"filter: + name = 'a8m'",
+		`sort: "age" => ""`,
+		"limit: 25 => 10",
+	}
+	if got := a.Diff(c); !reflect.DeepEqual(got, want) {
+		t.Fatalf("diff:\n\tgot: %q\n\twant %q", got, want)
+	}
+	if a.Equal(parse(`{"filter": {"age": {"$gt": 11}, "$or": [{"city": "TLV"}, {"city": "NYC"}]}, "sort": ["age"]}`)) {
+		t.Fatal("expect params with different values to be different")
+	}
+	// params that were not created by the parser are compared by their expression and arguments.
+	d := &Params{Limit: 25, Sort: "age", FilterExp: a.FilterExp, FilterArgs: a.FilterArgs}
+	if !d.Equal(a) {
+		t.Fatalf("unexpected diff: %v", d.Diff(a))
+	}
+	if d.Equal(&Params{Limit: 25, Sort: "age", FilterExp: a.FilterExp}) {
+		t.Fatal("expect params with different arguments to be different")
+	}
+}
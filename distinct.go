@@ -0,0 +1,34 @@
+package rql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// distinctOn builds the DISTINCT ON clause of the given fields. PostgreSQL requires the
+// DISTINCT ON expressions to match the leftmost ORDER BY expressions (in any order), so
+// the sort of the params must start with the same fields.
+func (p *parseState) distinctOn(pr *Params, fields []string) {
+	expect(p.conf.Dialect == DialectSQL, CodeInvalidQuery, "", "distinct_on is supported only by the SQL dialect")
+	cols := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, k := range fields {
+		f, r := p.lookupPath(k)
+		if f == nil {
+			p.unknownField(k, "unrecognized key "+strconv.Quote(k)+" for distinct_on", false, sortableField)
+		}
+		expectStr(f.Sortable, CodeNotSortable, k, "field %q is not sortable", k)
+		c := p.column(f, r)
+		expectStr(!seen[c], CodeInvalidQuery, k, "duplicate distinct_on field %q", k)
+		seen[c] = true
+		cols = append(cols, c)
+	}
+	expect(len(pr.sort) >= len(cols), CodeInvalidQuery, "", "sort must start with the distinct_on fields")
+	for _, s := range pr.sort[:len(cols)] {
+		name, _ := p.sortToken(s)
+		f, r := p.lookupPath(name)
+		expect(f != nil && seen[p.column(f, r)], CodeInvalidQuery, "", "sort must start with the distinct_on fields")
+	}
+	pr.DistinctOn = "DISTINCT ON (" + strings.Join(cols, ", ") + ")"
+	pr.distinctOn = fields
+}
@@ -0,0 +1,62 @@
+// Package entrql generates rql models from ent schemas (entgo.io/ent), in order to keep the
+// rql and the ent schemas from drifting. Fields are exposed to rql using annotations:
+//
+//	func (User) Fields() []ent.Field {
+//		return []ent.Field{
+//			field.String("name").
+//				Annotations(entrql.Filterable(), entrql.Sortable()),
+//			field.Time("created_at").
+//				Annotations(entrql.Sortable()),
+//		}
+//	}
+//
+// The rql template is registered in the ent codegen configuration (entc.go):
+//
+//	err := entc.Generate("./schema", &gen.Config{
+//		Templates: []*gen.Template{
+//			gen.MustParse(gen.NewTemplate("rql").ParseFS(entrql.Templates, "template/rql.tmpl")),
+//		},
+//	})
+//
+// For each schema, the generated rql.go file contains a model with the annotated fields, and
+// a constructor of its parser. For example:
+//
+//	type UserRQL struct {
+//		Name      string    `rql:"filter,sort"`
+//		CreatedAt time.Time `rql:"sort"`
+//	}
+//
+//	func NewUserParser(c rql.Config) (*rql.Parser, error)
+//
+// The package does not depend on ent. Its annotations implement the schema.Annotation interface
+// of ent, and they are passed to the template using their names.
+package entrql
+
+import "embed"
+
+// Templates holds the codegen templates of entrql.
+//
+//go:embed template/*.tmpl
+var Templates embed.FS
+
+// FilterAnnotation marks an ent field as filterable. See Filterable.
+type FilterAnnotation struct {
+	Filter bool `json:"filter"`
+}
+
+// Name implements the schema.Annotation interface of ent.
+func (FilterAnnotation) Name() string { return "RQLFilter" }
+
+// SortAnnotation marks an ent field as sortable. See Sortable.
+type SortAnnotation struct {
+	Sort bool `json:"sort"`
+}
+
+// Name implements the schema.Annotation interface of ent.
+func (SortAnnotation) Name() string { return "RQLSort" }
+
+// Filterable returns an annotation that exposes the ent field to rql filters.
+func Filterable() FilterAnnotation { return FilterAnnotation{Filter: true} }
+
+// Sortable returns an annotation that exposes the ent field to rql sorting.
+func Sortable() SortAnnotation { return SortAnnotation{Sort: true} }
@@ -0,0 +1,108 @@
+package entrql
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/format"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// The fake types below mimic the parts of the ent codegen graph (gen.Graph) that are
+// used by the template.
+type (
+	graph struct {
+		Config struct{ Package string }
+		Nodes  []*node
+	}
+	node struct {
+		Name   string
+		ID     *field
+		Fields []*field
+	}
+	field struct {
+		Name        string
+		Type        string
+		Enum        bool
+		Annotations map[string]interface{}
+	}
+)
+
+func (f *field) StructField() string {
+	parts := strings.Split(f.Name, "_")
+	for i, p := range parts {
+		if p == "id" {
+			parts[i] = "ID"
+		} else {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func (f *field) IsEnum() bool { return f.Enum }
+
+// annotations returns the given annotations as they are passed to the ent templates.
+func annotations(t *testing.T, as ...interface{ Name() string }) map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, a := range as {
+		b, err := json.Marshal(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			t.Fatal(err)
+		}
+		m[a.Name()] = v
+	}
+	return m
+}
+
+func TestTemplate(t *testing.T) {
+	tmpl, err := template.New("header").Parse(`package {{ $.Config.Package }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl, err = tmpl.ParseFS(Templates, "template/rql.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+	g := &graph{Nodes: []*node{
+		{
+			Name: "User",
+			ID:   &field{Name: "id", Type: "int", Annotations: annotations(t, Filterable(), Sortable())},
+			Fields: []*field{
+				{Name: "name", Type: "string", Annotations: annotations(t, Filterable())},
+				{Name: "status", Type: "user.Status", Enum: true, Annotations: annotations(t, Filterable())},
+				{Name: "created_at", Type: "time.Time", Annotations: annotations(t, Sortable())},
+				{Name: "password", Type: "string"},
+			},
+		},
+	}}
+	g.Config.Package = "ent"
+	var b bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&b, "rql", g); err != nil {
+		t.Fatal(err)
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		t.Fatalf("invalid generated code: %v\n%s", err, b.String())
+	}
+	for _, want := range []string{
+		"type UserRQL struct {\n" +
+			"\tID        int       `rql:\"filter,sort\"`\n" +
+			"\tName      string    `rql:\"filter\"`\n" +
+			"\tStatus    string    `rql:\"filter\"`\n" +
+			"\tCreatedAt time.Time `rql:\"sort\"`\n" +
+			"}",
+		"func NewUserParser(c rql.Config) (*rql.Parser, error) {",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Fatalf("expect generated code to contain:\n%s\ngot:\n%s", want, src)
+		}
+	}
+	if strings.Contains(string(src), "Password") {
+		t.Fatalf("expect fields without annotations to be excluded:\n%s", src)
+	}
+}
@@ -0,0 +1,66 @@
+package rql
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Explain returns a human-readable description of the accepted query, suitable
+// for audit logs and debugging UIs. For example:
+//
+//	age > 10 AND (city = 'TLV' OR city = 'NYC'), sorted by name desc, rows 0-25
+//
+// Unlike FilterExp, the filter values are written inline. Therefore, the returned
+// string must not be used as an SQL statement. The values of sensitive fields are
+// redacted (see Params.Redacted).
+func (p *Params) Explain() string {
+	var parts []string
+	switch {
+	case p.filter != nil && !p.filter.empty():
+		b := &bytes.Buffer{}
+		p.filter.write(b, func(b *bytes.Buffer, f *field, v interface{}) {
+			if sensitive(f) {
+				b.WriteString("<redacted>")
+			} else {
+				b.WriteString(literal(v))
+			}
+		})
+		parts = append(parts, b.String())
+	case p.FilterExp != "":
+		parts = append(parts, p.FilterExp)
+	default:
+		parts = append(parts, "all rows")
+	}
+	if p.Select != "" {
+		parts = append(parts, "selecting "+p.Select)
+	}
+	if p.Sort != "" {
+		parts = append(parts, "sorted by "+p.Sort)
+	}
+	// params without a limit (see Config.NoDefaultLimit) return all rows from the offset.
+	if p.Limit > 0 {
+		parts = append(parts, fmt.Sprintf("rows %d-%d", p.Offset, p.Offset+p.Limit))
+	} else {
+		parts = append(parts, fmt.Sprintf("rows from %d", p.Offset))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// literal returns a readable representation of the given filter value.
+func literal(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case time.Time:
+		return "'" + v.Format(time.RFC3339Nano) + "'"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
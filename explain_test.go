@@ -0,0 +1,65 @@
+package rql
+
+import "testing"
+
+func TestExplain(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int     `rql:"filter,sort"`
+			City string  `rql:"filter"`
+			Name string  `rql:"filter,sort"`
+			Rate float64 `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		input []byte
+		want  string
+	}{
+		{
+			input: []byte(`{}`),
+			want:  "all rows, rows 0-25",
+		},
+		{
+			input: []byte(`{"filter": {"age": {"$gt": 10}}, "sort": ["-name"], "limit": 10, "offset": 20}`),
+			want:  "age > 10, sorted by name desc, rows 20-30",
+		},
+		{
+			input: []byte(`{"filter": {"$or": [{"city": "TLV"}, {"city": "N'Y"}]}, "select": ["name"]}`),
+			want:  "(city = 'TLV' OR city = 'N''Y'), selecting name, rows 0-25",
+		},
+		{
+			input: []byte(`{"filter": {"rate": {"$gte": 0.5}}}`),
+			want:  "rate >= 0.5, rows 0-25",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.input), func(t *testing.T) {
+			out, err := p.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if got := out.Explain(); got != tt.want {
+				t.Fatalf("explain:\n\tgot: %q\n\twant: %q", got, tt.want)
+			}
+		})
+	}
+	// params without a limit.
+	nl := MustNewParser(Config{
+		Model: new(struct {
+			Age int `rql:"filter"`
+		}),
+		NoDefaultLimit: true,
+		Log:            t.Logf,
+	})
+	out, err := nl.Parse([]byte(`{"filter": {"age": 1}, "offset": 20}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if got, want := out.Explain(), "age = 1, rows from 20"; got != want {
+		t.Fatalf("explain:\n\tgot: %q\n\twant: %q", got, want)
+	}
+}
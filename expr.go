@@ -0,0 +1,271 @@
+package rql
+
+import (
+	"bytes"
+	"database/sql"
+	"reflect"
+	"strconv"
+)
+
+// expr is a node in the parsed filter tree. A node is either a comparison
+// between a field and a value (e.g. "age > ?"), or a conjunction/disjunction
+// of its children.
+type expr struct {
+	// op is the comparison operator, or AND/OR for conjunctions.
+	op Op
+	// field, column, raw and value are set only for comparison nodes. raw
+	// holds the value as it was given by the user, and value holds it after
+	// it was converted to the field type. For list operators (e.g. IN), both
+	// of them are of type []interface{}.
+	field  *field
+	column string
+	// sql is the SQL operator of custom operators (see Config.ExtraOps).
+	sql   string
+	raw   interface{}
+	value interface{}
+	// join is the relation of the field, if the field belongs to a joined relation.
+	join *relation
+	// null reports if the node is a NULL check ("IS NULL" for EQ, and "IS NOT NULL" for NEQ).
+	null bool
+	// unnest reports if the values of a list node are bound as one ARRAY parameter.
+	unnest bool
+	// variable is the name of the variable that holds the value of a comparison node
+	// in a compiled query. raw and value are set when the query is executed.
+	variable string
+	// tuple holds the comparison nodes of the fields of a row-value comparison, like
+	// "(year, month) >= (?, ?)". op is the comparison operator, and field is nil.
+	tuple []*expr
+	// rel and sub are set only for relation nodes, and field is nil in this case.
+	// sub is the filter that is applied on the related rows, and it may be nil.
+	rel *relation
+	sub *expr
+	// children are the terms of a conjunction. paren reports if they should
+	// be wrapped with parentheses when there are more than one.
+	children []*expr
+	paren    bool
+	// search reports if the node is the disjunction of the search term. See Query.Search.
+	search bool
+	// implicit reports if the node was added by the parser, and not by the query filter.
+	// For example, the soft-delete predicate and the keyset condition of cursors.
+	implicit bool
+}
+
+// empty reports if the node is a conjunction without terms.
+func (e *expr) empty() bool {
+	return e.field == nil && e.rel == nil && e.tuple == nil && len(e.children) == 0
+}
+
+// group reports if the node is a conjunction (or disjunction) of its children.
+func (e *expr) group() bool {
+	return e.field == nil && e.rel == nil && e.tuple == nil
+}
+
+// list returns the raw and the converted values of a comparison node as lists.
+func (e *expr) list() (raw, values []interface{}) {
+	if e.op.list() {
+		return e.raw.([]interface{}), e.value.([]interface{})
+	}
+	return []interface{}{e.raw}, []interface{}{e.value}
+}
+
+// add appends the given term to the node children. Empty conjunctions are skipped.
+func (e *expr) add(c *expr) {
+	if c.empty() {
+		return
+	}
+	e.children = append(e.children, c)
+}
+
+// equal reports if the two nodes are structurally equal. All fields of the nodes
+// are compared, and their values are compared deeply.
+func (e *expr) equal(o *expr) bool {
+	switch {
+	case e.op != o.op || e.field != o.field || e.column != o.column || e.sql != o.sql || e.join != o.join:
+		return false
+	case e.null != o.null || e.unnest != o.unnest || e.variable != o.variable || e.rel != o.rel:
+		return false
+	case e.paren != o.paren || e.search != o.search || e.implicit != o.implicit:
+		return false
+	case len(e.children) != len(o.children) || len(e.tuple) != len(o.tuple) || (e.sub == nil) != (o.sub == nil):
+		return false
+	case e.sub != nil && !e.sub.equal(o.sub):
+		return false
+	case !reflect.DeepEqual(e.raw, o.raw) || !reflect.DeepEqual(e.value, o.value):
+		return false
+	}
+	for i := range e.tuple {
+		if !e.tuple[i].equal(o.tuple[i]) {
+			return false
+		}
+	}
+	for i := range e.children {
+		if !e.children[i].equal(o.children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// write writes the SQL representation of the node to the given buffer. The arg function
+// is called for each value of a comparison node in order to write its representation.
+// Its field is nil for values that don't belong to a field, like "$count" values.
+func (e *expr) write(b *bytes.Buffer, arg func(*bytes.Buffer, *field, interface{})) {
+	if e.rel != nil {
+		if e.op == HAS || e.op == NHAS {
+			b.WriteString(e.op.SQL())
+			b.WriteByte(' ')
+			e.writeSubquery(b, "1", arg)
+			return
+		}
+		e.writeSubquery(b, "COUNT(*)", arg)
+		b.WriteByte(' ')
+		b.WriteString(e.op.SQL())
+		b.WriteByte(' ')
+		arg(b, nil, e.value)
+		return
+	}
+	if e.tuple != nil {
+		b.WriteByte('(')
+		for i, c := range e.tuple {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(c.column)
+		}
+		b.WriteString(") ")
+		b.WriteString(e.op.SQL())
+		b.WriteString(" (")
+		for i, c := range e.tuple {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			arg(b, c.field, c.value)
+		}
+		b.WriteByte(')')
+		return
+	}
+	if e.field != nil {
+		b.WriteString(e.column)
+		if e.null {
+			if e.op == NEQ {
+				b.WriteString(" IS NOT NULL")
+			} else {
+				b.WriteString(" IS NULL")
+			}
+			return
+		}
+		b.WriteByte(' ')
+		if e.sql != "" {
+			b.WriteString(e.sql)
+		} else {
+			b.WriteString(e.op.SQL())
+		}
+		b.WriteByte(' ')
+		if !e.op.list() && e.op != OVERLAPS {
+			arg(b, e.field, e.value)
+			return
+		}
+		if e.unnest {
+			b.WriteString("UNNEST(")
+			arg(b, e.field, e.array())
+			b.WriteByte(')')
+			return
+		}
+		if e.op == OVERLAPS {
+			// the bounds are passed to the range constructor. e.g. "tstzrange(?, ?)".
+			b.WriteString(e.field.Range)
+		}
+		b.WriteByte('(')
+		for i, v := range e.value.([]interface{}) {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			arg(b, e.field, v)
+		}
+		b.WriteByte(')')
+		return
+	}
+	paren := e.paren && len(e.children) > 1
+	if paren {
+		b.WriteByte('(')
+	}
+	for i, c := range e.children {
+		if i > 0 {
+			b.WriteByte(' ')
+			b.WriteString(e.op.SQL())
+			b.WriteByte(' ')
+		}
+		c.write(b, arg)
+	}
+	if paren {
+		b.WriteByte(')')
+	}
+}
+
+// writeSubquery writes the subquery of a relation node with the given selection.
+func (e *expr) writeSubquery(b *bytes.Buffer, sel string, arg func(*bytes.Buffer, *field, interface{})) {
+	b.WriteString("(SELECT ")
+	b.WriteString(sel)
+	b.WriteString(" FROM ")
+	b.WriteString(e.rel.Table)
+	b.WriteString(" WHERE ")
+	b.WriteString(e.rel.On)
+	if e.sub != nil && !e.sub.empty() {
+		b.WriteString(" AND ")
+		e.sub.write(b, arg)
+	}
+	b.WriteByte(')')
+}
+
+// render writes the SQL representation of the given filter to the parse state
+// buffer, and collects its arguments.
+func (p *parseState) render(e *expr) {
+	e.write(p.Buffer, p.bind)
+}
+
+// bind writes a placeholder for the given value, and adds it to the arguments list.
+// If the ReuseArgs option is enabled, the placeholder of a previous occurrence of
+// the value is used instead of adding a new argument.
+func (p *parseState) bind(b *bytes.Buffer, f *field, v interface{}) {
+	var (
+		i     int
+		ok    bool
+		reuse = p.conf.ReuseArgs && v != nil && reflect.TypeOf(v).Comparable()
+	)
+	if reuse {
+		i, ok = p.args[v]
+	}
+	if !ok {
+		i = len(p.values)
+		if reuse {
+			if p.args == nil {
+				p.args = make(map[interface{}]int)
+			}
+			p.args[v] = i
+		}
+		if p.conf.BindStyle.named() && p.conf.Placeholder == nil {
+			v = sql.Named("p"+strconv.Itoa(i+1), v)
+		}
+		p.values = append(p.values, v)
+	}
+	switch n := strconv.Itoa(i + 1); {
+	case p.conf.Placeholder != nil:
+		var m *FieldMeta
+		if f != nil {
+			m = f.meta
+		}
+		b.WriteString(p.conf.Placeholder(i+1, m))
+	case p.conf.BindStyle == BindDollar:
+		b.WriteString("$" + n)
+	case p.conf.BindStyle == BindAt:
+		b.WriteString("@p" + n)
+	case p.conf.BindStyle == BindColon:
+		b.WriteString(":p" + n)
+	default:
+		b.WriteByte('?')
+	}
+	if f != nil && f.Cast != "" {
+		b.WriteString("::")
+		b.WriteString(f.Cast)
+	}
+}
@@ -0,0 +1,143 @@
+package rql
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestBindStyle(t *testing.T) {
+	model := new(struct {
+		Age  int    `rql:"filter"`
+		Name string `rql:"filter"`
+	})
+	input := []byte(`{
+		"filter": {
+			"$or": [
+				{ "$and": [{ "name": "a8m" }, { "age": { "$gt": 1 } }] },
+				{ "$and": [{ "name": "a8m" }, { "age": { "$lt": 1 } }] }
+			]
+		}
+	}`)
+	tests := []struct {
+		name     string
+		conf     Config
+		wantExp  string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "dollar",
+			conf:     Config{BindStyle: BindDollar},
+			wantExp:  "((name = $1 AND age > $2) OR (name = $3 AND age < $4))",
+			wantArgs: []interface{}{"a8m", 1, "a8m", 1},
+		},
+		{
+			name:     "dollar reuse args",
+			conf:     Config{BindStyle: BindDollar, ReuseArgs: true},
+			wantExp:  "((name = $1 AND age > $2) OR (name = $1 AND age < $2))",
+			wantArgs: []interface{}{"a8m", 1},
+		},
+		{
+			name:     "named reuse args",
+			conf:     Config{BindStyle: BindAt, ReuseArgs: true},
+			wantExp:  "((name = @p1 AND age > @p2) OR (name = @p1 AND age < @p2))",
+			wantArgs: []interface{}{sql.Named("p1", "a8m"), sql.Named("p2", 1)},
+		},
+		{
+			name:    "question reuse args",
+			conf:    Config{ReuseArgs: true},
+			wantErr: true,
+		},
+		{
+			name: "placeholder",
+			conf: Config{
+				BindStyle: BindAt,
+				Placeholder: func(i int, f *FieldMeta) string {
+					return "@" + f.Name + strconv.Itoa(i)
+				},
+			},
+			wantExp:  "((name = @name1 AND age > @age2) OR (name = @name3 AND age < @age4))",
+			wantArgs: []interface{}{"a8m", 1, "a8m", 1},
+		},
+		{
+			name: "placeholder reuse args",
+			conf: Config{
+				ReuseArgs: true,
+				Placeholder: func(i int, f *FieldMeta) string {
+					if f.Type.Kind() == reflect.String {
+						return "?" + strconv.Itoa(i) + "::text"
+					}
+					return "?" + strconv.Itoa(i)
+				},
+			},
+			wantExp:  "((name = ?1::text AND age > ?2) OR (name = ?1::text AND age < ?2))",
+			wantArgs: []interface{}{"a8m", 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.conf.Model = model
+			tt.conf.Log = t.Logf
+			p, err := NewParser(tt.conf)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("want: %v\ngot:%v\nerr: %v", tt.wantErr, err != nil, err)
+			}
+			if err != nil {
+				return
+			}
+			out, err := p.Parse(input)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if out.FilterExp != tt.wantExp {
+				t.Fatalf("filter exp:\n\tgot: %q\n\twant: %q", out.FilterExp, tt.wantExp)
+			}
+			if !reflect.DeepEqual(out.FilterArgs, tt.wantArgs) {
+				t.Fatalf("filter args:\n\tgot: %v\n\twant: %v", out.FilterArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCast(t *testing.T) {
+	type User struct {
+		ID   string `rql:"filter,cast=uuid"`
+		Name string `rql:"filter"`
+		Age  int    `rql:"filter"`
+	}
+	p := MustNewParser(Config{
+		Model:     new(User),
+		BindStyle: BindDollar,
+		Cast: func(f *FieldMeta) string {
+			if f.Type.Kind() == reflect.Int {
+				return "int4"
+			}
+			return ""
+		},
+		Log: t.Logf,
+	})
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"age": {"$gt": 1}}, {"id": {"$in": ["a", "b"]}}, {"name": "a8m"}]}}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(age > $1::int4 AND id IN ($2::uuid, $3::uuid) AND name = $4)",
+		FilterArgs: []interface{}{1, "a", "b", "a8m"},
+	})
+	if m := p.Check().Fields[1]; m.Name != "id" || m.Cast != "uuid" {
+		t.Fatalf("unexpected field meta: %+v", m)
+	}
+	for _, conf := range []Config{
+		{Model: new(struct {
+			ID string `rql:"filter,cast=uuid;drop"`
+		})},
+		{Model: new(User), Cast: func(*FieldMeta) string { return "int--" }},
+	} {
+		if _, err := NewParser(conf); err == nil {
+			t.Fatal("expect error for invalid cast")
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package rql
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// Fingerprint returns a stable hash of the query shape: the structure of the filter
+// (fields, operators and conjunctions) with its values stripped, and the sort and
+// select expressions. Queries that differ only in their filter values, or in the order
+// of their filter terms, have the same fingerprint. It can be used to aggregate slow-query
+// logs, or to rate-limit clients by query shape rather than by exact bytes.
+func (p *Params) Fingerprint() string {
+	h := fnv.New64a()
+	if p.filter != nil {
+		h.Write([]byte(shape(p.filter)))
+	} else {
+		h.Write([]byte(p.FilterExp))
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(p.Sort))
+	h.Write([]byte{0})
+	h.Write([]byte(p.Select))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// shape returns the canonical representation of the expression structure.
+// The children of conjunctions are sorted, because their order is not
+// deterministic (objects) and does not affect the result.
+func shape(e *expr) string {
+	switch {
+	case e.rel != nil && e.sub != nil:
+		return e.rel.Name + "(" + shape(e.sub) + ") " + string(e.op)
+	case e.rel != nil:
+		return e.rel.Name + " " + string(e.op)
+	case e.field != nil:
+		return e.column + " " + string(e.op)
+	case e.tuple != nil:
+		cols := make([]string, len(e.tuple))
+		for i, c := range e.tuple {
+			cols[i] = c.column
+		}
+		return "(" + strings.Join(cols, ", ") + ") " + string(e.op)
+	}
+	terms := make([]string, len(e.children))
+	for i, c := range e.children {
+		terms[i] = shape(c)
+	}
+	sort.Strings(terms)
+	return string(e.op) + "(" + strings.Join(terms, ", ") + ")"
+}
@@ -0,0 +1,56 @@
+package rql
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			City string `rql:"filter"`
+			Name string `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	fingerprint := func(s string) string {
+		out, err := p.Parse([]byte(s))
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", s, err)
+		}
+		return out.Fingerprint()
+	}
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{
+			a:    `{"filter": {"age": {"$gt": 1}, "name": "a8m"}}`,
+			b:    `{"filter": {"name": "foo", "age": {"$gt": 10}}, "limit": 10}`,
+			want: true,
+		},
+		{
+			a:    `{"filter": {"$or": [{"city": "TLV"}, {"age": 1}]}}`,
+			b:    `{"filter": {"$or": [{"age": 2}, {"city": "NYC"}]}}`,
+			want: true,
+		},
+		{
+			a: `{"filter": {"age": {"$gt": 1}}}`,
+			b: `{"filter": {"age": {"$lt": 1}}}`,
+		},
+		{
+			a: `{"filter": {"$or": [{"city": "TLV"}, {"age": 1}]}}`,
+			b: `{"filter": {"$and": [{"city": "TLV"}, {"age": 1}]}}`,
+		},
+		{
+			a: `{"filter": {"age": 1}, "sort": ["age"]}`,
+			b: `{"filter": {"age": 1}, "sort": ["-age"]}`,
+		},
+	}
+	for _, tt := range tests {
+		if got := fingerprint(tt.a) == fingerprint(tt.b); got != tt.want {
+			t.Errorf("fingerprint(%s) == fingerprint(%s): got %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package rql
+
+// Query returns the canonical Query of the given Params, with the defaults that were
+// resolved by the parser (limit and sort) and with explicit operators in the filter.
+// It can be used to echo the effective query to clients, or to store it for replay.
+// For example:
+//
+//	params, err := p.Parse([]byte(`{"filter": {"name": "a8m"}}`))
+//	if err != nil {
+//		return err
+//	}
+//	b, err := p.Query(params).MarshalJSON()
+//	// {"limit":25,"filter":{"name":{"$eq":"a8m"}}}
+//
+// Params that were not created by this parser are formatted without their filter.
+func (p *Parser) Query(pr *Params) *Query {
+	q := &Query{
+		Limit:  pr.Limit,
+		Offset: pr.Offset,
+	}
+	if len(pr.selects) > 0 {
+		q.Select = append([]string(nil), pr.selects...)
+	}
+	if len(pr.sort) > 0 {
+		q.Sort = append([]string(nil), pr.sort...)
+	}
+	q.Search = pr.search
+	if len(pr.distinctOn) > 0 {
+		q.DistinctOn = append([]string(nil), pr.distinctOn...)
+	}
+	if filter := explicit(pr.filter); filter != nil && !filter.empty() {
+		q.Filter = p.object(filter)
+	}
+	return q
+}
+
+// explicit returns a copy of the given filter without the nodes that were not written in the
+// query filter: the search node, which is formatted in the "search" field, and the implicit
+// nodes that were added by the parser (e.g. the soft-delete predicate or the cursor keyset).
+func explicit(e *expr) *expr {
+	switch {
+	case e == nil || e.search || e.implicit:
+		return nil
+	case !e.group():
+		return e
+	}
+	c := *e
+	c.children = nil
+	for _, child := range e.children {
+		if child = explicit(child); child == nil {
+			continue
+		}
+		if child.group() && len(child.children) == 1 {
+			child = child.children[0]
+		}
+		c.add(child)
+	}
+	return &c
+}
+
+// object returns the filter object of the given expression.
+func (p *Parser) object(e *expr) map[string]interface{} {
+	switch {
+	case e.rel != nil:
+		terms := make(map[string]interface{})
+		if e.op == HAS || e.op == NHAS {
+			terms[p.op(e.op)] = true
+		} else {
+			terms[p.op(COUNT)] = map[string]interface{}{p.op(e.op): e.raw}
+		}
+		if e.sub != nil && !e.sub.empty() {
+			terms[p.op(WHERE)] = e.rel.Parser.object(e.sub)
+		}
+		return map[string]interface{}{e.rel.Name: terms}
+	case e.field != nil:
+		key := e.field.Name
+		if e.join != nil {
+			key = e.join.Name + p.conf.FieldSep + key
+		}
+		terms := map[string]interface{}{p.op(e.op): p.raw(e)}
+		if e.field.partOf != nil {
+			terms = map[string]interface{}{p.op(e.field.part): terms}
+		}
+		return map[string]interface{}{key: terms}
+	case e.tuple != nil:
+		fields, values := make([]interface{}, len(e.tuple)), make([]interface{}, len(e.tuple))
+		for i, c := range e.tuple {
+			fields[i], values[i] = c.field.Name, c.raw
+		}
+		return map[string]interface{}{
+			p.op(TUPLE): map[string]interface{}{"fields": fields, p.op(e.op): values},
+		}
+	case e.op == OR:
+		return map[string]interface{}{p.op(OR): p.objects(e.children)}
+	}
+	// conjunctions are merged into one object, unless
+	// two of its terms are using the same key.
+	m := make(map[string]interface{})
+	for _, c := range e.children {
+		if !merge(m, p.object(c)) {
+			return map[string]interface{}{p.op(AND): p.objects(e.children)}
+		}
+	}
+	return m
+}
+
+// raw returns the value of the given comparison node, or its variable reference.
+func (p *Parser) raw(e *expr) interface{} {
+	if e.variable != "" {
+		return map[string]interface{}{p.op(VAR): e.variable}
+	}
+	return e.raw
+}
+
+// objects returns the filter objects of the given expressions.
+func (p *Parser) objects(es []*expr) []interface{} {
+	terms := make([]interface{}, len(es))
+	for i, e := range es {
+		terms[i] = p.object(e)
+	}
+	return terms
+}
+
+// merge merges the src filter object into dst. It reports false if the
+// two objects contain the same operator for the same key.
+func merge(dst, src map[string]interface{}) bool {
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+			continue
+		}
+		ops1, ok1 := dst[k].(map[string]interface{})
+		ops2, ok2 := v.(map[string]interface{})
+		if !ok1 || !ok2 {
+			return false
+		}
+		for op := range ops2 {
+			if _, ok := ops1[op]; ok {
+				return false
+			}
+		}
+		for op, v := range ops2 {
+			ops1[op] = v
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package rql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func TestQuery(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			City string `rql:"filter"`
+			Name string `rql:"filter,sort"`
+		}),
+		DefaultSort: []string{"+name"},
+		Log:         t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		name  string
+		input []byte
+		want  *Query
+	}{
+		{
+			name:  "defaults",
+			input: []byte(`{}`),
+			want:  &Query{Limit: 25, Sort: []string{"+name"}},
+		},
+		{
+			name:  "explicit operators",
+			input: []byte(`{"filter": {"name": "a8m", "age": {"$gt": 1, "$lt": 10}}, "select": ["name", "age"], "sort": ["-age"]}`),
+			want: &Query{
+				Limit:  25,
+				Sort:   []string{"-age"},
+				Select: []string{"name", "age"},
+				Filter: map[string]interface{}{
+					"name": map[string]interface{}{"$eq": "a8m"},
+					"age":  map[string]interface{}{"$gt": float64(1), "$lt": float64(10)},
+				},
+			},
+		},
+		{
+			name:  "disjunction",
+			input: []byte(`{"filter": {"$or": [{"city": "TLV"}, {"city": "NYC"}]}}`),
+			want: &Query{
+				Limit: 25,
+				Sort:  []string{"+name"},
+				Filter: map[string]interface{}{
+					"$or": []interface{}{
+						map[string]interface{}{"city": map[string]interface{}{"$eq": "TLV"}},
+						map[string]interface{}{"city": map[string]interface{}{"$eq": "NYC"}},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := p.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			q := p.Query(out)
+			if !reflect.DeepEqual(q, tt.want) {
+				t.Fatalf("query:\n\tgot: %+v\n\twant: %+v", q, tt.want)
+			}
+			b, err := q.MarshalJSON()
+			if err != nil {
+				t.Fatalf("failed to encode query: %v", err)
+			}
+			replay, err := p.Parse(b)
+			if err != nil {
+				t.Fatalf("failed to parse the encoded query %s: %v", b, err)
+			}
+			assertParams(t, replay, out)
+		})
+	}
+	t.Run("conflicting keys", func(t *testing.T) {
+		out, err := p.Parse([]byte(`{"filter": {"age": 1, "$and": [{"age": 2}]}}`))
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		q := p.Query(out)
+		if terms, ok := q.Filter["$and"].([]interface{}); !ok || len(terms) != 2 || len(q.Filter) != 1 {
+			t.Fatalf("expect the filter terms to be wrapped with $and: %v", q.Filter)
+		}
+	})
+	t.Run("implicit terms", func(t *testing.T) {
+		type User struct {
+			gorm.Model
+			Name string `rql:"filter"`
+		}
+		p := MustNewParser(Config{Model: User{}, GormModel: true, Log: t.Logf})
+		out, err := p.Parse([]byte(`{"filter": {"name": "a8m"}}`))
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		want := map[string]interface{}{"name": map[string]interface{}{"$eq": "a8m"}}
+		if q := p.Query(out); !reflect.DeepEqual(q.Filter, want) {
+			t.Fatalf("expect the soft-delete term to be skipped: %v", q.Filter)
+		}
+	})
+}
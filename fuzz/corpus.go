@@ -0,0 +1,218 @@
+package fuzz
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/a8m/rql"
+)
+
+// Generator generates random queries that match the schema of a parser. It can be used
+// for seeding a fuzzer corpus for a custom model, instead of the User model above:
+//
+//	g := fuzz.NewGenerator(parser, 1)
+//	if err := g.WriteCorpus("workdir/corpus", 1000); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// A Generator is not safe for concurrent use.
+type Generator struct {
+	rand   *rand.Rand
+	conf   rql.Config
+	fields []rql.FieldMeta
+}
+
+// NewGenerator returns a generator for the given parser. The seed makes the output deterministic.
+func NewGenerator(p *rql.Parser, seed int64) *Generator {
+	return &Generator{
+		rand:   rand.New(rand.NewSource(seed)),
+		conf:   p.Config,
+		fields: p.Fields(),
+	}
+}
+
+// Valid returns a query that is accepted by the parser.
+func (g *Generator) Valid() []byte {
+	return g.marshal(g.query())
+}
+
+// NearValid returns a query that is similar to a valid one, but contains one invalid
+// part: an unknown field, an unsupported operator, a value of the wrong type, etc.
+func (g *Generator) NearValid() []byte {
+	q := g.query()
+	filter, _ := q["filter"].(map[string]interface{})
+	if filter == nil {
+		filter = make(map[string]interface{})
+		q["filter"] = filter
+	}
+	f := g.field(func(f rql.FieldMeta) bool { return f.Filterable })
+	switch n := g.rand.Intn(6); {
+	case n == 0:
+		filter["unknown_field"] = g.rand.Intn(100)
+	case n == 1 && f != nil:
+		filter[f.Name] = map[string]interface{}{g.conf.OpPrefix + "unknown": g.value(f)}
+	case n == 2 && f != nil:
+		filter[f.Name] = []interface{}{g.value(f)}
+	case n == 3:
+		q["limit"] = -g.rand.Intn(10) - 1
+	case n == 4:
+		q["sort"] = []interface{}{"unknown_field"}
+	default:
+		filter[g.conf.OpPrefix+"or"] = map[string]interface{}{}
+	}
+	return g.marshal(q)
+}
+
+// WriteCorpus writes n valid and n near-valid queries to the given directory. The files are
+// named by the SHA1 of their content, like the corpus files of go-fuzz.
+func (g *Generator) WriteCorpus(dir string, n int) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i := 0; i < 2*n; i++ {
+		b := g.Valid()
+		if i%2 == 1 {
+			b = g.NearValid()
+		}
+		sum := sha1.Sum(b)
+		if err := ioutil.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:])), b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query returns a random valid query.
+func (g *Generator) query() map[string]interface{} {
+	q := make(map[string]interface{})
+	if filter := g.filter(2); len(filter) > 0 {
+		q["filter"] = filter
+	}
+	if g.rand.Intn(2) == 0 {
+		var sort []interface{}
+		for i := g.rand.Intn(3); i > 0; i-- {
+			if f := g.field(func(f rql.FieldMeta) bool { return f.Sortable }); f != nil {
+				sort = append(sort, []string{"", "+", "-"}[g.rand.Intn(3)]+f.Name)
+			}
+		}
+		if len(sort) > 0 {
+			q["sort"] = sort
+		}
+	}
+	if g.rand.Intn(2) == 0 {
+		var sel []interface{}
+		for i := g.rand.Intn(3); i > 0; i-- {
+			sel = append(sel, g.fields[g.rand.Intn(len(g.fields))].Name)
+		}
+		if len(sel) > 0 {
+			q["select"] = sel
+		}
+	}
+	if max := g.conf.LimitMaxValue; g.rand.Intn(2) == 0 && max > 0 {
+		q["limit"] = 1 + g.rand.Intn(max)
+	}
+	if g.rand.Intn(2) == 0 {
+		q["offset"] = g.rand.Intn(1000)
+	}
+	return q
+}
+
+// filter returns a random valid filter object, with conjunctions up to the given depth.
+func (g *Generator) filter(depth int) map[string]interface{} {
+	m := make(map[string]interface{})
+	for i := g.rand.Intn(4); i > 0; i-- {
+		if depth > 0 && g.rand.Intn(4) == 0 {
+			var terms []interface{}
+			for j := 1 + g.rand.Intn(3); j > 0; j-- {
+				terms = append(terms, g.filter(depth-1))
+			}
+			m[g.conf.OpPrefix+[]string{"or", "and"}[g.rand.Intn(2)]] = terms
+			continue
+		}
+		// the values of range fields are not generated.
+		f := g.field(func(f rql.FieldMeta) bool { return f.Filterable && f.Range == "" })
+		if f == nil {
+			break
+		}
+		op := f.Ops[g.rand.Intn(len(f.Ops))]
+		switch {
+		case op == rql.IN || op == rql.NIN:
+			var vs []interface{}
+			for j := 1 + g.rand.Intn(3); j > 0; j-- {
+				vs = append(vs, g.value(f))
+			}
+			m[f.Name] = map[string]interface{}{g.conf.OpPrefix + string(op): vs}
+		case op == rql.YEAR || op == rql.MONTH || op == rql.WEEK || op == rql.DAY || op == rql.DOW || op == rql.HOUR:
+			// date parts of time fields. 1-6 is in the range of all parts.
+			m[f.Name] = map[string]interface{}{g.conf.OpPrefix + string(op): 1 + g.rand.Intn(6)}
+		case op == rql.EQ && g.rand.Intn(2) == 0:
+			m[f.Name] = g.value(f)
+		default:
+			m[f.Name] = map[string]interface{}{g.conf.OpPrefix + string(op): g.value(f)}
+		}
+	}
+	return m
+}
+
+// field returns a random field that matches the given predicate, or nil if there is none.
+func (g *Generator) field(pred func(rql.FieldMeta) bool) *rql.FieldMeta {
+	var fs []*rql.FieldMeta
+	for i := range g.fields {
+		if pred(g.fields[i]) {
+			fs = append(fs, &g.fields[i])
+		}
+	}
+	if len(fs) == 0 {
+		return nil
+	}
+	return fs[g.rand.Intn(len(fs))]
+}
+
+var (
+	nullBool   = reflect.TypeOf(sql.NullBool{})
+	nullString = reflect.TypeOf(sql.NullString{})
+	nullInt    = reflect.TypeOf(sql.NullInt64{})
+	nullInt32  = reflect.TypeOf(sql.NullInt32{})
+	nullFloat  = reflect.TypeOf(sql.NullFloat64{})
+)
+
+// value returns a random JSON value that is valid for the given field.
+func (g *Generator) value(f *rql.FieldMeta) interface{} {
+	switch t := f.Type; {
+	case t.Kind() == reflect.Bool, t == nullBool:
+		return g.rand.Intn(2) == 0
+	case t.Kind() == reflect.String, t == nullString:
+		const letters = "abcdefghijklmnopqrstuvwxyz%_"
+		b := make([]byte, g.rand.Intn(8))
+		for i := range b {
+			b[i] = letters[g.rand.Intn(len(letters))]
+		}
+		return string(b)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64, t == nullInt, t == nullInt32:
+		return g.rand.Intn(2000) - 1000
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr:
+		return g.rand.Intn(1000)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64, t == nullFloat:
+		return g.rand.Float64()*2000 - 1000
+	case f.Layout != "":
+		return time.Unix(g.rand.Int63n(2e9), 0).UTC().Format(f.Layout)
+	default:
+		return nil
+	}
+}
+
+func (g *Generator) marshal(q map[string]interface{}) []byte {
+	b, err := json.Marshal(q)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,44 @@
+package fuzz
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/a8m/rql"
+)
+
+func TestGenerator(t *testing.T) {
+	p := rql.MustNewParser(rql.Config{
+		Model: struct {
+			ID        uint      `rql:"filter,sort"`
+			Age       int       `rql:"filter,sort"`
+			Rate      float64   `rql:"filter"`
+			Admin     bool      `rql:"filter"`
+			Name      string    `rql:"filter,sort"`
+			CreatedAt time.Time `rql:"filter,sort,layout=2006-01-02 15:04"`
+		}{},
+		LimitMaxValue: 50,
+	})
+	g := NewGenerator(p, 1)
+	for i := 0; i < 500; i++ {
+		if b := g.Valid(); !accepted(p, b) {
+			t.Fatalf("valid query %s rejected", b)
+		}
+		if b := g.NearValid(); accepted(p, b) {
+			t.Fatalf("near-valid query %s accepted", b)
+		}
+	}
+	dir := t.TempDir()
+	if err := NewGenerator(p, 2).WriteCorpus(dir, 10); err != nil {
+		t.Fatal(err)
+	}
+	if files, err := ioutil.ReadDir(dir); err != nil || len(files) == 0 {
+		t.Fatalf("expect corpus files, got: %v %v", files, err)
+	}
+}
+
+func accepted(p *rql.Parser, b []byte) bool {
+	_, err := p.Parse(b)
+	return err == nil
+}
@@ -4,6 +4,7 @@ require (
 	github.com/go-sql-driver/mysql v1.5.0 // indirect
 	github.com/jinzhu/gorm v1.9.16 // indirect
 	github.com/mailru/easyjson v0.7.7
+	golang.org/x/text v0.13.0
 )
 
 go 1.16
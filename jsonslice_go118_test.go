@@ -0,0 +1,46 @@
+//go:build go1.18
+// +build go1.18
+
+package rql
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// JSONSlice has the same structure as the datatypes.JSONSlice[T] type of gorm.
+type JSONSlice[T any] []T
+
+func (j JSONSlice[T]) Value() (driver.Value, error) { return json.Marshal(j) }
+
+func TestJSONSlice(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Nums  JSONSlice[int64]     `rql:"filter"`
+			Dates JSONSlice[time.Time] `rql:"filter,layout=2006-01-02"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"nums": {"$contains": [1, 2]}}, {"dates": {"$contains": "2020-01-02"}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(nums @> ? AND dates @> ?)",
+		FilterArgs: []interface{}{[]byte(`[1,2]`), []byte(`["2020-01-02T00:00:00Z"]`)},
+	})
+	for _, input := range []string{
+		`{"filter": {"nums": {"$contains": 1.5}}}`,
+		`{"filter": {"dates": {"$contains": "2020-01-02T00:00:00Z"}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+}
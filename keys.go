@@ -0,0 +1,113 @@
+package rql
+
+import (
+	"fmt"
+
+	"github.com/mailru/easyjson/jlexer"
+	"github.com/mailru/easyjson/jwriter"
+)
+
+// queryKeys are the default JSON keys of the query fields.
+var queryKeys = []string{"limit", "offset", "select", "sort", "filter", "vars", "after", "before", "search", "distinct_on"}
+
+// defaults sets the default names of the empty keys, and validates that the keys
+// don't conflict with each other, or with the keys that can't be renamed.
+func (k *QueryKeys) defaults() error {
+	for _, f := range []struct {
+		key *string
+		def string
+	}{{&k.Filter, "filter"}, {&k.Sort, "sort"}, {&k.Limit, "limit"}, {&k.Offset, "offset"}} {
+		if *f.key == "" {
+			*f.key = f.def
+		}
+	}
+	seen := make(map[string]bool, len(queryKeys))
+	for _, key := range queryKeys {
+		name := k.name(key)
+		if seen[name] {
+			return fmt.Errorf("rql: query key %q conflicts with another key", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// name returns the name of the given default key.
+func (k *QueryKeys) name(key string) string {
+	switch key {
+	case "filter":
+		return k.Filter
+	case "sort":
+		return k.Sort
+	case "limit":
+		return k.Limit
+	case "offset":
+		return k.Offset
+	default:
+		return key
+	}
+}
+
+// initKeys maps the renamed keys of Config.Keys to their default names.
+func (p *Parser) initKeys() {
+	for _, key := range queryKeys {
+		if name := p.conf.Keys.name(key); name != key {
+			if p.keys == nil {
+				p.keys = make(map[string]string)
+			}
+			// the default name is rejected, unless it's the new name of another key.
+			if _, ok := p.keys[key]; !ok {
+				p.keys[key] = ""
+			}
+			p.keys[name] = key
+		}
+	}
+}
+
+// unmarshal decodes the given input into the query, using the keys of Config.Keys.
+func (p *Parser) unmarshal(b []byte, q *Query) error {
+	if p.keys != nil {
+		var err error
+		if b, err = p.renameKeys(b); err != nil {
+			return err
+		}
+	}
+	r := jlexer.Lexer{Data: b}
+	q.UnmarshalEasyJSON(&r)
+	return r.Error()
+}
+
+// renameKeys rewrites the renamed keys of the given query object to their default names,
+// before it is decoded. The default names of renamed keys are rejected as unknown fields.
+func (p *Parser) renameKeys(b []byte) ([]byte, error) {
+	in := jlexer.Lexer{Data: b}
+	if in.IsNull() {
+		return b, nil
+	}
+	out := jwriter.Writer{}
+	out.RawByte('{')
+	in.Delim('{')
+	for first := true; !in.IsDelim('}'); first = false {
+		key := in.UnsafeFieldName(false)
+		if name, ok := p.keys[key]; ok && name == "" {
+			in.AddError(&jlexer.LexerError{Offset: in.GetPos(), Reason: "unknown field", Data: key})
+		} else if ok {
+			key = name
+		}
+		in.WantColon()
+		if !first {
+			out.RawByte(',')
+		}
+		out.String(key)
+		out.RawByte(':')
+		out.Raw(in.Raw(), nil)
+		in.WantComma()
+	}
+	in.Delim('}')
+	in.Consumed()
+	if err := in.Error(); err != nil {
+		return nil, err
+	}
+	out.RawByte('}')
+	return out.BuildBytes()
+}
@@ -0,0 +1,60 @@
+package rql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter"`
+		}),
+		Keys: QueryKeys{Filter: "where", Sort: "order", Limit: "take", Offset: "skip"},
+		Log:  t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"where": {"age": {"$gt": 10}}, "order": ["-age"], "take": 10, "skip": 20, "select": ["name"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      10,
+		Offset:     20,
+		FilterExp:  "age > ?",
+		FilterArgs: []interface{}{10},
+		Sort:       "age desc",
+		Select:     "name",
+	})
+	for _, input := range []string{`{"filter": {"age": 1}}`, `{"sort": ["age"]}`, `{"limit": 10}`} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for default key in input %s", input)
+		}
+	}
+	if _, err := p.Parse([]byte(`{"take": -1}`)); err == nil || !strings.Contains(err.Error(), "take must be") {
+		t.Fatalf("expect error with the renamed key, got: %v", err)
+	}
+	if _, err := p.Compile([]byte(`{"where": {"age": {"$var": "age"}}}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// keys can be swapped.
+	p = MustNewParser(Config{
+		Model: new(struct {
+			Age int `rql:"filter,sort"`
+		}),
+		Keys: QueryKeys{Filter: "sort", Sort: "filter"},
+	})
+	out, err = p.Parse([]byte(`{"sort": {"age": 1}, "filter": ["age"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{Limit: 25, FilterExp: "age = ?", FilterArgs: []interface{}{1}, Sort: "age"})
+	for _, keys := range []QueryKeys{{Filter: "sort"}, {Limit: "search"}, {Limit: "take", Offset: "take"}} {
+		if _, err := NewParser(Config{Model: new(struct{}), Keys: keys}); err == nil {
+			t.Fatalf("expect error for conflicting keys: %+v", keys)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package rql
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxMoneyScale is the maximum number of decimal places of money fields.
+const maxMoneyScale = 8
+
+var (
+	// moneyValue matches the decimal amounts of money fields. e.g. "10.50" or "-3".
+	moneyValue = regexp.MustCompile(`^-?(\d+)(?:\.(\d+))?$`)
+	// intTypes holds the signed integer types by their kinds.
+	intTypes = map[reflect.Kind]reflect.Type{
+		reflect.Int:   reflect.TypeOf(int(0)),
+		reflect.Int8:  reflect.TypeOf(int8(0)),
+		reflect.Int16: reflect.TypeOf(int16(0)),
+		reflect.Int32: reflect.TypeOf(int32(0)),
+		reflect.Int64: reflect.TypeOf(int64(0)),
+	}
+)
+
+// parseMoney parses the given decimal amount into minor units of the given scale.
+// For example, "10.5" with scale 2 is 1050. Amounts with more decimal places than
+// the scale are rejected, instead of being rounded.
+func parseMoney(s string, scale int) (int64, error) {
+	m := moneyValue.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(m[2]) > scale {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", s, scale)
+	}
+	digits := m[1] + m[2] + strings.Repeat("0", scale-len(m[2]))
+	if s[0] == '-' {
+		digits = "-" + digits
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("amount %q overflows int64", s)
+	}
+	return n, nil
+}
+
+// validateMoney returns a validation function of decimal amounts with the given scale,
+// that are stored in a column of the given integer type.
+func validateMoney(scale int, t reflect.Type) func(interface{}) error {
+	return func(v interface{}) error {
+		s, ok := v.(string)
+		if !ok {
+			return errorType(v, "string")
+		}
+		n, err := parseMoney(s, scale)
+		if err != nil {
+			return err
+		}
+		if reflect.Zero(t).OverflowInt(n) {
+			return fmt.Errorf("amount %q overflows %s", s, t.Kind())
+		}
+		return nil
+	}
+}
+
+// convertMoney returns a converter of decimal amounts to minor units of the kind of
+// the given integer type.
+func convertMoney(scale int, t reflect.Type) func(interface{}) interface{} {
+	it := intTypes[t.Kind()]
+	return func(v interface{}) interface{} {
+		n, _ := parseMoney(v.(string), scale)
+		return reflect.ValueOf(n).Convert(it).Interface()
+	}
+}
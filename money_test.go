@@ -0,0 +1,92 @@
+package rql
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestMoney(t *testing.T) {
+	type Cents int32
+	p := MustNewParser(Config{
+		Model: struct {
+			Amount   int64         `rql:"filter,money"`
+			Fee      Cents         `rql:"filter,money"`
+			Yen      int           `rql:"filter,money=0"`
+			Dinar    sql.NullInt64 `rql:"filter,money=3"`
+			Quantity int           `rql:"filter"`
+		}{},
+		CoerceStrings: true,
+		Log:           t.Logf,
+	})
+	tests := []struct {
+		input string
+		want  *Params
+	}{
+		{
+			input: `{"filter": {"amount": {"$gte": "10.50"}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "amount >= ?",
+				FilterArgs: []interface{}{int64(1050)},
+			},
+		},
+		{
+			input: `{"filter": {"amount": {"$in": ["0.5", "-3", "7.05"]}, "fee": "1.99"}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "amount IN (?, ?, ?) AND fee = ?",
+				FilterArgs: []interface{}{int64(50), int64(-300), int64(705), int32(199)},
+			},
+		},
+		{
+			input: `{"filter": {"yen": "1500", "dinar": {"$lt": "2.125"}, "quantity": "3"}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "yen = ? AND dinar < ? AND quantity = ?",
+				FilterArgs: []interface{}{1500, int64(2125), 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			out, err := p.Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertParams(t, out, tt.want)
+		})
+	}
+	for _, input := range []string{
+		`{"filter": {"amount": 10.5}}`,
+		`{"filter": {"amount": "10.505"}}`,
+		`{"filter": {"amount": "1e3"}}`,
+		`{"filter": {"amount": "1,000.00"}}`,
+		`{"filter": {"amount": " 10"}}`,
+		`{"filter": {"amount": ".5"}}`,
+		`{"filter": {"amount": "99999999999999999999"}}`,
+		`{"filter": {"fee": "21474836.48"}}`,
+		`{"filter": {"yen": "1.5"}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Errorf("expected error for input: %s", input)
+		}
+	}
+	for _, m := range []interface{}{
+		struct {
+			Amount float64 `rql:"filter,money"`
+		}{},
+		struct {
+			Amount uint64 `rql:"filter,money"`
+		}{},
+		struct {
+			Amount int64 `rql:"filter,money=usd"`
+		}{},
+		struct {
+			Amount int64 `rql:"filter,money=9"`
+		}{},
+	} {
+		if _, err := NewParser(Config{Model: m}); err == nil {
+			t.Errorf("expected error for model: %T", m)
+		}
+	}
+}
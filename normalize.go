@@ -0,0 +1,127 @@
+package rql
+
+// normalize rewrites the given expression into a simpler equivalent form:
+//
+//  1. Nested conjunctions of the same kind are flattened. "a AND (b AND c)" => "a AND b AND c".
+//  2. Duplicate terms are dropped. "a OR a" => "a".
+//  3. Equality checks of the same field in a disjunction are collapsed. "x = a OR x = b" => "x IN (a, b)".
+//
+// Conjunctions that are left with one term are replaced by the term itself.
+func normalize(e *expr) *expr {
+	if e.rel != nil && e.sub != nil {
+		e.sub = normalize(e.sub)
+	}
+	if !e.group() {
+		return e
+	}
+	children := make([]*expr, 0, len(e.children))
+	for _, c := range e.children {
+		c = normalize(c)
+		// the search node is kept, in order to be formatted as the search term.
+		if c.group() && c.op == e.op && !c.search {
+			children = append(children, c.children...)
+		} else {
+			children = append(children, c)
+		}
+	}
+	children = dedup(children)
+	if e.op == OR {
+		children = collapseIn(children)
+	}
+	if len(children) == 1 {
+		return children[0]
+	}
+	e.children = children
+	return e
+}
+
+// dedup removes the duplicate terms from the given list.
+func dedup(es []*expr) []*expr {
+	uniq := es[:0]
+	for _, e := range es {
+		var found bool
+		for _, u := range uniq {
+			if found = u.equal(e); found {
+				break
+			}
+		}
+		if !found {
+			uniq = append(uniq, e)
+		}
+	}
+	return uniq
+}
+
+// collapseIn collapses the equality checks (EQ and IN) of the same field in a disjunction
+// into one IN predicate. The position of the predicate is the position of the first check.
+// Fields of different relations (or columns) are not collapsed, even if they share a parser.
+func collapseIn(es []*expr) []*expr {
+	type key struct {
+		field  *field
+		join   *relation
+		column string
+	}
+	var (
+		out []*expr
+		in  = make(map[key]*expr)
+	)
+	for _, e := range es {
+		if e.field == nil || e.null || e.op != EQ && e.op != IN {
+			out = append(out, e)
+			continue
+		}
+		raw, values := e.list()
+		k := key{e.field, e.join, e.column}
+		c, ok := in[k]
+		if !ok {
+			// copy the node in order to keep its other attributes (e.g. join or sql).
+			cp := *e
+			c = &cp
+			if e.op == IN {
+				// copy the lists in order to avoid mutating the user input on append.
+				c.raw, c.value = append([]interface{}(nil), raw...), append([]interface{}(nil), values...)
+			}
+			in[k] = c
+			out = append(out, c)
+			continue
+		}
+		if c.op == EQ {
+			c.op, c.raw, c.value = IN, []interface{}{c.raw}, []interface{}{c.value}
+		}
+		c.raw = append(c.raw.([]interface{}), raw...)
+		c.value = append(c.value.([]interface{}), values...)
+	}
+	return out
+}
+
+// chunk splits the lists of IN and NOT IN predicates that are longer than the
+// given size into a disjunction (or conjunction for NOT IN) of smaller lists.
+func chunk(e *expr, size int) *expr {
+	if e.rel != nil && e.sub != nil {
+		e.sub = chunk(e.sub, size)
+	}
+	if e.group() {
+		for i, c := range e.children {
+			e.children[i] = chunk(c, size)
+		}
+		return e
+	}
+	if e.field == nil || !e.op.list() || len(e.value.([]interface{})) <= size {
+		return e
+	}
+	g := &expr{op: OR, paren: true}
+	if e.op == NIN {
+		g.op = AND
+	}
+	raw, values := e.list()
+	for i := 0; i < len(values); i += size {
+		j := i + size
+		if j > len(values) {
+			j = len(values)
+		}
+		c := *e
+		c.raw, c.value = raw[i:j], values[i:j]
+		g.add(&c)
+	}
+	return g
+}
@@ -0,0 +1,231 @@
+package rql
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		wantOut *Params
+	}{
+		{
+			name:  "collapse equality checks",
+			input: []byte(`{"filter": {"$or": [{"city": "TLV"}, {"city": "NYC"}, {"$or": [{"city": "LDN"}]}]}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "city IN (?, ?, ?)",
+				FilterArgs: []interface{}{"TLV", "NYC", "LDN"},
+			},
+		},
+		{
+			name:  "collapse into existing IN",
+			input: []byte(`{"filter": {"$or": [{"age": {"$in": [1, 2]}}, {"age": 3}, {"name": "a8m"}]}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(age IN (?, ?, ?) OR name = ?)",
+				FilterArgs: []interface{}{1, 2, 3, "a8m"},
+			},
+		},
+		{
+			name:  "flatten conjunctions",
+			input: []byte(`{"filter": {"name": "a8m", "$and": [{"age": {"$gt": 1}}, {"$and": [{"city": "TLV"}]}]}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "name = ? AND age > ? AND city = ?",
+				FilterArgs: []interface{}{"a8m", 1, "TLV"},
+			},
+		},
+		{
+			name:  "drop duplicates",
+			input: []byte(`{"filter": {"age": 1, "$and": [{"age": 1}, {"$or": [{"age": {"$gt": 5}}, {"age": {"$gt": 5}}]}]}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "age = ? AND age > ?",
+				FilterArgs: []interface{}{1, 5},
+			},
+		},
+	}
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter"`
+			City string `rql:"filter"`
+			Name string `rql:"filter"`
+		}),
+		Normalize: true,
+		Log:       t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := p.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			assertParams(t, out, tt.wantOut)
+		})
+	}
+}
+
+func TestArgsLimit(t *testing.T) {
+	model := new(struct {
+		ID int `rql:"filter"`
+	})
+	tests := []struct {
+		name    string
+		conf    Config
+		input   []byte
+		wantErr bool
+		wantOut *Params
+	}{
+		{
+			name:  "chunk in",
+			conf:  Config{InChunkSize: 2},
+			input: []byte(`{"filter": {"id": {"$in": [1, 2, 3]}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(id IN (?, ?) OR id IN (?))",
+				FilterArgs: []interface{}{1, 2, 3},
+			},
+		},
+		{
+			name:  "chunk nin",
+			conf:  Config{InChunkSize: 2},
+			input: []byte(`{"filter": {"id": {"$nin": [1, 2, 3, 4]}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(id NOT IN (?, ?) AND id NOT IN (?, ?))",
+				FilterArgs: []interface{}{1, 2, 3, 4},
+			},
+		},
+		{
+			name:  "max args",
+			conf:  Config{MaxArgs: 3},
+			input: []byte(`{"filter": {"id": {"$in": [1, 2, 3]}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "id IN (?, ?, ?)",
+				FilterArgs: []interface{}{1, 2, 3},
+			},
+		},
+		{
+			name:    "too many args",
+			conf:    Config{MaxArgs: 3},
+			input:   []byte(`{"filter": {"id": {"$in": [1, 2, 3]}, "$or": [{"id": 4}]}}`),
+			wantErr: true,
+		},
+		{
+			name:  "max args with reused args",
+			conf:  Config{MaxArgs: 1, BindStyle: BindDollar, ReuseArgs: true},
+			input: []byte(`{"filter": {"$or": [{"id": 1}, {"id": {"$gt": 1}}]}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(id = $1 OR id > $1)",
+				FilterArgs: []interface{}{1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.conf.Model = model
+			tt.conf.Log = t.Logf
+			p, err := NewParser(tt.conf)
+			if err != nil {
+				t.Fatalf("failed to build parser: %v", err)
+			}
+			out, err := p.Parse(tt.input)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("want: %v\ngot:%v\nerr: %v", tt.wantErr, err != nil, err)
+			}
+			if err == nil && out.FilterExp != tt.wantOut.FilterExp {
+				t.Fatalf("filter exp:\n\tgot: %q\n\twant: %q", out.FilterExp, tt.wantOut.FilterExp)
+			}
+			assertParams(t, out, tt.wantOut)
+		})
+	}
+}
+
+func TestNormalizeJoins(t *testing.T) {
+	ap := MustNewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	p, err := NewParser(Config{
+		Model: new(struct {
+			ID int `rql:"filter"`
+		}),
+		FieldSep:  ".",
+		Normalize: true,
+		Relations: map[string]Relation{
+			"buyer":  {Table: "accounts", Alias: "b", On: "b.id = orders.buyer_id", Parser: ap},
+			"seller": {Table: "accounts", Alias: "s", On: "s.id = orders.seller_id", Parser: ap},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		name    string
+		input   []byte
+		wantOut *Params
+	}{
+		{
+			name:  "same value",
+			input: []byte(`{"filter": {"$and": [{"buyer.name": "x"}, {"seller.name": "x"}]}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "b.name = ? AND s.name = ?",
+				FilterArgs: []interface{}{"x", "x"},
+			},
+		},
+		{
+			name:  "disjunction",
+			input: []byte(`{"filter": {"$or": [{"buyer.name": "x"}, {"seller.name": "y"}, {"buyer.name": "z"}]}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(b.name IN (?, ?) OR s.name = ?)",
+				FilterArgs: []interface{}{"x", "z", "y"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := p.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			assertParams(t, out, tt.wantOut)
+		})
+	}
+}
+
+func TestChunkNodes(t *testing.T) {
+	f, r := &field{Name: "id"}, &relation{Name: "orders"}
+	e := &expr{op: IN, field: f, column: "orders.id", join: r, raw: []interface{}{1, 2, 3}, value: []interface{}{1, 2, 3}, implicit: true}
+	g := chunk(e, 2)
+	if len(g.children) != 2 {
+		t.Fatalf("expect 2 chunks, got %d", len(g.children))
+	}
+	for _, c := range g.children {
+		if c.join != r || !c.implicit || c.column != e.column {
+			t.Fatalf("expect the chunks to keep the node fields: %+v", c)
+		}
+	}
+	if g.children[0].equal(g.children[1]) {
+		t.Fatal("expect chunks with different values to be different")
+	}
+	v1, v2 := *e, *e
+	v1.variable, v2.variable = "a", "b"
+	if v1.equal(&v2) {
+		t.Fatal("expect nodes with different variables to be different")
+	}
+	n1, n2 := *e, *e
+	n2.null = true
+	if n1.equal(&n2) {
+		t.Fatal("expect nodes with different null checks to be different")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build go1.17
+// +build go1.17
+
+package rql
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+// sql.NullInt16 and sql.NullByte were added in Go 1.17.
+func init() {
+	nullTypes[reflect.TypeOf(sql.NullInt16{})] = reflect.TypeOf(int16(0))
+	nullTypes[reflect.TypeOf(sql.NullByte{})] = reflect.TypeOf(uint8(0))
+}
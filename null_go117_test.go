@@ -0,0 +1,40 @@
+//go:build go1.17
+// +build go1.17
+
+package rql
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestNullTypesGo117(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Rank  sql.NullInt16 `rql:"filter"`
+			Level sql.NullByte  `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"rank": -1}, {"level": {"$in": [1, 255]}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(rank = ? AND level IN (?, ?))",
+		FilterArgs: []interface{}{int16(-1), uint8(1), uint8(255)},
+	})
+	for _, input := range []string{
+		`{"filter": {"level": 256}}`,
+		`{"filter": {"level": -1}}`,
+		`{"filter": {"rank": 40000}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package rql
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OpSpec describes a custom filter operator that is added on top of the default
+// operators. See Config.ExtraOps.
+type OpSpec struct {
+	// Name is the name of the operator, without the OpPrefix. For example, "fuzzy".
+	Name Op
+	// SQL is the SQL operator that is written between the column and the placeholder
+	// of the value. For example, "%" (pg_trgm similarity) or "ILIKE".
+	SQL string
+	// AppliesTo reports if the operator can be applied on the given field.
+	// If it is nil, the operator is added to all fields.
+	AppliesTo func(f *FieldMeta) bool
+	// Validator and Converter override the validation and the conversion of the field
+	// values for this operator. If they are nil, the functions of the field are used.
+	Validator func(interface{}) error
+	Converter Converter
+}
+
+// reservedOps are the operators that can not be overridden by Config.ExtraOps.
+var reservedOps = []Op{OR, AND, COUNT, HAS, NHAS, WHERE, PRESET, VAR, TUPLE, YEAR, MONTH, WEEK, DAY, DOW, HOUR}
+
+// initExtraOps validates the custom operators, and adds them to the fields they apply to.
+func (p *Parser) initExtraOps() error {
+	if len(p.conf.ExtraOps) == 0 {
+		return nil
+	}
+	p.extraOps = make(map[Op]*OpSpec, len(p.conf.ExtraOps))
+	for i := range p.conf.ExtraOps {
+		spec := &p.conf.ExtraOps[i]
+		switch {
+		case spec.Name == "":
+			return errors.New("rql: extra op must have a name")
+		case spec.SQL == "":
+			return fmt.Errorf("rql: extra op %q must have an SQL operator", spec.Name)
+		case p.extraOps[spec.Name] != nil:
+			return fmt.Errorf("rql: duplicate extra op %q", spec.Name)
+		case opFormat[spec.Name] != "" || spec.Name.list():
+			return fmt.Errorf("rql: extra op %q conflicts with a default operator", spec.Name)
+		}
+		if p.reserved(spec.Name) {
+			return fmt.Errorf("rql: extra op %q conflicts with a reserved operator", spec.Name)
+		}
+		p.extraOps[spec.Name] = spec
+		for name, f := range p.fields {
+			if name == f.Name {
+				p.extraFieldOp(f, spec)
+			}
+		}
+	}
+	return nil
+}
+
+// extraFieldOp adds the given custom operator to the operators of the field, if it applies to it.
+func (p *Parser) extraFieldOp(f *field, spec *OpSpec) {
+	if spec.AppliesTo == nil || spec.AppliesTo(p.meta(f)) {
+		f.FilterOps[p.op(spec.Name)] = true
+	}
+}
+
+// validate validates the given value of the field for the given operator.
+func (p *Parser) validate(f *field, op Op, v interface{}) error {
+	if spec := p.extraOps[op]; spec != nil && spec.Validator != nil {
+		return spec.Validator(v)
+	}
+	return f.ValidateFn(v)
+}
+
+// validate is like Parser.validate, but it also applies the ValidateValue hook on the value.
+func (p *parseState) validate(f *field, op Op, v interface{}) error {
+	v, err := p.relative(f, v)
+	if err != nil {
+		return err
+	}
+	if err := p.Parser.validate(f, op, v); err != nil {
+		return err
+	}
+	return p.validateHook(f, op, v)
+}
+
+// validateElem validates an element of a list value of the field, and applies the
+// ValidateValue hook on it.
+func (p *parseState) validateElem(f *field, op Op, v interface{}) error {
+	v, err := p.relative(f, v)
+	if err != nil {
+		return err
+	}
+	if err := f.ValidateFn(v); err != nil {
+		return err
+	}
+	return p.validateHook(f, op, v)
+}
+
+// validateHook calls the ValidateValue hook with the context of the call, if it is set.
+func (p *parseState) validateHook(f *field, op Op, v interface{}) error {
+	if p.conf.ValidateValue == nil {
+		return nil
+	}
+	return p.conf.ValidateValue(p.ctx, f.meta, op, v)
+}
+
+// convert is like Parser.convert, but it also applies the ConvertValue hook on the value.
+func (p *parseState) convert(f *field, op Op, v interface{}) interface{} {
+	v, _ = p.relative(f, v)
+	v = p.Parser.convert(f, op, v)
+	if p.conf.ConvertValue != nil {
+		v = p.conf.ConvertValue(p.ctx, f.meta, op, v)
+	}
+	return v
+}
+
+// opSQL returns the SQL operator of custom operators, and an empty string for the default ones.
+func (p *Parser) opSQL(op Op) string {
+	if spec := p.extraOps[op]; spec != nil {
+		return spec.SQL
+	}
+	return ""
+}
+
+// fieldOps are the default operators that can be applied on fields.
+var fieldOps = []Op{EQ, NEQ, LT, GT, LTE, GTE, LIKE, IN, NIN, CONTAINS, OVERLAPS}
+
+// initOpAliases validates the operator aliases, and maps them (with the OpPrefix) to their operators.
+func (p *Parser) initOpAliases() error {
+	if len(p.conf.OpAliases) == 0 {
+		return nil
+	}
+	p.opAliases = make(map[string]string, len(p.conf.OpAliases))
+	for alias, op := range p.conf.OpAliases {
+		if alias == "" {
+			return fmt.Errorf("rql: empty alias for op %q", op)
+		}
+		if p.knownOp(Op(alias)) || opFormat[Op(alias)] != "" || p.reserved(Op(alias)) {
+			return fmt.Errorf("rql: op alias %q conflicts with an existing operator", alias)
+		}
+		if !p.knownOp(op) {
+			return fmt.Errorf("rql: op alias %q references an unknown field operator %q", alias, op)
+		}
+		p.opAliases[p.op(Op(alias))] = p.op(op)
+	}
+	return nil
+}
+
+// knownOp reports if the given operator is a default or a custom field operator.
+func (p *Parser) knownOp(op Op) bool {
+	for _, o := range fieldOps {
+		if o == op {
+			return true
+		}
+	}
+	return p.extraOps[op] != nil
+}
+
+// initDisabledOps validates the operators of Config.DisabledOps.
+func (p *Parser) initDisabledOps() error {
+	if len(p.conf.DisabledOps) == 0 {
+		return nil
+	}
+	p.disabledOps = make(map[Op]bool, len(p.conf.DisabledOps))
+	for _, op := range p.conf.DisabledOps {
+		if !p.knownOp(op) && !p.reserved(op) {
+			return fmt.Errorf("rql: unknown disabled op %q", op)
+		}
+		p.disabledOps[op] = true
+	}
+	return nil
+}
+
+// disabledOp rejects the given operator if it is disabled by Config.DisabledOps.
+func (p *parseState) disabledOp(field string, op Op) {
+	expectStr(!p.disabledOps[op], CodeDisabledOp, field, "op %q is disabled", p.op(op))
+}
+
+// reserved reports if the given operator is reserved for relations and filter groups.
+func (p *Parser) reserved(op Op) bool {
+	for _, o := range reservedOps {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
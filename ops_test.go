@@ -0,0 +1,185 @@
+package rql
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtraOps(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+			Age  int    `rql:"filter"`
+		}),
+		ExtraOps: []OpSpec{
+			{
+				Name: "fuzzy",
+				SQL:  "%",
+				AppliesTo: func(f *FieldMeta) bool {
+					return f.Type.Kind() == reflect.String
+				},
+			},
+			{
+				Name: "ilike",
+				SQL:  "ILIKE",
+				AppliesTo: func(f *FieldMeta) bool {
+					return f.Type.Kind() == reflect.String
+				},
+				Validator: func(v interface{}) error {
+					if s, ok := v.(string); !ok || !strings.Contains(s, "%") {
+						return errors.New("expect a pattern")
+					}
+					return nil
+				},
+				Converter: func(v interface{}) interface{} {
+					return strings.ToLower(v.(string))
+				},
+			},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"name": {"$fuzzy": "a8m"}}, {"name": {"$ilike": "A8M%"}}, {"age": {"$gt": 20}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(name % ? AND name ILIKE ? AND age > ?)",
+		FilterArgs: []interface{}{"a8m", "a8m%", 20},
+	})
+	for _, input := range []string{
+		`{"filter": {"age": {"$fuzzy": "a8m"}}}`,
+		`{"filter": {"name": {"$ilike": "a8m"}}}`,
+		`{"filter": {"name": {"$fuzzy": 1}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+	for _, ops := range [][]OpSpec{
+		{{SQL: "%"}},
+		{{Name: "fuzzy"}},
+		{{Name: "eq", SQL: "=="}},
+		{{Name: "preset", SQL: "%"}},
+		{{Name: "fuzzy", SQL: "%"}, {Name: "fuzzy", SQL: "%"}},
+	} {
+		if _, err := NewParser(Config{Model: new(struct {
+			Name string `rql:"filter"`
+		}), ExtraOps: ops}); err == nil {
+			t.Fatalf("expect error for ops: %+v", ops)
+		}
+	}
+}
+
+func TestOpAliases(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+			Age  int    `rql:"filter"`
+		}),
+		ExtraOps: []OpSpec{{Name: "fuzzy", SQL: "%", AppliesTo: func(f *FieldMeta) bool { return f.Name == "name" }}},
+		OpAliases: map[string]Op{
+			"ne":      NEQ,
+			"le":      LTE,
+			"similar": "fuzzy",
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"name": {"$ne": "a8m"}}, {"age": {"$le": 20}}, {"name": {"$similar": "a8m"}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(name <> ? AND age <= ? AND name % ?)",
+		FilterArgs: []interface{}{"a8m", 20, "a8m"},
+	})
+	if _, err := p.Parse([]byte(`{"filter": {"age": {"$similar": 1}}}`)); err == nil {
+		t.Fatal("expect aliases to respect the operators of the field")
+	}
+	for _, aliases := range []map[string]Op{
+		{"": NEQ},
+		{"eq": NEQ},
+		{"or": NEQ},
+		{"neq2": OR},
+		{"neq2": "unknown"},
+	} {
+		if _, err := NewParser(Config{Model: new(struct {
+			Name string `rql:"filter"`
+		}), OpAliases: aliases}); err == nil {
+			t.Fatalf("expect error for aliases: %v", aliases)
+		}
+	}
+}
+
+func TestDisabledOps(t *testing.T) {
+	type Order struct {
+		Total int `rql:"filter"`
+	}
+	conf := Config{
+		Model: new(struct {
+			Name string `rql:"filter,search"`
+			Age  int    `rql:"filter"`
+		}),
+		ExtraOps:    []OpSpec{{Name: "fuzzy", SQL: "%"}},
+		DisabledOps: []Op{LIKE, OR, COUNT, "fuzzy"},
+		Relations: map[string]Relation{
+			"orders": {Table: "orders", On: "orders.user_id = users.id", Model: Order{}},
+		},
+		Log: t.Logf,
+	}
+	p, err := NewParser(conf)
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"name": {"$neq": "a8m"}, "orders": {"$has": {"total": {"$gt": 10}}}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "name <> ? AND EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND total > ?)",
+		FilterArgs: []interface{}{"a8m", 10},
+	})
+	for _, input := range []string{
+		`{"filter": {"name": {"$like": "a8m%"}}}`,
+		`{"filter": {"name": {"$fuzzy": "a8m"}}}`,
+		`{"filter": {"$or": [{"name": "a8m"}, {"age": 1}]}}`,
+		`{"filter": {"orders": {"$count": {"$gt": 1}}}}`,
+		`{"filter": {"orders": {"$has": {"$or": [{"total": 1}, {"total": 2}]}}}}`,
+		`{"search": "a8m"}`,
+	} {
+		_, err := p.Parse([]byte(input))
+		if perr, ok := err.(*ParseError); !ok || perr.Code != CodeDisabledOp {
+			t.Fatalf("expect disabled op error for input %s, got: %v", input, err)
+		}
+	}
+	for _, f := range p.Fields() {
+		for _, op := range f.Ops {
+			if op == LIKE || op == "fuzzy" {
+				t.Fatalf("expect disabled op %q to be omitted from field %q", op, f.Name)
+			}
+		}
+	}
+	conf.Sanitize = true
+	p = MustNewParser(conf)
+	out, err = p.Parse([]byte(`{"filter": {"name": {"$like": "a8m%"}, "age": 1}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.FilterExp != "age = ?" || len(out.Warnings) != 1 || out.Warnings[0].Code != WarnIgnoredOp {
+		t.Fatalf("expect disabled op to be dropped: %q %v", out.FilterExp, out.Warnings)
+	}
+	conf.DisabledOps = []Op{"regex"}
+	if _, err := NewParser(conf); err == nil {
+		t.Fatal("expect error for unknown disabled op")
+	}
+}
@@ -0,0 +1,43 @@
+package rql
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// initPresets decodes and validates the presets of the parser.
+func (p *Parser) initPresets() error {
+	if len(p.conf.Presets) == 0 {
+		return nil
+	}
+	p.presets = make(map[string]map[string]interface{}, len(p.conf.Presets))
+	for name, s := range p.conf.Presets {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			return fmt.Errorf("rql: decoding preset %q: %v", name, err)
+		}
+		p.presets[name] = m
+	}
+	for name := range p.presets {
+		if _, err := p.ParseQuery(&Query{Filter: map[string]interface{}{p.op(PRESET): name}}); err != nil {
+			return fmt.Errorf("rql: invalid preset %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// preset expands the preset with the given name.
+func (p *parseState) preset(v interface{}) *expr {
+	name, ok := v.(string)
+	expect(ok, CodeInvalidQuery, "", "%s must be type string", p.op(PRESET))
+	m, ok := p.presets[name]
+	expect(ok, CodeUnknownPreset, "", "unrecognized preset %q", name)
+	for _, n := range p.expanding {
+		expect(n != name, CodeInvalidQuery, "", "preset %q references itself", name)
+	}
+	p.expanding = append(p.expanding, name)
+	e := p.and(m)
+	p.expanding = p.expanding[:len(p.expanding)-1]
+	e.paren = true
+	return e
+}
@@ -0,0 +1,88 @@
+package rql
+
+import "testing"
+
+func TestPresets(t *testing.T) {
+	model := new(struct {
+		Age   int    `rql:"filter"`
+		Name  string `rql:"filter"`
+		Admin bool   `rql:"filter"`
+	})
+	p, err := NewParser(Config{
+		Model: model,
+		Presets: map[string]string{
+			"admins":       `{"admin": true}`,
+			"adult_admins": `{"$preset": "admins", "age": {"$gte": 18}}`,
+			"names":        `{"$or": [{"name": "a"}, {"name": "b"}]}`,
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		name    string
+		input   []byte
+		wantOut *Params
+		wantErr bool
+	}{
+		{
+			name:  "simple",
+			input: []byte(`{"filter": {"$preset": "admins", "age": 10}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "admin = ? AND age = ?",
+				FilterArgs: []interface{}{true, 10},
+			},
+		},
+		{
+			name:  "nested",
+			input: []byte(`{"filter": {"$preset": "adult_admins"}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(admin = ? AND age >= ?)",
+				FilterArgs: []interface{}{true, 18},
+			},
+		},
+		{
+			name:  "disjunction",
+			input: []byte(`{"filter": {"$preset": "names", "age": 10}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(name = ? OR name = ?) AND age = ?",
+				FilterArgs: []interface{}{"a", "b", 10},
+			},
+		},
+		{
+			name:    "unknown",
+			input:   []byte(`{"filter": {"$preset": "users"}}`),
+			wantErr: true,
+		},
+		{
+			name:    "invalid type",
+			input:   []byte(`{"filter": {"$preset": ["admins"]}}`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := p.Parse(tt.input)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("want error: %v, got: %v", tt.wantErr, err)
+			}
+			if err == nil {
+				assertParams(t, out, tt.wantOut)
+			}
+		})
+	}
+	for _, presets := range []map[string]string{
+		{"a": `{"age": "a"}`},
+		{"a": `{"age": `},
+		{"a": `{"$preset": "b"}`, "b": `{"$preset": "a"}`},
+		{"a": `{"$preset": "c"}`},
+	} {
+		if _, err := NewParser(Config{Model: model, Presets: presets}); err == nil {
+			t.Errorf("expect error for presets: %v", presets)
+		}
+	}
+}
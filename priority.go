@@ -0,0 +1,58 @@
+package rql
+
+import (
+	"fmt"
+	"sort"
+)
+
+// initPriority validates the keys of the FilterPriority option.
+func (p *Parser) initPriority() error {
+	for name := range p.conf.FilterPriority {
+		if f, ok := p.fields[name]; ok && f.Name == name {
+			continue
+		}
+		if _, ok := p.relations[name]; !ok {
+			return fmt.Errorf("rql: unknown field %q in 'FilterPriority'", name)
+		}
+	}
+	return nil
+}
+
+// prioritize orders the top-level terms of the given conjunction by the FilterPriority option.
+func (p *parseState) prioritize(e *expr) {
+	if !e.group() || e.op != AND || len(e.children) < 2 {
+		return
+	}
+	sort.SliceStable(e.children, func(i, j int) bool {
+		ni, gi := termName(e.children[i])
+		nj, gj := termName(e.children[j])
+		switch pi, pj := p.conf.FilterPriority[ni], p.conf.FilterPriority[nj]; {
+		case gi != gj:
+			return gj
+		case pi != pj:
+			return pi > pj
+		default:
+			return ni < nj
+		}
+	})
+}
+
+// termName returns the field (or the relation) name of the given term, and reports if the
+// term is a group of terms. Conjunctions of one field, like "age > ? AND age < ?", are named by it.
+func termName(e *expr) (string, bool) {
+	switch {
+	case e.rel != nil:
+		return e.rel.Name, false
+	case e.field != nil:
+		return e.field.Name, false
+	case e.op != AND || len(e.children) == 0:
+		return "", true
+	}
+	name, group := termName(e.children[0])
+	for _, c := range e.children[1:] {
+		if n, g := termName(c); group || g || n != name {
+			return "", true
+		}
+	}
+	return name, group
+}
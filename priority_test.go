@@ -0,0 +1,45 @@
+package rql
+
+import "testing"
+
+func TestFilterPriority(t *testing.T) {
+	type Order struct {
+		Total int `rql:"filter"`
+	}
+	p := MustNewParser(Config{
+		Model: new(struct {
+			TenantID int    `rql:"filter"`
+			Status   int    `rql:"filter"`
+			Name     string `rql:"filter"`
+			Age      int    `rql:"filter"`
+		}),
+		Relations: map[string]Relation{
+			"orders": {Model: new(Order), Table: "orders", On: "orders.user_id = users.id"},
+		},
+		FilterPriority: map[string]int{"tenant_id": 10, "status": 5, "orders": -1},
+		Log:            t.Logf,
+	})
+	input := []byte(`{
+		"filter": {
+			"orders": {"$has": true},
+			"$or": [{"name": "a"}, {"name": "b"}],
+			"name": "a8m",
+			"age": {"$gt": 1},
+			"status": 1,
+			"tenant_id": 2
+		}
+	}`)
+	for i := 0; i < 10; i++ {
+		out, err := p.Parse(input)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "tenant_id = ? AND status = ? AND age > ? AND name = ? AND EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id) AND (name = ? OR name = ?)"
+		if out.FilterExp != want {
+			t.Fatalf("filter exp:\n\tgot: %q\n\twant: %q", out.FilterExp, want)
+		}
+	}
+	if _, err := NewParser(Config{Model: new(struct{}), FilterPriority: map[string]int{"name": 1}}); err == nil {
+		t.Fatal("expect error for unknown field in FilterPriority")
+	}
+}
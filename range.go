@@ -0,0 +1,52 @@
+package rql
+
+import (
+	"reflect"
+	"time"
+)
+
+// rangeType describes a PostgreSQL range type, and the type of its elements.
+type rangeType struct {
+	// name is the name of the range type, and its constructor. e.g. "int8range".
+	name string
+	// elem is the type that the element values are validated and converted as.
+	elem reflect.Type
+	// cast is the SQL type of the elements, that the placeholders are cast to.
+	cast string
+}
+
+// rangeTypes holds the range types of the "range" option, by their element names.
+var rangeTypes = map[string]rangeType{
+	"int4": {name: "int4range", elem: reflect.TypeOf(int32(0)), cast: "integer"},
+	"int8": {name: "int8range", elem: reflect.TypeOf(int64(0)), cast: "bigint"},
+	"num":  {name: "numrange", elem: reflect.TypeOf(float64(0)), cast: "numeric"},
+	"ts":   {name: "tsrange", elem: reflect.TypeOf(time.Time{}), cast: "timestamp"},
+	"tstz": {name: "tstzrange", elem: reflect.TypeOf(time.Time{}), cast: "timestamptz"},
+	"date": {name: "daterange", elem: reflect.TypeOf(time.Time{}), cast: "date"},
+}
+
+// overlaps parses the "$overlaps" operator of a range field. Its value is an array of the
+// lower and the upper bounds, where null is unbounded. For example:
+//
+//	{"slot": {"$overlaps": ["2024-06-01T10:00:00Z", null]}}   => "slot && tstzrange(?, ?)"
+func (p *parseState) overlaps(f *field, r *relation, v interface{}) *expr {
+	vs, ok := v.([]interface{})
+	expect(ok && len(vs) == 2, CodeInvalidValue, f.Name, "%s on field %q must be an array of the lower and the upper bounds", p.op(OVERLAPS), f.Name)
+	values := make([]interface{}, len(vs))
+	for i := range vs {
+		if vs[i] != nil {
+			mustStr(p.validateElem(f, OVERLAPS, vs[i]), f.Name, "invalid datatype or format for field %q", f.Name)
+			values[i] = p.convert(f, OVERLAPS, vs[i])
+		}
+	}
+	c, ok := compare(values[0], values[1])
+	expect(!ok || c <= 0, CodeInvalidValue, f.Name, "lower bound of %s on field %q must be less than or equal to its upper bound", p.op(OVERLAPS), f.Name)
+	return p.newExpr(expr{
+		op:     OVERLAPS,
+		field:  f,
+		column: p.column(f, r),
+		join:   r,
+		raw:    v,
+		value:  values,
+	})
+}
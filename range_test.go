@@ -0,0 +1,104 @@
+package rql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRange(t *testing.T) {
+	type Booking struct {
+		Slot   string  `rql:"filter,range=tstz"`
+		Seats  []byte  `rql:"filter,range=int4"`
+		Prices string  `rql:"filter,range=num,cast=float8"`
+		Nights *string `rql:"filter,range=date,layout=2006-01-02"`
+	}
+	p := MustNewParser(Config{
+		Model: Booking{},
+		Log:   t.Logf,
+	})
+	tests := []struct {
+		input string
+		want  *Params
+	}{
+		{
+			input: `{"filter": {"slot": {"$contains": "2024-06-01T10:00:00Z"}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "slot @> ?::timestamptz",
+				FilterArgs: []interface{}{time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			input: `{"filter": {"slot": {"$overlaps": ["2024-06-01T10:00:00Z", "2024-06-01T11:00:00Z"]}}}`,
+			want: &Params{
+				Limit:     25,
+				FilterExp: "slot && tstzrange(?::timestamptz, ?::timestamptz)",
+				FilterArgs: []interface{}{
+					time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC),
+					time.Date(2024, 6, 1, 11, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			input: `{"filter": {"seats": {"$contains": 4}, "nights": {"$overlaps": [null, "2024-06-10"]}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "seats @> ?::integer AND nights && daterange(?::date, ?::date)",
+				FilterArgs: []interface{}{int32(4), nil, time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			input: `{"filter": {"prices": {"$contains": 9.99}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "prices @> ?::float8",
+				FilterArgs: []interface{}{9.99},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			out, err := p.Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertParams(t, out, tt.want)
+			b, err := p.Query(out).MarshalJSON()
+			if err != nil {
+				t.Fatalf("failed to marshal query: %v", err)
+			}
+			again, err := p.Parse(b)
+			if err != nil {
+				t.Fatalf("failed to parse formatted query %s: %v", b, err)
+			}
+			assertParams(t, again, tt.want)
+		})
+	}
+	for _, input := range []string{
+		`{"filter": {"slot": "2024-06-01T10:00:00Z"}}`,
+		`{"filter": {"slot": {"$in": ["2024-06-01T10:00:00Z"]}}}`,
+		`{"filter": {"slot": {"$contains": 10}}}`,
+		`{"filter": {"slot": {"$overlaps": "2024-06-01T10:00:00Z"}}}`,
+		`{"filter": {"slot": {"$overlaps": ["2024-06-01T10:00:00Z"]}}}`,
+		`{"filter": {"slot": {"$overlaps": ["2024-06-02T10:00:00Z", "2024-06-01T10:00:00Z"]}}}`,
+		`{"filter": {"seats": {"$contains": 1.5}}}`,
+		`{"filter": {"seats": {"$contains": 3000000000}}}`,
+		`{"filter": {"nights": {"$dow": 0}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Errorf("expected error for input: %s", input)
+		}
+	}
+	for _, c := range []Config{
+		{Model: struct {
+			Slot string `rql:"filter,range=text"`
+		}{}},
+		{Model: struct {
+			Slot string `rql:"filter,range=tstz"`
+		}{}, Dialect: DialectSpanner},
+	} {
+		if _, err := NewParser(c); err == nil {
+			t.Errorf("expected error for config: %+v", c)
+		}
+	}
+}
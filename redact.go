@@ -0,0 +1,41 @@
+package rql
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Redacted returns the filter expression of the params, where the values are replaced by their
+// types, so queries can be logged for debugging without leaking the user input (e.g. PII) that
+// is held in FilterArgs. For example:
+//
+//	email = <string> AND (age > <int> OR created_at >= <time.Time>)
+//
+// The values of fields that have the "sensitive" option are replaced by <redacted>, here and in
+// Explain. Params that were not created by the parser return their FilterExp as is.
+func (p *Params) Redacted() string {
+	if p.filter == nil || p.filter.empty() {
+		return p.FilterExp
+	}
+	b := &bytes.Buffer{}
+	p.filter.write(b, func(b *bytes.Buffer, f *field, v interface{}) {
+		switch {
+		case sensitive(f):
+			b.WriteString("<redacted>")
+		case v == nil:
+			b.WriteString("NULL")
+		default:
+			fmt.Fprintf(b, "<%T>", v)
+		}
+	})
+	return b.String()
+}
+
+// sensitive reports if the values of the given field must not be written in logs.
+// The date parts of a sensitive time field are sensitive as well.
+func sensitive(f *field) bool {
+	if f != nil && f.partOf != nil {
+		f = f.partOf
+	}
+	return f != nil && f.Sensitive
+}
@@ -0,0 +1,68 @@
+package rql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedacted(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age       int       `rql:"filter"`
+			Email     string    `rql:"filter,sensitive"`
+			Name      string    `rql:"filter"`
+			BirthDate time.Time `rql:"filter,sensitive"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		input    []byte
+		redacted string
+		explain  string
+	}{
+		{
+			input:    []byte(`{}`),
+			redacted: "",
+			explain:  "all rows, rows 0-25",
+		},
+		{
+			input:    []byte(`{"filter": {"$and": [{"age": {"$gt": 10}}, {"name": {"$in": ["a", "b"]}}]}}`),
+			redacted: "(age > <int> AND name IN (<string>, <string>))",
+			explain:  "(age > 10 AND name IN ('a', 'b')), rows 0-25",
+		},
+		{
+			input:    []byte(`{"filter": {"$or": [{"email": "a8m@example.com"}, {"name": "a8m"}]}}`),
+			redacted: "(email = <redacted> OR name = <string>)",
+			explain:  "(email = <redacted> OR name = 'a8m'), rows 0-25",
+		},
+		{
+			input:    []byte(`{"filter": {"birth_date": {"$year": {"$eq": 1990}}}}`),
+			redacted: "EXTRACT(YEAR FROM birth_date) = <redacted>",
+			explain:  "EXTRACT(YEAR FROM birth_date) = <redacted>, rows 0-25",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.input), func(t *testing.T) {
+			out, err := p.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if got := out.Redacted(); got != tt.redacted {
+				t.Fatalf("redacted:\n\tgot: %q\n\twant: %q", got, tt.redacted)
+			}
+			if got := out.Explain(); got != tt.explain {
+				t.Fatalf("explain:\n\tgot: %q\n\twant: %q", got, tt.explain)
+			}
+		})
+	}
+	if fs := p.Fields(); fs[2].Name != "email" || !fs[2].Sensitive {
+		t.Fatal("expect the email field to be sensitive")
+	}
+	pr := &Params{FilterExp: "email = ?", FilterArgs: []interface{}{"a8m@example.com"}}
+	if got := pr.Redacted(); got != "email = ?" {
+		t.Fatalf("unexpected redacted expression: %q", got)
+	}
+}
@@ -0,0 +1,228 @@
+package rql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// relation is a has-many relation of the model.
+type relation struct {
+	// Name of the relation in the query.
+	Name string
+	// Table, Alias and On are copied from the Relation configuration.
+	Table string
+	Alias string
+	On    string
+	// Parser of the related model.
+	Parser *Parser
+}
+
+// initRelations creates the parsers of the registered relations. Relations without a pre-built
+// parser get a new one, that inherits the configuration of the parent parser (see relationConfig).
+func (p *Parser) initRelations() error {
+	for name, r := range p.conf.Relations {
+		if r.Table == "" || r.On == "" || (r.Model == nil) == (r.Parser == nil) {
+			return fmt.Errorf("rql: relation %q must have a 'Table', 'On' and either a 'Model' or a 'Parser' fields", name)
+		}
+		if p.fields[name] != nil {
+			return fmt.Errorf("rql: relation %q conflicts with a field with the same name", name)
+		}
+		rp := r.Parser
+		if rp == nil {
+			var err error
+			rp, err = NewParser(p.conf.relationConfig(r.Model))
+			if err != nil {
+				return fmt.Errorf("rql: relation %q: %v", name, err)
+			}
+		} else if rp.conf.OpPrefix != p.conf.OpPrefix || rp.conf.BindStyle != p.conf.BindStyle {
+			return fmt.Errorf("rql: parser of relation %q must have the same 'OpPrefix' and 'BindStyle' options", name)
+		}
+		p.relations[name] = &relation{Name: name, Table: r.Table, Alias: r.Alias, On: r.On, Parser: rp}
+	}
+	return nil
+}
+
+// relationConfig returns the configuration of the parser of a relation with the given model.
+// All options are inherited, except the ones that refer to the fields or the table of the
+// parent model, like DefaultSort, Presets and TableName.
+func (c Config) relationConfig(model interface{}) Config {
+	c.Model = model
+	c.TableName = ""
+	c.DefaultSort = nil
+	c.FilterPriority = nil
+	c.Relations = nil
+	c.Presets = nil
+	c.SortExprs = nil
+	c.Windows = nil
+	c.Computed = nil
+	c.Exprs = nil
+	c.Cache = nil
+	return c
+}
+
+// relation returns the relation registered under the given name, or nil if
+// it does not exist or it's not allowed to be used in this parse call.
+func (p *parseState) relation(name string) *relation {
+	r := p.relations[name]
+	if r == nil || p.allowed != nil && !p.allowed[name] {
+		return nil
+	}
+	return r
+}
+
+// lookupPath returns the field registered under the given name. If there is no such field, the
+// name is looked up as a path to a field of a relation (e.g. "orders.total"), and the relation
+// is returned as well. Relations that are used this way are joined to the query.
+func (p *parseState) lookupPath(name string) (*field, *relation) {
+	if f := p.lookup(name); f != nil {
+		return f, nil
+	}
+	i := strings.Index(name, p.conf.FieldSep)
+	if i == -1 {
+		return nil, nil
+	}
+	r := p.relation(name[:i])
+	if r == nil {
+		return nil, nil
+	}
+	r.Parser.mu.RLock()
+	f := r.Parser.get(name[i+len(p.conf.FieldSep):])
+	r.Parser.mu.RUnlock()
+	if f == nil {
+		return nil, nil
+	}
+	for _, j := range p.joins {
+		if j == r {
+			return f, r
+		}
+	}
+	p.joins = append(p.joins, r)
+	return f, r
+}
+
+// column returns the column name of the given field. Fields of joined relations
+// are qualified with the relation alias (or table name). For example, "orders.total".
+// Date-part fields are extracted from the column of their time field.
+func (p *parseState) column(f *field, r *relation) string {
+	switch {
+	case f.partOf != nil:
+		column, _ := datePart(p.conf.Dialect, f.part, p.column(f.partOf, r))
+		return column
+	case r == nil && p.alias != "" && f.Expr == "" && !strings.Contains(f.Column, "."):
+		return p.ident(f, p.alias+"."+p.fieldColumn(f))
+	case r == nil:
+		return p.ident(f, p.fieldColumn(f))
+	case f.Expr != "" || strings.Contains(f.Column, "."):
+		return p.ident(f, f.Column)
+	}
+	t := r.Alias
+	if t == "" {
+		t = r.Table
+	}
+	return p.ident(f, t+"."+r.Parser.fieldColumn(f))
+}
+
+// tableColumn returns the column of the given field, qualified with the TableName option.
+// It is used by the expressions that are rendered when the parser is created.
+func (p *Parser) tableColumn(f *field) string {
+	if p.conf.TableName == "" || f.Expr != "" || strings.Contains(f.Column, ".") {
+		return p.fieldColumn(f)
+	}
+	return p.conf.TableName + "." + p.fieldColumn(f)
+}
+
+// relFilter parses the filter object of a relation. For example:
+//
+//	{ "$count": { "$gte": 5 }, "$where": { "status": "paid" } }
+//	{ "$has": true, "$where": { "status": "paid" } }
+//	{ "$nhas": { "status": "paid" } }
+func (p *parseState) relFilter(r *relation, v interface{}) *expr {
+	terms, ok := v.(map[string]interface{})
+	expect(ok, CodeInvalidQuery, r.Name, "filter of relation %q must be type object", r.Name)
+	var sub *expr
+	if w, ok := terms[p.op(WHERE)]; ok {
+		p.disabledOp(r.Name, WHERE)
+		sub = p.subFilter(r, WHERE, w)
+	}
+	e := &expr{op: AND, paren: true}
+	for k, v := range terms {
+		switch k {
+		case p.op(WHERE):
+		case p.op(COUNT):
+			p.disabledOp(r.Name, COUNT)
+			e.add(p.count(r, sub, v))
+		case p.op(HAS), p.op(NHAS):
+			op := Op(strings.TrimPrefix(k, p.conf.OpPrefix))
+			p.disabledOp(r.Name, op)
+			e.add(p.exists(r, op, sub, v))
+		default:
+			expect(false, CodeInvalidOp, r.Name, "unrecognized key %q for relation %q", k, r.Name)
+		}
+	}
+	expect(!e.empty(), CodeInvalidQuery, r.Name, "missing operator for relation %q", r.Name)
+	if len(e.children) == 1 {
+		return e.children[0]
+	}
+	return e
+}
+
+// count parses the "$count" operator of a relation. Its value is either a number
+// for equality check, or an object of comparison operators. For example:
+//
+//	{ "$gte": 5, "$lt": 10 }
+func (p *parseState) count(r *relation, sub *expr, v interface{}) *expr {
+	terms, ok := v.(map[string]interface{})
+	if !ok {
+		terms = map[string]interface{}{p.op(EQ): v}
+	}
+	e := &expr{op: AND, paren: true}
+	for opName, opVal := range terms {
+		op := Op(strings.TrimPrefix(opName, p.conf.OpPrefix))
+		expect(countOps[op], CodeInvalidOp, r.Name, "can not apply op %q on %s%s of relation %q", opName, p.conf.OpPrefix, COUNT, r.Name)
+		p.disabledOp(r.Name, op)
+		must(validateUInt(opVal), r.Name, "invalid datatype for %s%s of relation %q", p.conf.OpPrefix, COUNT, r.Name)
+		e.add(&expr{op: op, rel: r, sub: sub, raw: opVal, value: convertInt(opVal)})
+	}
+	if len(e.children) == 1 {
+		return e.children[0]
+	}
+	return e
+}
+
+// exists parses the "$has" and "$nhas" operators of a relation. Its value is either
+// a boolean, or the filter of the related rows. For example:
+//
+//	{ "$has": true }
+//	{ "$has": { "status": "paid" } }
+func (p *parseState) exists(r *relation, op Op, sub *expr, v interface{}) *expr {
+	if b, ok := v.(bool); ok {
+		if !b {
+			op = map[Op]Op{HAS: NHAS, NHAS: HAS}[op]
+		}
+		return &expr{op: op, rel: r, sub: sub}
+	}
+	expect(sub == nil, CodeInvalidQuery, r.Name, "%s%s of relation %q can not be used with a filter and %s%s", p.conf.OpPrefix, op, r.Name, p.conf.OpPrefix, WHERE)
+	return &expr{op: op, rel: r, sub: p.subFilter(r, op, v)}
+}
+
+// subFilter parses the filter of the related rows. The filter is rendered in a subquery, and
+// therefore, paths to the fields of nested relations (e.g. "items.sku"), that require a join,
+// are rejected. Nested relations can be filtered using their operators instead.
+func (p *parseState) subFilter(r *relation, op Op, v interface{}) *expr {
+	m, ok := v.(map[string]interface{})
+	expect(ok, CodeInvalidQuery, r.Name, "%s%s of relation %q must be type object", p.conf.OpPrefix, op, r.Name)
+	r.Parser.mu.RLock()
+	defer r.Parser.mu.RUnlock()
+	ps := &parseState{Parser: r.Parser, ctx: p.ctx, sanitize: p.sanitize, compiling: p.compiling, vars: p.vars, queryVars: p.queryVars, now: p.now}
+	e := ps.and(m)
+	p.now = ps.now
+	if len(ps.joins) > 0 {
+		expect(false, CodeInvalidQuery, r.Name, "fields of relation %q can not be used in the filter of relation %q, use %s%s instead", ps.joins[0].Name, r.Name, p.conf.OpPrefix, HAS)
+	}
+	p.dropped = append(p.dropped, ps.dropped...)
+	p.warnings = append(p.warnings, ps.warnings...)
+	return e
+}
+
+// countOps are the operators that can be applied on the "$count" operator.
+var countOps = map[Op]bool{EQ: true, NEQ: true, LT: true, LTE: true, GT: true, GTE: true}
@@ -0,0 +1,384 @@
+package rql
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRelations(t *testing.T) {
+	type Order struct {
+		Status string  `rql:"filter"`
+		Total  float64 `rql:"filter"`
+	}
+	conf := Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+		}),
+		Relations: map[string]Relation{
+			"orders": {
+				Table: "orders",
+				On:    "orders.user_id = users.id",
+				Model: Order{},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		input   []byte
+		wantErr bool
+		wantOut *Params
+	}{
+		{
+			name:  "count",
+			input: []byte(`{"filter": {"orders": {"$count": {"$gte": 5}}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(SELECT COUNT(*) FROM orders WHERE orders.user_id = users.id) >= ?",
+				FilterArgs: []interface{}{5},
+			},
+		},
+		{
+			name:  "count equality",
+			input: []byte(`{"filter": {"name": "a8m", "orders": {"$count": 0}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "name = ? AND (SELECT COUNT(*) FROM orders WHERE orders.user_id = users.id) = ?",
+				FilterArgs: []interface{}{"a8m", 0},
+			},
+		},
+		{
+			name:  "count with where",
+			input: []byte(`{"filter": {"orders": {"$count": {"$gte": 5}, "$where": {"$and": [{"status": "paid"}, {"total": {"$gt": 10}}]}}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(SELECT COUNT(*) FROM orders WHERE orders.user_id = users.id AND (status = ? AND total > ?)) >= ?",
+				FilterArgs: []interface{}{"paid", 10.0, 5},
+			},
+		},
+		{
+			name:  "has",
+			input: []byte(`{"filter": {"orders": {"$has": true}}}`),
+			wantOut: &Params{
+				Limit:     25,
+				FilterExp: "EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id)",
+			},
+		},
+		{
+			name:  "has false",
+			input: []byte(`{"filter": {"orders": {"$has": false}}}`),
+			wantOut: &Params{
+				Limit:     25,
+				FilterExp: "NOT EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id)",
+			},
+		},
+		{
+			name:  "nhas with filter",
+			input: []byte(`{"filter": {"name": "a8m", "orders": {"$nhas": {"status": "paid"}}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "name = ? AND NOT EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND status = ?)",
+				FilterArgs: []interface{}{"a8m", "paid"},
+			},
+		},
+		{
+			name:  "has with where",
+			input: []byte(`{"filter": {"orders": {"$has": true, "$where": {"total": {"$gt": 10}}}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND total > ?)",
+				FilterArgs: []interface{}{10.0},
+			},
+		},
+		{
+			name:  "nhas with where",
+			input: []byte(`{"filter": {"orders": {"$nhas": true, "$where": {"status": "paid"}}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "NOT EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND status = ?)",
+				FilterArgs: []interface{}{"paid"},
+			},
+		},
+		{
+			name:  "nhas in disjunction",
+			input: []byte(`{"filter": {"$or": [{"name": "a8m"}, {"orders": {"$nhas": {"total": {"$gt": 10}}}}]}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(name = ? OR NOT EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND total > ?))",
+				FilterArgs: []interface{}{"a8m", 10.0},
+			},
+		},
+		{
+			name:    "has with filter and where",
+			input:   []byte(`{"filter": {"orders": {"$has": {"status": "paid"}, "$where": {"total": {"$gt": 10}}}}}`),
+			wantErr: true,
+		},
+		{
+			name:    "invalid has value",
+			input:   []byte(`{"filter": {"orders": {"$has": 1}}}`),
+			wantErr: true,
+		},
+		{
+			name:    "invalid count value",
+			input:   []byte(`{"filter": {"orders": {"$count": {"$gte": -1}}}}`),
+			wantErr: true,
+		},
+		{
+			name:    "invalid count op",
+			input:   []byte(`{"filter": {"orders": {"$count": {"$like": 1}}}}`),
+			wantErr: true,
+		},
+		{
+			name:    "unknown field in where",
+			input:   []byte(`{"filter": {"orders": {"$count": 1, "$where": {"name": "a8m"}}}}`),
+			wantErr: true,
+		},
+		{
+			name:    "missing count",
+			input:   []byte(`{"filter": {"orders": {"$where": {"status": "paid"}}}}`),
+			wantErr: true,
+		},
+	}
+	conf.Log = t.Logf
+	p, err := NewParser(conf)
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := p.Parse(tt.input)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("want: %v\ngot:%v\nerr: %v", tt.wantErr, err != nil, err)
+			}
+			assertParams(t, out, tt.wantOut)
+		})
+	}
+}
+
+func TestJoins(t *testing.T) {
+	type Order struct {
+		Status string  `rql:"filter"`
+		Total  float64 `rql:"filter,sort"`
+	}
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter,sort"`
+		}),
+		FieldSep: ".",
+		Relations: map[string]Relation{
+			"orders": {
+				Table: "orders",
+				Alias: "o",
+				On:    "o.user_id = users.id",
+				Model: Order{},
+			},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"orders.status": "paid", "name": "a8m"}, "sort": ["-orders.total"]}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "o.status = ? AND name = ?",
+		FilterArgs: []interface{}{"paid", "a8m"},
+		Sort:       "o.total desc",
+	})
+	if len(out.Joins) != 1 || out.Joins[0].String() != "JOIN orders AS o ON o.user_id = users.id" {
+		t.Fatalf("unexpected joins: %v", out.Joins)
+	}
+	out, err = p.Parse([]byte(`{"filter": {"name": "a8m"}}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if len(out.Joins) != 0 {
+		t.Fatalf("unexpected joins: %v", out.Joins)
+	}
+	for _, input := range []string{
+		`{"filter": {"orders.unknown": 1}}`,
+		`{"sort": ["orders.status"]}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+}
+
+func TestRelationParser(t *testing.T) {
+	items := MustNewParser(Config{
+		Model: new(struct {
+			SKU string `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	orders := MustNewParser(Config{
+		Model: new(struct {
+			Status string `rql:"filter"`
+		}),
+		ColumnFn: strings.ToUpper,
+		Relations: map[string]Relation{
+			"items": {Table: "items", On: "items.order_id = orders.id", Parser: items},
+		},
+		Log: t.Logf,
+	})
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+		}),
+		Relations: map[string]Relation{
+			"orders": {Table: "orders", On: "orders.user_id = users.id", Parser: orders},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"orders": {"$has": {"$and": [{"STATUS": "paid"}, {"items": {"$has": {"sku": "a8m"}}}]}}}}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND (STATUS = ? AND EXISTS (SELECT 1 FROM items WHERE items.order_id = orders.id AND sku = ?)))",
+		FilterArgs: []interface{}{"paid", "a8m"},
+	})
+	// relation parsers can be changed while they are used by their parent.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			orders.AddField(FieldSpec{Name: "f" + strconv.Itoa(i), Type: reflect.TypeOf(""), Options: "filter"})
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if _, err := p.Parse([]byte(`{"filter": {"orders": {"$has": {"STATUS": "paid"}}}}`)); err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+	}
+	<-done
+	// the filter of a relation is rendered in a subquery, and its joins are not rendered.
+	if _, err := p.Parse([]byte(`{"filter": {"orders": {"$has": {"items_sku": "a8m"}}}}`)); err == nil || !strings.Contains(err.Error(), `fields of relation "items"`) {
+		t.Fatalf("expect error for nested relation path, got: %v", err)
+	}
+	_, err = NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+		}),
+		OpPrefix: "@",
+		Relations: map[string]Relation{
+			"orders": {Table: "orders", On: "orders.user_id = users.id", Parser: orders},
+		},
+	})
+	if err == nil {
+		t.Fatal("expect error for relation parser with different op prefix")
+	}
+}
+
+func TestRelationOptions(t *testing.T) {
+	type Order struct {
+		ManagerID int     `rql:"filter"`
+		Status    string  `rql:"filter"`
+		Total     float64 `rql:"filter"`
+	}
+	tests := []struct {
+		name    string
+		conf    Config
+		input   []byte
+		wantErr bool
+		wantOut *Params
+	}{
+		{
+			name:  "null as is null",
+			conf:  Config{NullAsIsNull: true},
+			input: []byte(`{"filter": {"orders": {"$has": {"manager_id": null}}}}`),
+			wantOut: &Params{
+				Limit:     25,
+				FilterExp: "EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND manager_id IS NULL)",
+			},
+		},
+		{
+			name:  "normalize strings",
+			conf:  Config{NormalizeStrings: TrimSpace},
+			input: []byte(`{"filter": {"orders": {"$has": {"status": " x "}}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND status = ?)",
+				FilterArgs: []interface{}{"x"},
+			},
+		},
+		{
+			name:  "coerce strings",
+			conf:  Config{CoerceStrings: true},
+			input: []byte(`{"filter": {"orders": {"$has": {"manager_id": "42"}}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND manager_id = ?)",
+				FilterArgs: []interface{}{42},
+			},
+		},
+		{
+			name:    "empty values",
+			conf:    Config{EmptyValues: EmptyReject},
+			input:   []byte(`{"filter": {"orders": {"$has": {"status": ""}}}}`),
+			wantErr: true,
+		},
+		{
+			name:    "float precision",
+			conf:    Config{FloatPrecision: 2},
+			input:   []byte(`{"filter": {"orders": {"$has": {"total": 1.234}}}}`),
+			wantErr: true,
+		},
+		{
+			name:    "strict identifiers",
+			conf:    Config{StrictIdentifiers: true, FieldNameFn: Column, ColumnFn: func(s string) string { return Column(s) + "; --" }},
+			input:   []byte(`{"filter": {"orders": {"$has": {"status": "paid"}}}}`),
+			wantErr: true,
+		},
+		{
+			name: "cast",
+			conf: Config{
+				BindStyle: BindDollar,
+				Cast: func(f *FieldMeta) string {
+					if f.Name == "status" {
+						return "text"
+					}
+					return ""
+				},
+			},
+			input: []byte(`{"filter": {"orders": {"$has": {"status": "paid"}}}}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND status = $1::text)",
+				FilterArgs: []interface{}{"paid"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := tt.conf
+			conf.Model = new(struct {
+				Name string `rql:"filter"`
+			})
+			conf.Relations = map[string]Relation{
+				"orders": {Table: "orders", On: "orders.user_id = users.id", Model: Order{}},
+			}
+			conf.Log = t.Logf
+			p, err := NewParser(conf)
+			if err != nil {
+				t.Fatalf("failed to build parser: %v", err)
+			}
+			out, err := p.Parse(tt.input)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("want: %v\ngot:%v\nerr: %v", tt.wantErr, err != nil, err)
+			}
+			if !tt.wantErr {
+				assertParams(t, out, tt.wantOut)
+			}
+		})
+	}
+}
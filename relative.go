@@ -0,0 +1,74 @@
+package rql
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// relativeExpr matches relative time values, like "now", "now-24h" and "now+1h30m".
+var relativeExpr = regexp.MustCompile(`^now(?:([+-])(.+))?$`)
+
+// relativeTime makes the given time field accept relative time values, if the RelativeTime
+// option is enabled. The values are resolved by the parse state before they are validated or
+// converted, and then handled as values in the field layout.
+func (p *Parser) relativeTime(f *field) {
+	if p.conf.RelativeTime {
+		f.relative = f.Layout
+	}
+}
+
+// relative resolves the relative time values of the given field value (or of its elements, for
+// list values) to times in the layout of the field. The current time is resolved once per parse
+// call, so the validation and the conversion of the values, and all values of the query, use the
+// same time.
+func (p *parseState) relative(f *field, v interface{}) (interface{}, error) {
+	if f.relative == "" {
+		return v, nil
+	}
+	switch v := v.(type) {
+	case string:
+		if !relativeExpr.MatchString(v) {
+			return v, nil
+		}
+		t, err := p.resolveTime(v)
+		if err != nil {
+			return nil, err
+		}
+		return t.Format(f.relative), nil
+	case []interface{}:
+		var vs []interface{}
+		for i := range v {
+			e, err := p.relative(f, v[i])
+			if err != nil {
+				return nil, err
+			}
+			if e != v[i] && vs == nil {
+				vs = append([]interface{}(nil), v...)
+			}
+			if vs != nil {
+				vs[i] = e
+			}
+		}
+		if vs != nil {
+			return vs, nil
+		}
+	}
+	return v, nil
+}
+
+// resolveTime returns the time of the given relative time value.
+func (p *parseState) resolveTime(s string) (time.Time, error) {
+	if p.now.IsZero() {
+		p.now = p.conf.Now()
+	}
+	m := relativeExpr.FindStringSubmatch(s)
+	if m[1] == "" {
+		return p.now, nil
+	}
+	d, err := time.ParseDuration(m[1] + m[2])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid relative time %q", s)
+	}
+	return p.now.Add(d), nil
+}
@@ -0,0 +1,112 @@
+package rql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 30, 0, 0, time.UTC)
+	p := MustNewParser(Config{
+		Model: new(struct {
+			CreatedAt time.Time `rql:"filter"`
+			Day       time.Time `rql:"filter,layout=2006-01-02"`
+		}),
+		RelativeTime: true,
+		Now:          func() time.Time { return now },
+		Log:          t.Logf,
+	})
+	tests := []struct {
+		input string
+		want  *Params
+	}{
+		{
+			input: `{"filter": {"created_at": {"$lte": "now"}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "created_at <= ?",
+				FilterArgs: []interface{}{now},
+			},
+		},
+		{
+			input: `{"filter": {"created_at": {"$gte": "now-24h", "$lt": "now+1h30m"}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "(created_at >= ? AND created_at < ?)",
+				FilterArgs: []interface{}{now.Add(-24 * time.Hour), now.Add(90 * time.Minute)},
+			},
+		},
+		{
+			input: `{"filter": {"day": "now-48h"}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "day = ?",
+				FilterArgs: []interface{}{time.Date(2024, 6, 13, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			input: `{"filter": {"created_at": "2024-01-01T00:00:00Z"}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "created_at = ?",
+				FilterArgs: []interface{}{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			out, err := p.Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertParams(t, out, tt.want)
+		})
+	}
+	for _, input := range []string{
+		`{"filter": {"created_at": "now-1x"}}`,
+		`{"filter": {"created_at": "now-"}}`,
+		`{"filter": {"created_at": "tomorrow"}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Errorf("expected error for input: %s", input)
+		}
+	}
+	strict := MustNewParser(Config{
+		Model: new(struct {
+			CreatedAt time.Time `rql:"filter"`
+		}),
+	})
+	if _, err := strict.Parse([]byte(`{"filter": {"created_at": "now"}}`)); err == nil {
+		t.Error("expected error for relative time without the RelativeTime option")
+	}
+}
+
+func TestRelativeTimeNow(t *testing.T) {
+	var calls int
+	start := time.Date(2024, 6, 15, 12, 30, 0, 0, time.UTC)
+	p := MustNewParser(Config{
+		Model: new(struct {
+			CreatedAt time.Time `rql:"filter"`
+		}),
+		RelativeTime: true,
+		// the clock advances on every call.
+		Now: func() time.Time {
+			calls++
+			return start.Add(time.Duration(calls) * time.Second)
+		},
+		Log: t.Logf,
+	})
+	out, err := p.Parse([]byte(`{"filter": {"created_at": {"$gte": "now-1h", "$lt": "now", "$in": ["now", "now+1h"]}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expect Now to be called once per parse, got %d calls", calls)
+	}
+	now := start.Add(time.Second)
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(created_at >= ? AND created_at IN (?, ?) AND created_at < ?)",
+		FilterArgs: []interface{}{now.Add(-time.Hour), now, now.Add(time.Hour), now},
+	})
+}
@@ -3,15 +3,33 @@ package rql
 import (
 	"bytes"
 	"container/list"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"math"
 	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 //go:generate easyjson -omit_empty -disallow_unknown_fields -snake_case rql.go
@@ -40,7 +58,8 @@ type Query struct {
 	//		"sort": ["name", "-age", "+redundant"]
 	//	}`))
 	//
-	Sort []string `json:"sort,omitempty"`
+	// Sort expressions are referenced by objects (see Config.SortExprs).
+	Sort SortFields `json:"sort,omitempty"`
 	// Filter is the query object for building the value for the `WHERE` clause.
 	// The full documentation of the supported operators is writtern in the README.
 	// An example for filter object:
@@ -56,6 +75,50 @@ type Query struct {
 	//	}`))
 	//
 	Filter map[string]interface{} `json:"filter,omitempty"`
+	// Vars holds the values of the variables that are referenced in the filter using
+	// the "$var" operator. Variables that are supplied by the server (see ParseOptions.Vars)
+	// take precedence over these values. For example:
+	//
+	//	params, err := p.Parse([]byte(`{
+	//		"vars": { "min_age": 18 },
+	//		"filter": {
+	//			"age": { "$gt": { "$var": "min_age" } }
+	//		}
+	//	}`))
+	//
+	Vars map[string]interface{} `json:"vars,omitempty"`
+	// After and Before hold a cursor token that was returned by Parser.EncodeCursor, and they are
+	// used for keyset pagination. After returns the page that follows the row of the cursor,
+	// and Before returns the page that precedes it. Only one of them can be set. For example:
+	//
+	//	params, err := p.Parse([]byte(`{
+	//		"sort": ["-created_at", "id"],
+	//		"after": "WzE1OTk...Q"
+	//	}`))
+	//
+	After  string `json:"after,omitempty"`
+	Before string `json:"before,omitempty"`
+	// Search is a free-text term that is matched against the fields that have the "search"
+	// option, using the LIKE operator. For example:
+	//
+	//	params, err := p.Parse([]byte(`{
+	//		"filter": {"status": "active"},
+	//		"search": "a8m"
+	//	}`))
+	//
+	// The term is combined with the filter according to the SearchOp option.
+	Search string `json:"search,omitempty"`
+	// DistinctOn holds the fields of the PostgreSQL DISTINCT ON clause, for returning one row
+	// per group of values, like the latest order of each user. The sort must start with the
+	// same fields, since PostgreSQL keeps the first row of each group. For example:
+	//
+	//	params, err := p.Parse([]byte(`{
+	//		"distinct_on": ["user_id"],
+	//		"sort": ["user_id", "-created_at"]
+	//	}`))
+	//	// params.DistinctOn: "DISTINCT ON (user_id)"
+	//
+	DistinctOn []string `json:"distinct_on,omitempty"`
 }
 
 // Params is the parser output after calling to `Parse`. You should pass its
@@ -81,6 +144,9 @@ type Params struct {
 	Offset int
 	// Select contains the expression for the `SELECT` clause defined in the Query.
 	Select string
+	// DistinctOn is the DISTINCT ON clause of the query, like "DISTINCT ON (user_id)", that
+	// should be written after the SELECT keyword. Empty if the query has no distinct_on fields.
+	DistinctOn string
 	// Sort used as a parameter for the `ORDER BY` clause. For example, "age desc, name".
 	Sort string
 	// FilterExp and FilterArgs come together and used as a parameters for the `WHERE` clause.
@@ -93,13 +159,139 @@ type Params struct {
 	// 	   Args: "a8m", 22
 	FilterExp  string
 	FilterArgs []interface{}
+	// SearchArgs are the LIKE patterns of the search term of the query (see Query.Search), one
+	// for each searchable field. They are included in FilterArgs as well, and they are exposed
+	// for callers that need the search patterns, like highlighting the matches.
+	SearchArgs []string
+	// Joins contains the relations that need to be joined to the query, because
+	// they were used in the filter or sort expressions. For example, with gorm:
+	//
+	//	for _, j := range params.Joins {
+	//		db = db.Joins(j.String())
+	//	}
+	//
+	Joins []JoinClause
+	// Unsatisfiable reports if the filter can never be true, and the query is
+	// expected to return an empty result. It is set only if the parser was
+	// configured with the DetectContradictions option.
+	Unsatisfiable bool
+	// TableHint is the table hint that should be written after the table name, like
+	// "@{FORCE_INDEX=users_by_name}". It is set only by the Spanner dialect.
+	TableHint string
+	// AllowFiltering reports if the query filters by non-key fields, and the "ALLOW FILTERING"
+	// clause must be appended to the statement. It is set only by the CQL dialect.
+	AllowFiltering bool
+	// Reverse reports if the query was sorted in the opposite direction to paginate backwards
+	// (i.e. Query.Before was set), and the caller must reverse the returned rows.
+	Reverse bool
+	// Dropped holds the errors of the query parts that were removed from the query,
+	// when the parser is configured with the Sanitize option.
+	Dropped []*ParseError
+	// Warnings holds the issues that were found in the query, but did not fail it,
+	// like ignored fields in Sanitize mode or a clamped limit. They can be returned
+	// to the client, for example, in the "meta" block of the API response.
+	Warnings []Warning
+	// filter is the parsed expression tree of the filter object, and sort
+	// and selects hold the sort and select expressions that were used.
+	filter  *expr
+	sort    []string
+	selects []string
+	// cursorSort is the sort specification that cursors of the query are bound to.
+	cursorSort string
+	// search is the search term of the query.
+	search string
+	// parser is the parser that created the params. See Params.Usage.
+	parser *Parser
+	// distinctOn are the distinct_on fields of the query.
+	distinctOn []string
+}
+
+// ParseOptions holds per-request overrides of the parser configuration.
+// The zero value of each option means "use the parser configuration".
+type ParseOptions struct {
+	// DefaultLimit overrides the Config.DefaultLimit option.
+	DefaultLimit int
+	// LimitMaxValue overrides the Config.LimitMaxValue option. Unlimited is accepted as well.
+	LimitMaxValue int
+	// DefaultSort overrides the Config.DefaultSort option.
+	DefaultSort []string
+	// AllowedFields restricts the fields that can be used in the filter, sort
+	// and select expressions. Fields that are not in this list are treated as
+	// unrecognized keys. A nil value means that all fields are allowed.
+	AllowedFields []string
+	// ForceIndex is the name of the index that is passed to the FORCE_INDEX hint of the
+	// Spanner dialect. See Params.TableHint.
+	ForceIndex string
+	// Vars holds server-supplied values for the variables that are referenced in the
+	// filter using the "$var" operator. They are validated against the type of the field
+	// they are applied on. Go numeric types are accepted for numbers.
+	Vars map[string]interface{}
+	// Alias is the table alias that the columns of the filter, sort and select expressions
+	// are qualified with. See Parser.ParseWithAlias.
+	Alias string
+	// TotalCount appends the number of rows that match the filter (regardless of the limit and
+	// the offset) to the select expression, as the TotalCountColumn column. It lets list endpoints
+	// retrieve the rows and their total count in one round-trip. For example:
+	//
+	//	{"select": ["id", "name"]}   => "id, name, COUNT(*) OVER() AS total_count"
+	//	{}                           => "*, COUNT(*) OVER() AS total_count"
+	TotalCount bool
 }
 
 // ParseError is type of error returned when there is a parsing problem.
 type ParseError struct {
-	msg string
+	// Code is the category of the error.
+	Code ErrorCode
+	// Field is the query field that caused the error, if any.
+	Field string
+	// Suggestions are the closest names to an unrecognized field or operator, for
+	// "did you mean" messages. It is set only in Suggest mode. See Config.Suggest.
+	Suggestions []string
+	msg         string
 }
 
+// Warning describes an issue in a query that was accepted in a lenient mode.
+type Warning struct {
+	Code    WarningCode `json:"code"`
+	Field   string      `json:"field,omitempty"`
+	Message string      `json:"message"`
+}
+
+// WarningCode is the category of a Warning.
+type WarningCode string
+
+// Warning codes.
+const (
+	WarnIgnoredField    WarningCode = "ignored_field"    // an unknown or disallowed field was removed from the query.
+	WarnIgnoredOp       WarningCode = "ignored_op"       // an operator that can't be applied on the field was removed.
+	WarnIgnoredValue    WarningCode = "ignored_value"    // a term with an invalid value was removed.
+	WarnClampedLimit    WarningCode = "clamped_limit"    // the limit was reduced to the maximum value.
+	WarnDeprecated      WarningCode = "deprecated"       // a deprecated field was used.
+	WarnTruncatedFilter WarningCode = "truncated_filter" // terms were dropped from a filter that exceeds the MaxSQLLength option.
+)
+
+// ErrorCode is the category of a ParseError.
+type ErrorCode string
+
+// Error codes of ParseError.
+const (
+	CodeInvalidJSON     ErrorCode = "invalid_json"      // the input is not a valid JSON query.
+	CodeInvalidQuery    ErrorCode = "invalid_query"     // the query structure is invalid, e.g. "$or" is not an array.
+	CodeUnknownField    ErrorCode = "unknown_field"     // the field does not exist or not allowed.
+	CodeNotFilterable   ErrorCode = "not_filterable"    // the field can not be used in the filter.
+	CodeNotSortable     ErrorCode = "not_sortable"      // the field can not be used in the sort.
+	CodeInvalidOp       ErrorCode = "invalid_op"        // the operator can not be applied on the field.
+	CodeDisabledOp      ErrorCode = "disabled_op"       // the operator is disabled by the DisabledOps option.
+	CodeInvalidValue    ErrorCode = "invalid_value"     // the value does not match the field type or format.
+	CodeInvalidLimit    ErrorCode = "invalid_limit"     // the limit is out of range.
+	CodeInvalidOffset   ErrorCode = "invalid_offset"    // the offset is negative.
+	CodeTooManyArgs     ErrorCode = "too_many_args"     // the filter exceeds the MaxArgs option.
+	CodeTooManyBranches ErrorCode = "too_many_branches" // an "$or" or "$and" array exceeds the MaxBranches option.
+	CodeSQLTooLong      ErrorCode = "sql_too_long"      // the rendered filter exceeds the MaxSQLLength option.
+	CodeUnknownPreset   ErrorCode = "unknown_preset"    // the preset is not registered.
+	CodeInputTooLarge   ErrorCode = "input_too_large"   // the input exceeds the MaxInputBytes or MaxJSONDepth options.
+)
+
 func (p ParseError) Error() string {
 	return p.msg
 }
@@ -108,37 +300,270 @@ func (p ParseError) Error() string {
 type field struct {
 	// Name of the column.
 	Name string
+	// Column is the qualified column of the field (e.g. "orders.total"), if it was
+	// configured with the "column" option. Otherwise, it is derived from the Name.
+	Column string
 	// Has a "sort" option in the tag.
 	Sortable bool
 	// Has a "filter" option in the tag.
 	Filterable bool
+	// Has a "search" option in the tag.
+	Searchable bool
+	// Has a "sensitive" option in the tag. Its values are redacted in Explain and Redacted.
+	Sensitive bool
 	// All supported operators for this field.
 	FilterOps map[string]bool
 	// Validation for the type. for example, unit8 greater than or equal to 0.
 	ValidateFn func(interface{}) error
 	// ConvertFn converts the given value to the type value.
 	CovertFn func(interface{}) interface{}
+	// Type is the (indirect) type of the struct field, and Layout is the time
+	// layout of the field, if it is a time field.
+	Type   reflect.Type
+	Layout string
+	// relative is the layout of the relative time values of the field (or of its elements,
+	// for arrays of times), if it accepts them. See Config.RelativeTime.
+	relative string
+	// Deprecated holds the deprecation message of the field, if it has a "deprecated" option.
+	Deprecated string
+	// Aliases are the former names of the field that are still accepted in queries.
+	Aliases []string
+	// Since and Until are the API versions the field belongs to. See Parser.For.
+	Since string
+	Until string
+	// Expr is the SQL expression of the field, if it was configured with the "expr" option.
+	// In this case, Column holds the expression as well.
+	Expr string
+	// Collate is the collation that is used when sorting by the field.
+	Collate string
+	// Cast is the type that the placeholders of the field values are cast to. For example, "uuid".
+	Cast string
+	// Range is the PostgreSQL range type of the field, if it has the "range" option. For example, "tstzrange".
+	Range string
+	// Key is the role of the field in the primary key, if it has the "partition" or the
+	// "clustering" options. It is used by the CQL dialect.
+	Key KeyKind
+	// meta is the description of the field that is passed to the configuration hooks.
+	meta *FieldMeta
+	// column is the interned column of the field, when it is derived from its name.
+	column string
+	// skipped are the tag options of the field that were ignored. See Parser.Check.
+	skipped []string
+	// parts are the date-part fields of a time field, by their operators. The part fields
+	// are not registered in the parser, and partOf and part hold their time field and operator.
+	parts  map[Op]*field
+	partOf *field
+	part   Op
 }
 
+// FieldMeta describes a field of the parser model, as it is exposed to the query.
+type FieldMeta struct {
+	// Name is the name of the field in the query. For example, "address.name".
+	Name string
+	// Column is the column of the field in the generated SQL.
+	Column string
+	// Type is the Go type of the struct field. Pointers are dereferenced.
+	Type reflect.Type
+	// Layout is the time layout of the field values. Empty for non-time fields.
+	Layout string
+	// Sortable, Filterable and Searchable report if the field has the "sort", the "filter"
+	// and the "search" options.
+	Sortable   bool
+	Filterable bool
+	Searchable bool
+	// Sensitive reports if the field has the "sensitive" option. See Params.Redacted.
+	Sensitive bool
+	// Ops are the operators that can be applied on the field, without the OpPrefix.
+	Ops []Op
+	// Deprecated is the deprecation message of the field. Empty if the field is not deprecated.
+	Deprecated string
+	// Aliases are the additional names that are accepted for the field in queries.
+	Aliases []string
+	// Since and Until are the API versions the field belongs to (until is exclusive).
+	// Empty if the field belongs to all versions.
+	Since string
+	Until string
+	// Key is the role of the field in the primary key. See DialectCQL.
+	Key KeyKind
+	// Cast is the type that the placeholders of the field values are cast to. Empty if the
+	// field has no cast.
+	Cast string
+	// Range is the range type of the field (e.g. "int8range"). Empty if the field has no range option.
+	Range string
+}
+
+// meta returns the description of the field.
+func (p *Parser) meta(f *field) *FieldMeta {
+	m := &FieldMeta{
+		Name:       f.Name,
+		Column:     p.fieldColumn(f),
+		Type:       f.Type,
+		Layout:     f.Layout,
+		Sortable:   f.Sortable,
+		Filterable: f.Filterable,
+		Searchable: f.Searchable,
+		Sensitive:  f.Sensitive,
+		Deprecated: f.Deprecated,
+		Key:        f.Key,
+		Cast:       f.Cast,
+		Range:      f.Range,
+		Aliases:    f.Aliases,
+		Since:      f.Since,
+		Until:      f.Until,
+	}
+	for op := range f.FilterOps {
+		if op := Op(strings.TrimPrefix(op, p.conf.OpPrefix)); !p.disabledOps[op] {
+			m.Ops = append(m.Ops, op)
+		}
+	}
+	sort.Slice(m.Ops, func(i, j int) bool { return m.Ops[i] < m.Ops[j] })
+	return m
+}
+
+// QueryParser is the interface implemented by Parser. Code that depends on parsing
+// (e.g. HTTP handlers) can accept it instead of *Parser, in order to be tested with a
+// fake implementation, like rqltest.Parser.
+type QueryParser interface {
+	Parse(b []byte) (*Params, error)
+	ParseQuery(q *Query) (*Params, error)
+}
+
+var _ QueryParser = (*Parser)(nil)
+
 // A Parser parses various types. The result from the Parse method is a Param object.
-// It is safe for concurrent use by multiple goroutines except for configuration changes.
+// It is safe for concurrent use by multiple goroutines. The configuration is copied
+// when the parser is created, and can't be changed after that.
 type Parser struct {
+	// Config is a copy of the configuration of the parser, with the defaults applied.
+	// It is exported for reading only. The parser uses its own copy of the configuration,
+	// and changes to this field are ignored. They are detected in one of every 64 parse
+	// calls, and reported once using the Log function.
 	Config
-	fields map[string]*field
+	// conf is the configuration that is used by the parser.
+	conf Config
+	// calls counts the parse calls, and changed is set to 1 when a change of the exported
+	// Config was reported, or while the parser is created (e.g. presets are parsed before
+	// the Config is set). See checkConfig.
+	calls   uint32
+	changed int32
+	// mu guards the fields of the parser, that can be changed by AddField and RemoveField.
+	mu        sync.RWMutex
+	fields    map[string]*field
+	relations map[string]*relation
+	// versions caches the parsers that were returned from For.
+	versions *sync.Map
+	// presets holds the decoded filters of Config.Presets.
+	presets map[string]map[string]interface{}
+	// windows holds the rendered expressions of Config.Windows.
+	windows map[string]string
+	// extraOps holds the operators of Config.ExtraOps by their names.
+	extraOps map[Op]*OpSpec
+	// folded maps the lowercased names (and aliases) of the fields to the fields,
+	// when the parser is configured with CaseInsensitiveFields.
+	folded map[string]*field
+	// opAliases maps the aliases of Config.OpAliases to their operators (with the OpPrefix).
+	opAliases map[string]string
+	// disabledOps holds the operators of Config.DisabledOps.
+	disabledOps map[Op]bool
+	// keys maps the renamed keys of Config.Keys to their default names. The default names
+	// of the renamed keys are mapped to an empty string, in order to be rejected.
+	keys map[string]string
+	// softDelete is the DeletedAt field of an embedded gorm.Model. See Config.GormModel.
+	softDelete *field
+	// ops holds the interned names of the operators (with the OpPrefix).
+	ops map[Op]string
+	// skipped are the fields and the tag options that were ignored. See Check.
+	skipped []Skipped
+	// schema is the fingerprint of the parser schema, that namespaces its entries in Config.Cache.
+	// It is computed again when the fields are changed.
+	schema string
 }
 
 // NewParser creates a new Parser. it fails if the configuration is invalid.
 func NewParser(c Config) (*Parser, error) {
+	c = c.clone()
 	if err := c.defaults(); err != nil {
 		return nil, err
 	}
 	p := &Parser{
-		Config: c,
-		fields: make(map[string]*field),
+		conf:      c,
+		fields:    make(map[string]*field),
+		relations: make(map[string]*relation),
+		versions:  &sync.Map{},
+		ops:       make(map[Op]string),
+		changed:   1,
+	}
+	for _, op := range []Op{EQ, NEQ, LT, GT, LTE, GTE, LIKE, IN, NIN, OR, AND, CONTAINS, OVERLAPS, COUNT, HAS, NHAS, WHERE, PRESET, VAR, TUPLE, YEAR, MONTH, WEEK, DAY, DOW, HOUR} {
+		p.ops[op] = c.OpPrefix + string(op)
 	}
 	if err := p.init(); err != nil {
 		return nil, err
 	}
+	if err := p.initExprs(); err != nil {
+		return nil, err
+	}
+	if err := p.initFolded(); err != nil {
+		return nil, err
+	}
+	if c.Collate != nil {
+		for name, f := range p.fields {
+			if name == f.Name && f.Collate == "" {
+				f.Collate = c.Collate(p.meta(f))
+			}
+		}
+	}
+	if c.Cast != nil {
+		for name, f := range p.fields {
+			if name != f.Name || f.Cast != "" {
+				continue
+			}
+			if f.Cast = c.Cast(p.meta(f)); f.Cast != "" && !castType.MatchString(f.Cast) {
+				return nil, fmt.Errorf("rql: invalid cast %q of field %q", f.Cast, f.Name)
+			}
+		}
+	}
+	if err := p.initExtraOps(); err != nil {
+		return nil, err
+	}
+	if err := p.initOpAliases(); err != nil {
+		return nil, err
+	}
+	if err := p.initDisabledOps(); err != nil {
+		return nil, err
+	}
+	p.initKeys()
+	for name, f := range p.fields {
+		if name == f.Name {
+			f.meta = p.meta(f)
+		}
+	}
+	if err := p.initDialect(); err != nil {
+		return nil, err
+	}
+	if err := p.initRelations(); err != nil {
+		return nil, err
+	}
+	if err := p.initPresets(); err != nil {
+		return nil, err
+	}
+	if err := p.initComputed(); err != nil {
+		return nil, err
+	}
+	if err := p.initWindows(); err != nil {
+		return nil, err
+	}
+	if err := p.initSortExprs(); err != nil {
+		return nil, err
+	}
+	if err := p.initPriority(); err != nil {
+		return nil, err
+	}
+	if c.StrictTags && len(p.skipped) > 0 {
+		return nil, fmt.Errorf("rql: strict tags: %s", p.Check().Skipped[0])
+	}
+	p.initSchema()
+	p.Config = p.conf.clone()
+	p.changed = 0
 	return p, nil
 }
 
@@ -152,52 +577,584 @@ func MustNewParser(c Config) *Parser {
 	return p
 }
 
+// Fields returns the description of the fields that are exposed by the parser,
+// sorted by their name.
+func (p *Parser) Fields() []FieldMeta {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	fs := make([]FieldMeta, 0, len(p.fields))
+	for name, f := range p.fields {
+		// skip the aliases of the fields.
+		if name != f.Name {
+			continue
+		}
+		fs = append(fs, *p.meta(f))
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i].Name < fs[j].Name })
+	return fs
+}
+
 // Parse parses the given buffer into a Param object. It returns an error
 // if the JSON is invalid, or its values don't follow the schema of rql.
 func (p *Parser) Parse(b []byte) (pr *Params, err error) {
-	q := &Query{}
-	if err := q.UnmarshalJSON(b); err != nil {
-		return nil, &ParseError{"decoding buffer to *Query: " + err.Error()}
-	}
-	return p.ParseQuery(q)
+	return p.parse(context.Background(), b, ParseOptions{})
+}
+
+// ParseContext is like Parse, but it stops parsing and returns the context error
+// when the given context is canceled or its deadline is exceeded. The context is
+// passed to the AllowOp, ValidateValue, ConvertValue and OnRejectContext hooks.
+func (p *Parser) ParseContext(ctx context.Context, b []byte) (pr *Params, err error) {
+	return p.parse(ctx, b, ParseOptions{})
+}
+
+// ParseWithOptions is like Parse, but the given options override the parser configuration
+// for this call only. It allows one shared parser to serve endpoints with different
+// pagination caps or visibility rules. For example:
+//
+//	params, err := p.ParseWithOptions(b, rql.ParseOptions{
+//		LimitMaxValue: 10,
+//		AllowedFields: []string{"name", "age"},
+//	})
+func (p *Parser) ParseWithOptions(b []byte, opts ParseOptions) (pr *Params, err error) {
+	return p.parse(context.Background(), b, opts)
+}
+
+// ParseWithAlias is like Parse, but the columns of the model are qualified with the given
+// table alias. It allows embedding the params in hand-written queries that join several
+// tables, and have columns with the same name. For example:
+//
+//	params, err := p.ParseWithAlias(b, "u")
+//	// params.FilterExp: "u.name = ? AND u.age > ?"
+//	query := "SELECT u.* FROM users u JOIN orgs o ON o.id = u.org_id WHERE o.active AND " + params.FilterExp
+//
+// Columns that are already qualified (e.g. "column=orders.total"), and fields that are mapped
+// to SQL expressions, are not changed. The filters of relations are rendered in subqueries,
+// and they are not qualified.
+func (p *Parser) ParseWithAlias(b []byte, alias string) (pr *Params, err error) {
+	return p.parse(context.Background(), b, ParseOptions{Alias: alias})
 }
 
 // ParseQuery parses the given struct into a Param object. It returns an error
 // if one of the query values don't follow the schema of rql.
 func (p *Parser) ParseQuery(q *Query) (pr *Params, err error) {
-	defer func() {
-		if e := recover(); e != nil {
-			perr, ok := e.(*ParseError)
-			if !ok {
-				panic(e)
+	return p.parseQuery(context.Background(), q, ParseOptions{})
+}
+
+// ParseQueryContext is like ParseQuery, but it respects the cancellation of the given context.
+func (p *Parser) ParseQueryContext(ctx context.Context, q *Query) (pr *Params, err error) {
+	return p.parseQuery(ctx, q, ParseOptions{})
+}
+
+// ParseQueryWithOptions is like ParseQuery, but the given options override the
+// parser configuration for this call only.
+func (p *Parser) ParseQueryWithOptions(q *Query, opts ParseOptions) (pr *Params, err error) {
+	return p.parseQuery(context.Background(), q, opts)
+}
+
+// ParseAll parses several independent query documents (e.g. one from the user, and one from
+// an embedded widget), and combines them into one Params object. The filters are combined
+// using AND, and the other parts (limit, offset, sort, select, search, distinct_on and the
+// after/before cursor) are taken from the first document that defines them. The variables of
+// each filter are resolved using the "vars" of its own document, and a document can't set the
+// variables of another one. For example:
+//
+//	params, err := p.ParseAll(userQuery, widgetQuery)
+func (p *Parser) ParseAll(queries ...[]byte) (*Params, error) {
+	all := &Query{}
+	var filters []interface{}
+	for i, b := range queries {
+		q := &Query{}
+		if err := p.decode(b, q); err != nil {
+			err.msg = fmt.Sprintf("decoding query %d to *Query: %s", i, err.msg)
+			return nil, p.reject(context.Background(), err)
+		}
+		if len(q.Filter) > 0 {
+			filters = append(filters, p.bindDocVars(q.Filter, q.Vars))
+		}
+		if all.Limit == 0 {
+			all.Limit = q.Limit
+		}
+		if all.Offset == 0 {
+			all.Offset = q.Offset
+		}
+		if len(all.Sort) == 0 {
+			all.Sort = q.Sort
+		}
+		if len(all.Select) == 0 {
+			all.Select = q.Select
+		}
+		if all.Search == "" {
+			all.Search = q.Search
+		}
+		if len(all.DistinctOn) == 0 {
+			all.DistinctOn = q.DistinctOn
+		}
+		// the cursor is taken as a whole, because only one of its directions can be set.
+		if all.After == "" && all.Before == "" {
+			all.After, all.Before = q.After, q.Before
+		}
+	}
+	switch len(filters) {
+	case 0:
+	case 1:
+		all.Filter = filters[0].(map[string]interface{})
+	default:
+		all.Filter = map[string]interface{}{p.op(AND): filters}
+	}
+	return p.parseQuery(context.Background(), all, ParseOptions{})
+}
+
+// bindDocVars returns a copy of the given filter, with the variable references that are defined
+// in the given vars replaced by their values. Undefined variables are left for the parser to
+// reject, and so are all references when the "$var" operator is disabled.
+func (p *Parser) bindDocVars(v interface{}, vars map[string]interface{}) interface{} {
+	if len(vars) == 0 || p.disabledOps[VAR] {
+		return v
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if name, ok := v[p.op(VAR)].(string); ok && len(v) == 1 {
+			if value, ok := vars[name]; ok {
+				return value
+			}
+			return v
+		}
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = p.bindDocVars(e, vars)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = p.bindDocVars(e, vars)
+		}
+		return l
+	}
+	return v
+}
+
+func (p *Parser) parse(ctx context.Context, b []byte, opts ParseOptions) (*Params, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	q := &Query{}
+	if err := p.decode(b, q); err != nil {
+		err.msg = "decoding buffer to *Query: " + err.msg
+		return nil, p.reject(ctx, err)
+	}
+	return p.parseQuery(ctx, q, opts)
+}
+
+// decode decodes the given input into the query, and enforces the MaxInputBytes and
+// MaxJSONDepth options before the input is decoded.
+func (p *Parser) decode(b []byte, q *Query) *ParseError {
+	if max := p.conf.MaxInputBytes; max > 0 && len(b) > max {
+		return &ParseError{Code: CodeInputTooLarge, msg: fmt.Sprintf("input size %d exceeds the maximum of %d bytes", len(b), max)}
+	}
+	if max := p.conf.MaxJSONDepth; max > 0 && jsonDepth(b) > max {
+		return &ParseError{Code: CodeInputTooLarge, msg: fmt.Sprintf("input exceeds the maximum nesting depth of %d", max)}
+	}
+	if p.conf.RejectDuplicateKeys {
+		if k, ok := duplicateKey(b); ok {
+			return &ParseError{Code: CodeInvalidJSON, Field: k, msg: fmt.Sprintf("duplicate key %q", k)}
+		}
+	}
+	if err := p.unmarshal(b, q); err != nil {
+		return &ParseError{Code: CodeInvalidJSON, msg: err.Error()}
+	}
+	return nil
+}
+
+// duplicateKey returns the first key that is repeated in one of the objects of the given
+// JSON input. Invalid inputs are left for the decoder to report.
+func duplicateKey(b []byte) (string, bool) {
+	type frame struct {
+		keys map[string]bool // nil for arrays.
+		key  bool            // reports if the next token of an object is a key.
+	}
+	var stack []*frame
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return "", false
+		}
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		switch t {
+		case json.Delim('{'):
+			stack = append(stack, &frame{keys: make(map[string]bool), key: true})
+			continue
+		case json.Delim('['):
+			stack = append(stack, &frame{})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			if top = nil; len(stack) > 0 {
+				top = stack[len(stack)-1]
+			}
+		default:
+			if k, ok := t.(string); ok && top != nil && top.keys != nil && top.key {
+				if top.keys[k] {
+					return k, true
+				}
+				top.keys[k], top.key = true, false
+				continue
+			}
+		}
+		// a value was completed. In objects, it is followed by a key.
+		if top != nil && top.keys != nil {
+			top.key = true
+		}
+	}
+}
+
+// jsonDepth returns the maximum nesting depth of objects and arrays in the given JSON input.
+// It does not validate the input, and it is used for rejecting pathological inputs before
+// they are decoded recursively.
+func jsonDepth(b []byte) int {
+	var depth, max int
+	var str, esc bool
+	for _, c := range b {
+		switch {
+		case esc:
+			esc = false
+		case str && c == '\\':
+			esc = true
+		case c == '"':
+			str = !str
+		case str:
+		case c == '{' || c == '[':
+			if depth++; depth > max {
+				max = depth
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return max
+}
+
+func (p *Parser) parseQuery(ctx context.Context, q *Query, opts ParseOptions) (pr *Params, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	defer p.catch(ctx, &pr, &err)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ps := p.newParseState(ctx, opts)
+	pr = ps.parse(q, newParams())
+	ps.finish(pr)
+	ps.release()
+	return
+}
+
+// ParseInto is like Parse, but it parses the query into the given params, instead of allocating
+// new ones. The slices of the params (e.g. FilterArgs) are reused, and they must not be used by
+// the caller after the call. It allows services to reuse one Params object per worker:
+//
+//	var params rql.Params
+//	for b := range queries {
+//		if err := p.ParseInto(b, &params); err != nil {
+//			// ...
+//		}
+//	}
+//
+// On error, the content of the params is unspecified.
+func (p *Parser) ParseInto(b []byte, pr *Params) (err error) {
+	q := &Query{}
+	if err := p.decode(b, q); err != nil {
+		err.msg = "decoding buffer to *Query: " + err.msg
+		return p.reject(context.Background(), err)
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var out *Params
+	defer p.catch(context.Background(), &out, &err)
+	ps := p.newParseState(context.Background(), ParseOptions{})
+	out = ps.parse(q, pr)
+	ps.finish(out)
+	ps.release()
+	return nil
+}
+
+// ParseBatch parses many queries in one call, and returns their params and errors in the order
+// of the input. The queries share one parse state, and the lock of the parser is acquired once.
+// It is useful for bulk-export endpoints and message-queue consumers that process query jobs:
+//
+//	params, errs := p.ParseBatch(jobs)
+//	for i := range jobs {
+//		if errs != nil && errs[i] != nil {
+//			// reject job i.
+//			continue
+//		}
+//		// run job i with params[i].
+//	}
+//
+// The errs slice is nil if all queries were parsed successfully. Otherwise, it has the same
+// length as the input, and the params of the failed queries are nil.
+func (p *Parser) ParseBatch(bs [][]byte) ([]*Params, []error) {
+	var (
+		errs []error
+		prs  = make([]*Params, len(bs))
+	)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ps := p.newParseState(context.Background(), ParseOptions{})
+	for i, b := range bs {
+		q := &Query{}
+		var err error
+		if perr := p.decode(b, q); perr != nil {
+			perr.msg = fmt.Sprintf("decoding query %d to *Query: %s", i, perr.msg)
+			err = p.reject(context.Background(), perr)
+		} else {
+			ps.reset(context.Background(), ParseOptions{})
+			prs[i], err = ps.parseBatch(q)
+		}
+		if err != nil {
+			if errs == nil {
+				errs = make([]error, len(bs))
 			}
-			err = perr
-			pr = nil
+			errs[i] = err
+		}
+	}
+	ps.release()
+	return prs, errs
+}
+
+// parseBatch parses one query of a batch, and recovers from its parsing panics.
+func (ps *parseState) parseBatch(q *Query) (pr *Params, err error) {
+	defer ps.catch(ps.ctx, &pr, &err)
+	pr = ps.parse(q, newParams())
+	ps.finish(pr)
+	return
+}
+
+// catch recovers from parsing panics, and sets the returned error accordingly.
+func (p *Parser) catch(ctx context.Context, pr **Params, err *error) {
+	if e := recover(); e != nil {
+		switch e := e.(type) {
+		case *ParseError:
+			*err = p.reject(ctx, e)
+		case ctxError:
+			*err = e.err
+		default:
+			panic(e)
 		}
-	}()
-	pr = &Params{
-		Limit: p.DefaultLimit,
+		*pr = nil
+	}
+}
+
+// parse parses the given query into a Params object, without rendering its filter.
+func (p *parseState) parse(q *Query, pr *Params) *Params {
+	expectStr(p.alias == "" || tableAlias.MatchString(p.alias), CodeInvalidQuery, "", "invalid table alias %q", p.alias)
+	if p.alias == "" {
+		p.alias = p.conf.TableName
 	}
-	expect(q.Offset >= 0, "offset must be greater than or equal to 0")
+	p.prev.sort, p.prev.selects, p.prev.filter = pr.Sort, pr.Select, pr.FilterExp
+	// the slices of reused params are truncated, and their elements are cleared,
+	// in order to not retain the values of the previous query.
+	args, joins := pr.FilterArgs, pr.Joins[:0]
+	for i := range args {
+		args[i] = nil
+	}
+	*pr = Params{
+		Limit:      p.defaultLimit,
+		FilterArgs: args[:0],
+		Joins:      joins,
+		parser:     p.Parser,
+	}
+	p.values = args[:0]
+	if cap(args) == 0 {
+		p.values = make([]interface{}, 0, 8)
+	}
+	expect(q.Offset >= 0, CodeInvalidOffset, "", "%s must be greater than or equal to 0", p.conf.Keys.Offset)
 	pr.Offset = q.Offset
 	if q.Limit != 0 {
-		expect(q.Limit > 0 && q.Limit <= p.LimitMaxValue, "limit must be greater than 0 and less than or equal to %d", p.LimitMaxValue)
+		expect(q.Limit > 0, CodeInvalidLimit, "", "%s must be greater than 0", p.conf.Keys.Limit)
 		pr.Limit = q.Limit
+		if p.conf.ClampLimit && p.limitMaxValue != Unlimited && pr.Limit > p.limitMaxValue {
+			p.warn(WarnClampedLimit, "", "limit %d was reduced to %d", pr.Limit, p.limitMaxValue)
+			pr.Limit = p.limitMaxValue
+		}
+		expect(p.limitMaxValue == Unlimited || pr.Limit <= p.limitMaxValue, CodeInvalidLimit, "", "%s must be greater than 0 and less than or equal to %d", p.conf.Keys.Limit, p.limitMaxValue)
 	}
-	ps := p.newParseState()
-	ps.and(q.Filter)
-	pr.FilterExp = ps.String()
-	pr.FilterArgs = ps.values
-	pr.Sort = p.sort(q.Sort)
-	if len(pr.Sort) == 0 && len(p.DefaultSort) > 0 {
-		pr.Sort = p.sort(p.DefaultSort)
+	p.queryVars = q.Vars
+	pr.filter = p.and(q.Filter)
+	if q.Search != "" {
+		p.search(pr, q.Search)
 	}
-	for _, s := range q.Select {
-		expect(p.fields[s] != nil, "unrecognized selection key %q", s)
+	if f := p.softDelete; f != nil && p.get(f.Name) == f {
+		var used bool
+		walk(pr.filter, func(e *expr) { used = used || e.field == f })
+		if !used {
+			e := p.nullPredicate(f, nil, EQ)
+			e.implicit = true
+			pr.filter.add(e)
+		}
 	}
-	pr.Select = strings.Join(q.Select, ", ")
-	parseStatePool.Put(ps)
-	return
+	pr.sort = q.Sort
+	if len(pr.sort) == 0 {
+		pr.sort = p.defaultSort
+	}
+	pr.Sort, pr.sort = p.sort(pr.sort)
+	pr.cursorSort = pr.Sort
+	if len(q.DistinctOn) > 0 {
+		p.distinctOn(pr, q.DistinctOn)
+	}
+	if q.After != "" || q.Before != "" {
+		p.cursor(pr, q.After, q.Before)
+	}
+	pr.Select, pr.selects = p.selects(q.Select)
+	if p.totalCount {
+		pr.Select = p.total(pr.Select)
+	}
+	pr.Dropped = p.dropped
+	pr.Warnings = p.warnings
+	pr.AllowFiltering = p.allowFiltering
+	if p.forceIndex != "" {
+		expect(p.conf.Dialect == DialectSpanner, CodeInvalidQuery, "", "index hints are supported only by the Spanner dialect")
+		pr.TableHint = forceIndex(p.forceIndex)
+	}
+	for _, r := range p.joins {
+		pr.Joins = append(pr.Joins, JoinClause{Table: r.Table, Alias: r.Alias, On: r.On})
+	}
+	return pr
+}
+
+// finish applies the configured transformations on the filter of the given params,
+// and renders it.
+func (p *parseState) finish(pr *Params) {
+	if p.conf.Normalize {
+		pr.filter = normalize(pr.filter)
+	}
+	if p.conf.DetectContradictions {
+		pr.Unsatisfiable = unsatisfiable(pr.filter)
+	}
+	if p.conf.FilterPriority != nil {
+		p.prioritize(pr.filter)
+	}
+	if p.conf.InChunkSize > 0 {
+		pr.filter = chunk(pr.filter, p.conf.InChunkSize)
+	}
+	if p.conf.Dialect == DialectSpanner {
+		walk(pr.filter, func(e *expr) {
+			e.unnest = e.field != nil && e.op.list()
+		})
+	}
+	p.render(pr.filter)
+	if p.conf.MaxSQLLength > 0 && p.Len() > p.conf.MaxSQLLength {
+		p.truncate(pr)
+	}
+	expect(p.conf.MaxArgs == 0 || len(p.values) <= p.conf.MaxArgs, CodeTooManyArgs, "", "too many filter arguments: %d (max %d)", len(p.values), p.conf.MaxArgs)
+	pr.FilterExp = reuse(p.Bytes(), p.prev.filter)
+	pr.FilterArgs = p.values
+}
+
+// truncate drops the last terms of the filter conjunction, until its rendering fits the MaxSQLLength
+// option. It fails the parsing if the parser is not in Sanitize mode.
+func (p *parseState) truncate(pr *Params) {
+	size, max := p.Len(), p.conf.MaxSQLLength
+	expect(p.sanitize, CodeSQLTooLong, "", "filter expression is too long: %d bytes (max %d)", size, max)
+	e, n := pr.filter, 0
+	for p.Len() > max {
+		c := dropLast(e)
+		if c == nil {
+			break
+		}
+		e, n = c, n+1
+		p.Reset()
+		p.values, p.args = p.values[:0], nil
+		p.render(e)
+	}
+	expect(p.Len() <= max, CodeSQLTooLong, "", "filter expression is too long: %d bytes (max %d)", p.Len(), max)
+	pr.filter = e
+	pr.Warnings = append(pr.Warnings, Warning{
+		Code:    WarnTruncatedFilter,
+		Message: fmt.Sprintf("filter expression of %d bytes exceeds the maximum of %d bytes, %d of its terms were dropped", size, max, n),
+	})
+}
+
+// dropLast returns a copy of the given filter without its last term. Conjunctions with one term
+// (e.g. an "$and" array) are truncated in their terms, and other filters are dropped entirely.
+// The terms that were added by the parser (e.g. the soft-delete predicate) are never dropped,
+// and nil is returned if there is nothing to drop. The filter is copied, because the filter of
+// compiled queries is shared between their executions.
+func dropLast(e *expr) *expr {
+	switch {
+	case e.implicit:
+		return nil
+	case !e.group() || e.op != AND:
+		return &expr{op: AND}
+	}
+	last, terms := -1, 0
+	for i, c := range e.children {
+		if !c.implicit {
+			last, terms = i, terms+1
+		}
+	}
+	if last == -1 {
+		return nil
+	}
+	c := *e
+	c.children = append([]*expr(nil), e.children...)
+	if t := e.children[last]; terms == 1 && t.group() && t.op == AND {
+		if c.children[last] = dropLast(t); c.children[last] == nil {
+			return nil
+		}
+	} else {
+		c.children = append(c.children[:last], c.children[last+1:]...)
+	}
+	return &c
+}
+
+// release returns the parse state to the pool.
+func (p *parseState) release() {
+	// the state doesn't retain the parser, the options and the values of the last parse
+	// call, and the expressions of its params. The other fields are cleared by reset.
+	*p = parseState{Buffer: p.Buffer, nodes: p.nodes, links: p.links, scratch: p.scratch}
+	parseStatePool.Put(p)
+}
+
+var paramsPool sync.Pool
+
+// newParams returns params from the pool, or allocates new ones.
+func newParams() *Params {
+	if v := paramsPool.Get(); v != nil {
+		return v.(*Params)
+	}
+	return &Params{}
+}
+
+// Release returns the params to a pool, and they are reused by the next calls to the Parse
+// methods. It allows high-QPS services to avoid the allocation of the params, their filter
+// arguments and their joins, and the expressions that didn't change between the requests.
+// For example:
+//
+//	params, err := p.Parse(b)
+//	if err != nil {
+//		return err
+//	}
+//	defer params.Release()
+//
+// The params, and their slices, must not be used after the call. Calling Release is
+// optional, and params that are not released are collected by the garbage collector.
+func (pr *Params) Release() {
+	*pr = Params{
+		Select:     pr.Select,
+		Sort:       pr.Sort,
+		FilterExp:  pr.FilterExp,
+		FilterArgs: pr.FilterArgs,
+		Joins:      pr.Joins[:0],
+	}
+	for i := range pr.FilterArgs {
+		pr.FilterArgs[i] = nil
+	}
+	pr.FilterArgs = pr.FilterArgs[:0]
+	paramsPool.Put(pr)
 }
 
 // Column is the default function that converts field name into a database column.
@@ -206,16 +1163,20 @@ func (p *Parser) ParseQuery(q *Query) (pr *Params, err error) {
 //	Username => username
 //	FullName => full_name
 //	HTTPCode => http_code
+//	ÜberName => über_name
 func Column(s string) string {
-	var b strings.Builder
-	for i := 0; i < len(s); i++ {
-		r := rune(s[i])
-		// put '.' if it is not a start or end of a word, current letter is an uppercase letter,
+	var (
+		b  strings.Builder
+		rs = []rune(s)
+	)
+	b.Grow(len(s) + 2)
+	for i, r := range rs {
+		// put '_' if it is not a start or end of a word, current letter is an uppercase letter,
 		// and previous letter is a lowercase letter (cases like: "UserName"), or next letter is
 		// also a lowercase letter and previous letter is not "_".
-		if i > 0 && i < len(s)-1 && unicode.IsUpper(r) &&
-			(unicode.IsLower(rune(s[i-1])) ||
-				unicode.IsLower(rune(s[i+1])) && unicode.IsLetter(rune(s[i-1]))) {
+		if i > 0 && i < len(rs)-1 && unicode.IsUpper(r) &&
+			(unicode.IsLower(rs[i-1]) ||
+				unicode.IsLower(rs[i+1]) && unicode.IsLetter(rs[i-1])) {
 			b.WriteString("_")
 		}
 		b.WriteRune(unicode.ToLower(r))
@@ -223,59 +1184,256 @@ func Column(s string) string {
 	return b.String()
 }
 
-// init initializes the parser parsing state. it scans the fields
-// in a breath-first-search order and for each one of the field calls parseField.
+// CamelCase is a function for the FieldNameFn option that converts the struct fields
+// into camelCase query fields. For example:
+//
+//	Username => username
+//	FullName => fullName
+//	HTTPCode => httpCode
+func CamelCase(s string) string {
+	parts := strings.Split(Column(s), "_")
+	for i := 1; i < len(parts); i++ {
+		if r, n := utf8.DecodeRuneInString(parts[i]); parts[i] != "" {
+			parts[i] = string(unicode.ToUpper(r)) + parts[i][n:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// KebabCase is a function for the FieldNameFn option that converts the struct fields
+// into kebab-case query fields. For example:
+//
+//	Username => username
+//	FullName => full-name
+//	HTTPCode => http-code
+func KebabCase(s string) string {
+	return strings.Replace(Column(s), "_", "-", -1)
+}
+
+// ScreamingSnakeCase is a function for the ColumnFn (or the FieldNameFn) option that
+// converts the struct fields into uppercase names. For example:
+//
+//	Username => USERNAME
+//	FullName => FULL_NAME
+//	HTTPCode => HTTP_CODE
+func ScreamingSnakeCase(s string) string {
+	return strings.ToUpper(Column(s))
+}
+
+// init initializes the parser parsing state. it scans the fields in a breath-first-search
+// order, and creates a field (see newField) for each one of the tagged fields.
 func (p *Parser) init() error {
-	t := indirect(reflect.TypeOf(p.Model))
+	start := time.Now()
+	t := indirect(reflect.TypeOf(p.conf.Model))
 	l := list.New()
 	for i := 0; i < t.NumField(); i++ {
 		l.PushFront(t.Field(i))
 	}
+	// the struct fields are collected first, and their fields are created concurrently
+	// for large models. gorm reports the fields of an embedded gorm.Model.
+	var sfs []reflect.StructField
+	var gorm []bool
 	for l.Len() > 0 {
 		f := l.Remove(l.Front()).(reflect.StructField)
-		_, ok := f.Tag.Lookup(p.TagName)
+		_, ok := f.Tag.Lookup(p.conf.TagName)
 		switch t := indirect(f.Type); {
 		// no matter what the type of this field. if it has a tag,
 		// it is probably a filterable or sortable.
 		case ok:
-			if err := p.parseField(f); err != nil {
-				return err
+			sfs, gorm = append(sfs, f), append(gorm, false)
+		case p.conf.GormModel && f.Anonymous && gormModel(t):
+			for _, sf := range p.gormFields(t) {
+				sfs, gorm = append(sfs, sf), append(gorm, true)
 			}
 		case t.Kind() == reflect.Struct:
 			for i := 0; i < t.NumField(); i++ {
 				f1 := t.Field(i)
 				if !f.Anonymous {
-					f1.Name = f.Name + p.FieldSep + f1.Name
+					f1.Name = f.Name + p.conf.FieldSep + f1.Name
 				}
 				l.PushFront(f1)
 			}
 		case f.Anonymous:
-			p.Log("ignore embedded field %q that is not struct type", f.Name)
+			p.conf.Log("ignore embedded field %q that is not struct type", f.Name)
+			p.skipped = append(p.skipped, Skipped{Field: f.Name, Reason: "embedded field that is not struct type"})
+		}
+	}
+	fields, err := p.newFields(sfs)
+	if err != nil {
+		return err
+	}
+	for i, f := range fields {
+		if err := p.addField(f); err != nil {
+			return err
+		}
+		for _, r := range f.skipped {
+			p.skipped = append(p.skipped, Skipped{Field: sfs[i].Name, Reason: r})
 		}
+		if gorm[i] && sfs[i].Name == "DeletedAt" {
+			p.softDelete = f
+		}
+	}
+	if len(sfs) >= concurrentFields {
+		p.conf.Log("rql: created %d fields of model %v in %v", len(fields), t, time.Since(start))
 	}
 	return nil
 }
 
-// parseField parses the given struct field tag, and add a rule
-// in the parser according to its type and the options that were set on the tag.
-func (p *Parser) parseField(sf reflect.StructField) error {
-	f := &field{
-		Name:      p.ColumnFn(sf.Name),
-		CovertFn:  valueFn,
+// concurrentFields is the number of fields from which the fields of a model are created concurrently.
+const concurrentFields = 64
+
+// newFields creates the fields of the given struct fields. The fields of large models are
+// created concurrently, and they are returned in the order of the struct fields, in order
+// to keep their registration, and its errors, deterministic.
+func (p *Parser) newFields(sfs []reflect.StructField) ([]*field, error) {
+	fields := make([]*field, len(sfs))
+	workers := runtime.GOMAXPROCS(0)
+	if len(sfs) < concurrentFields || workers == 1 {
+		for i, sf := range sfs {
+			f, err := p.newField(sf, p.conf.Log)
+			if err != nil {
+				return nil, err
+			}
+			fields[i] = f
+		}
+		return fields, nil
+	}
+	// the Log function is not required to be safe for concurrent use.
+	var mu sync.Mutex
+	logf := func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		p.conf.Log(format, args...)
+	}
+	var (
+		wg   sync.WaitGroup
+		next int64 = -1
+		errs       = make([]error, len(sfs))
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(atomic.AddInt64(&next, 1)); i < len(sfs); i = int(atomic.AddInt64(&next, 1)) {
+				fields[i], errs[i] = p.newField(sfs[i], logf)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// gormModel reports if the given type is the gorm.Model of jinzhu/gorm or gorm.io/gorm.
+func gormModel(t reflect.Type) bool {
+	return t.Name() == "Model" && (t.PkgPath() == "github.com/jinzhu/gorm" || t.PkgPath() == "gorm.io/gorm")
+}
+
+// gormFields returns the fields of an embedded gorm.Model, tagged as filterable and sortable.
+func (p *Parser) gormFields(t reflect.Type) []reflect.StructField {
+	sfs := make([]reflect.StructField, t.NumField())
+	for i := range sfs {
+		sfs[i] = t.Field(i)
+		sfs[i].Tag = reflect.StructTag(p.conf.TagName + `:"filter,sort"`)
+	}
+	return sfs
+}
+
+// newField creates a field from the given struct field, according to its type and the
+// options that were set on its tag. Warnings about the tag are reported to logf.
+func (p *Parser) newField(sf reflect.StructField, logf func(string, ...interface{})) (*field, error) {
+	f := &field{
+		Name:      p.conf.ColumnFn(sf.Name),
+		CovertFn:  valueFn,
 		FilterOps: make(map[string]bool),
 	}
-	layout := time.RFC3339
-	opts := strings.Split(sf.Tag.Get(p.TagName), ",")
+	if p.conf.FieldNameFn != nil {
+		f.Name = p.conf.FieldNameFn(sf.Name)
+		f.Column = p.colName(p.conf.ColumnFn(sf.Name))
+	}
+	layout, converter, digest, oid, enum, as, rng, money := time.RFC3339, "", "", "", "", "", "", ""
+	tag := sf.Tag.Get(p.conf.TagName)
+	// the "expr" option must be the last one, because the SQL expression may contain commas.
+	if i := strings.Index(tag, "expr="); i == 0 || i > 0 && tag[i-1] == ',' {
+		f.Expr = strings.TrimSpace(tag[i+len("expr="):])
+		if f.Expr == "" {
+			return nil, fmt.Errorf("rql: empty expr for field %q", sf.Name)
+		}
+		f.Column = f.Expr
+		tag = strings.TrimSuffix(tag[:i], ",")
+	}
+	opts := strings.Split(tag, ",")
 	for _, opt := range opts {
 		switch s := strings.TrimSpace(opt); {
 		case s == "sort":
 			f.Sortable = true
 		case s == "filter":
 			f.Filterable = true
-		case strings.HasPrefix(opt, "column"):
-			f.Name = strings.TrimPrefix(opt, "column=")
-		case strings.HasPrefix(opt, "layout"):
-			layout = strings.TrimPrefix(opt, "layout=")
+		case s == "partition":
+			f.Key = PartitionKey
+		case s == "clustering":
+			f.Key = ClusteringKey
+		case s == "search":
+			f.Searchable = true
+		case s == "sensitive":
+			f.Sensitive = true
+		case strings.HasPrefix(s, "column"):
+			// a qualified column (e.g. "orders.total") of a view or a join, doesn't
+			// change the name of the field in the query. Only its column, which is
+			// quoted by the dialect. With FieldNameFn, the name of the field is also preserved.
+			switch c := strings.TrimPrefix(s, "column="); {
+			case strings.Contains(c, "."):
+				f.Column = quoteColumn(p.conf.Dialect, c)
+			case p.conf.FieldNameFn != nil:
+				f.Column = c
+			default:
+				f.Name = c
+			}
+		case strings.HasPrefix(s, "deprecated"):
+			// the message is optional, and usually points to the replacement of the field.
+			f.Deprecated = "it will be removed in a future version"
+			if msg := strings.TrimPrefix(s, "deprecated="); msg != s && msg != "" {
+				f.Deprecated = msg
+			}
+		case strings.HasPrefix(s, "alias="):
+			// aliases are separated by "|", because "," is the options separator.
+			for _, a := range strings.Split(strings.TrimPrefix(s, "alias="), "|") {
+				if a = strings.TrimSpace(a); a != "" {
+					f.Aliases = append(f.Aliases, a)
+				}
+			}
+		case strings.HasPrefix(s, "collate="):
+			f.Collate = strings.TrimPrefix(s, "collate=")
+		case strings.HasPrefix(s, "cast="):
+			if f.Cast = strings.TrimPrefix(s, "cast="); !castType.MatchString(f.Cast) {
+				return nil, fmt.Errorf("rql: invalid cast %q of field %q", f.Cast, sf.Name)
+			}
+		case strings.HasPrefix(s, "objectid="):
+			oid = strings.TrimPrefix(s, "objectid=")
+		case strings.HasPrefix(s, "enummap="):
+			enum = strings.TrimPrefix(s, "enummap=")
+		case strings.HasPrefix(s, "as="):
+			as = strings.TrimPrefix(s, "as=")
+		case strings.HasPrefix(s, "range="):
+			rng = strings.TrimPrefix(s, "range=")
+		case s == "money":
+			money = "2"
+		case strings.HasPrefix(s, "money="):
+			money = strings.TrimPrefix(s, "money=")
+		case strings.HasPrefix(s, "hash="):
+			digest = strings.TrimPrefix(s, "hash=")
+		case strings.HasPrefix(s, "convert="):
+			converter = strings.TrimPrefix(s, "convert=")
+		case strings.HasPrefix(s, "since="):
+			f.Since = strings.TrimPrefix(s, "since=")
+		case strings.HasPrefix(s, "until="):
+			f.Until = strings.TrimPrefix(s, "until=")
+		case strings.HasPrefix(s, "layout"):
+			layout = strings.TrimPrefix(s, "layout=")
 			// if it's one of the standard layouts, like: RFC822 or Kitchen.
 			if ly, ok := layouts[layout]; ok {
 				layout = ly
@@ -285,224 +1443,1033 @@ func (p *Parser) parseField(sf reflect.StructField) error {
 			// Z for zone information.
 			v := strings.NewReplacer("_", " ", "Z", "+").Replace(layout)
 			if _, err := time.Parse(layout, v); err != nil {
-				return fmt.Errorf("rql: layout %q is not parsable: %v", layout, err)
+				return nil, fmt.Errorf("rql: layout %q is not parsable: %v", layout, err)
 			}
 		default:
-			p.Log("Ignoring unknown option %q in struct tag", opt)
+			logf("Ignoring unknown option %q in struct tag", opt)
+			f.skipped = append(f.skipped, fmt.Sprintf("unknown option %q", opt))
 		}
 	}
+	if f.Since != "" && f.Until != "" && compareVersion(f.Since, f.Until) >= 0 {
+		return nil, fmt.Errorf("rql: field %q: since version %q must be lower than until version %q", sf.Name, f.Since, f.Until)
+	}
 	var filterOps []Op
-	switch typ := indirect(sf.Type); typ.Kind() {
+	f.Type = indirect(sf.Type)
+	// the sql.Null* types are validated and converted like the types of their values.
+	typ := f.Type
+	if t, ok := nullType(typ); ok {
+		typ = t
+	}
+	// interface fields are treated as the type of their hint. e.g. "as=jsonb".
+	if as != "" {
+		t, ok := hintTypes[as]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("rql: as option of field %q must be one of string, int, float, bool, time, json or jsonb", sf.Name)
+		case typ.Kind() != reflect.Interface:
+			return nil, fmt.Errorf("rql: as option of field %q is supported only on interface fields", sf.Name)
+		}
+		typ = t
+	}
+	// range fields are validated and converted like their elements. e.g. "range=tstz".
+	rt, isRange := rangeTypes[rng]
+	if rng != "" {
+		switch {
+		case !isRange:
+			return nil, fmt.Errorf("rql: range option of field %q must be one of int4, int8, num, ts, tstz or date", sf.Name)
+		case p.conf.Dialect != DialectSQL:
+			return nil, fmt.Errorf("rql: range option of field %q is supported only by the SQL dialect", sf.Name)
+		}
+		typ = rt.elem
+	}
+	ft, custom := p.conf.Types[typ]
+	if custom {
+		if ft.Underlying == nil {
+			return nil, fmt.Errorf("rql: custom type %v of field %q must have an underlying type", typ, sf.Name)
+		}
+		typ = ft.Underlying
+	}
+	switch typ.Kind() {
 	case reflect.Bool:
 		f.ValidateFn = validateBool
 		filterOps = append(filterOps, EQ, NEQ)
 	case reflect.String:
 		f.ValidateFn = validateString
 		filterOps = append(filterOps, EQ, NEQ, LT, LTE, GT, GTE, LIKE)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		f.ValidateFn = validateInt
-		f.CovertFn = convertInt
-		filterOps = append(filterOps, EQ, NEQ, LT, LTE, GT, GTE)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		f.ValidateFn = validateUInt
-		f.CovertFn = convertInt
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f.ValidateFn = validateIntKind(typ)
+		f.CovertFn = convertIntKind(typ)
 		filterOps = append(filterOps, EQ, NEQ, LT, LTE, GT, GTE)
 	case reflect.Float32, reflect.Float64:
-		f.ValidateFn = validateFloat
+		f.ValidateFn = p.validateFloatKind(typ)
+		filterOps = append(filterOps, EQ, NEQ, LT, LTE, GT, GTE)
+	case reflect.Slice:
+		switch {
+		case jsonDoc(typ):
+			// JSON documents accept any JSON value. Objects must be wrapped with an
+			// operator (e.g. {"$eq": {...}}), in order to be distinguished from operators.
+			f.ValidateFn = validateJSON
+			f.CovertFn = convertJSON(typ)
+			filterOps = append(filterOps, CONTAINS)
+		case typ.Elem().Kind() == reflect.Uint8:
+			f.ValidateFn = validateBytes
+			f.CovertFn = convertBytes
+		case typ.Implements(valuerType) && typ.Elem() != typ:
+			// arrays that are encoded by their Value method, like datatypes.JSONSlice[T] or
+			// pq.StringArray. Their elements are validated like fields of the element type.
+			ef, err := p.newField(reflect.StructField{
+				Name: sf.Name,
+				Type: typ.Elem(),
+				Tag:  reflect.StructTag(p.conf.TagName + `:"filter"`),
+			}, logf)
+			if err != nil {
+				return nil, err
+			}
+			if ef.Layout != "" {
+				ef.Layout = layout
+				ef.ValidateFn = validateTime(layout)
+				ef.CovertFn = convertTime(layout)
+				p.relativeTime(ef)
+			}
+			f.relative = ef.relative
+			f.ValidateFn = func(v interface{}) error {
+				_, err := arrayValue(typ, ef, v)
+				return err
+			}
+			f.CovertFn = func(v interface{}) interface{} {
+				a, _ := arrayValue(typ, ef, v)
+				return a
+			}
+			filterOps = append(filterOps, CONTAINS)
+		default:
+			return nil, fmt.Errorf("rql: field type for %q is not supported", sf.Name)
+		}
+		filterOps = append(filterOps, EQ, NEQ)
+	case reflect.Array:
+		if !objectID(typ) {
+			return nil, fmt.Errorf("rql: field type for %q is not supported", sf.Name)
+		}
+		convert, ok := objectIDFormats[oid]
+		if !ok {
+			return nil, fmt.Errorf("rql: objectid option of field %q must be one of hex, bytes or native", sf.Name)
+		}
+		f.ValidateFn = validateObjectID
+		f.CovertFn = func(v interface{}) interface{} {
+			return convert(typ, v.(string))
+		}
 		filterOps = append(filterOps, EQ, NEQ, LT, LTE, GT, GTE)
 	case reflect.Struct:
 		switch v := reflect.Zero(typ); v.Interface().(type) {
-		case sql.NullBool:
-			f.ValidateFn = validateBool
-			filterOps = append(filterOps, EQ, NEQ)
 		case sql.NullString:
 			f.ValidateFn = validateString
 			filterOps = append(filterOps, EQ, NEQ)
-		case sql.NullInt64:
-			f.ValidateFn = validateInt
-			f.CovertFn = convertInt
-			filterOps = append(filterOps, EQ, NEQ, LT, LTE, GT, GTE)
-		case sql.NullFloat64:
-			f.ValidateFn = validateFloat
-			filterOps = append(filterOps, EQ, NEQ, LT, LTE, GT, GTE)
 		case time.Time:
+			f.Layout = layout
 			f.ValidateFn = validateTime(layout)
 			f.CovertFn = convertTime(layout)
 			filterOps = append(filterOps, EQ, NEQ, LT, LTE, GT, GTE)
 		default:
 			if !v.Type().ConvertibleTo(reflect.TypeOf(time.Time{})) {
-				return fmt.Errorf("rql: field type for %q is not supported", sf.Name)
+				return nil, fmt.Errorf("rql: field type for %q is not supported", sf.Name)
 			}
+			f.Layout = layout
 			f.ValidateFn = validateTime(layout)
 			f.CovertFn = convertTime(layout)
 			filterOps = append(filterOps, EQ, NEQ, LT, LTE, GT, GTE)
 		}
+	case reflect.Interface:
+		return nil, fmt.Errorf("rql: field type for %q is not supported, use the as option for setting its type (e.g. as=jsonb)", sf.Name)
 	default:
-		return fmt.Errorf("rql: field type for %q is not supported", sf.Name)
+		return nil, fmt.Errorf("rql: field type for %q is not supported", sf.Name)
+	}
+	// money fields accept decimal strings, that are converted to integer minor units.
+	if money != "" {
+		scale, err := strconv.Atoi(money)
+		switch {
+		case err != nil || scale < 0 || scale > maxMoneyScale:
+			return nil, fmt.Errorf("rql: money option of field %q must be a scale between 0 and %d", sf.Name, maxMoneyScale)
+		case intTypes[typ.Kind()] == nil:
+			return nil, fmt.Errorf("rql: money option of field %q is supported only on signed integer fields", sf.Name)
+		}
+		f.ValidateFn, f.CovertFn = validateMoney(scale, typ), convertMoney(scale, typ)
+	}
+	if isRange {
+		// the operators of the elements are replaced by the operators of ranges.
+		filterOps = []Op{CONTAINS, OVERLAPS}
+		f.Range = rt.name
+		if f.Cast == "" {
+			f.Cast = rt.cast
+		}
+	} else {
+		filterOps = append(filterOps, IN, NIN)
 	}
 	for _, op := range filterOps {
 		f.FilterOps[p.op(op)] = true
 	}
+	if f.Searchable && !f.FilterOps[p.op(LIKE)] {
+		return nil, fmt.Errorf("rql: search option of field %q is supported only on string fields", sf.Name)
+	}
+	if validate := f.ValidateFn; custom && ft.Validator != nil {
+		f.ValidateFn = func(v interface{}) error {
+			if err := validate(v); err != nil {
+				return err
+			}
+			return ft.Validator(v)
+		}
+	}
+	if convert := f.CovertFn; custom && ft.Converter != nil {
+		f.CovertFn = func(v interface{}) interface{} {
+			return ft.Converter(convert(v))
+		}
+	}
+	if parse := coerceFn(typ); p.conf.CoerceStrings && parse != nil && money == "" {
+		validate, convert := f.ValidateFn, f.CovertFn
+		f.ValidateFn = func(v interface{}) error {
+			if s, ok := v.(string); ok {
+				c, err := parse(s)
+				if err != nil {
+					return err
+				}
+				v = c
+			}
+			return validate(v)
+		}
+		f.CovertFn = func(v interface{}) interface{} {
+			if s, ok := v.(string); ok {
+				v, _ = parse(s)
+			}
+			return convert(v)
+		}
+	}
+	if f.Layout != "" {
+		p.relativeTime(f)
+	}
+	if f.Layout != "" && !isRange {
+		p.dateParts(f)
+	}
+	// enum names are resolved before the coercion of strings, because they are not numbers.
+	if enums, err := p.enumValues(typ, enum); err != nil {
+		return nil, fmt.Errorf("rql: field %q: %v", sf.Name, err)
+	} else if enums != nil {
+		validate, convert := f.ValidateFn, f.CovertFn
+		f.ValidateFn = func(v interface{}) error {
+			if s, ok := v.(string); ok {
+				if _, ok := enums(s); !ok {
+					return fmt.Errorf("unknown value %q", s)
+				}
+				return nil
+			}
+			return validate(v)
+		}
+		f.CovertFn = func(v interface{}) interface{} {
+			if s, ok := v.(string); ok {
+				v, _ = enums(s)
+			}
+			return convert(v)
+		}
+	}
+	if oid != "" && !objectID(typ) {
+		return nil, fmt.Errorf("rql: objectid option of field %q is supported only on ObjectID fields", sf.Name)
+	}
+	if digest != "" {
+		h, ok := hashes[digest]
+		if !ok || f.Type.Kind() != reflect.Slice || f.Type.Elem().Kind() != reflect.Uint8 || jsonDoc(f.Type) {
+			return nil, fmt.Errorf("rql: hash option of field %q must be one of sha1, sha256 or sha512, and it is supported only on []byte fields", sf.Name)
+		}
+		f.CovertFn = func(v interface{}) interface{} {
+			w := h()
+			w.Write(convertBytes(v).([]byte))
+			return w.Sum(nil)
+		}
+	}
+	if converter != "" {
+		c, ok := p.conf.Converters[converter]
+		if !ok {
+			return nil, fmt.Errorf("rql: unknown converter %q for field %q", converter, sf.Name)
+		}
+		convert := f.CovertFn
+		f.CovertFn = func(v interface{}) interface{} {
+			return c(convert(v))
+		}
+	}
+	return f, nil
+}
+
+// addField registers the given field and its aliases in the parser.
+func (p *Parser) addField(f *field) error {
+	if prev, ok := p.fields[f.Name]; ok && prev.Name != f.Name {
+		return fmt.Errorf("rql: field %q conflicts with an alias of field %q", f.Name, prev.Name)
+	}
+	f.column = p.colName(f.Name)
 	p.fields[f.Name] = f
+	for _, a := range f.Aliases {
+		if prev, ok := p.fields[a]; ok {
+			return fmt.Errorf("rql: alias %q of field %q conflicts with field %q", a, f.Name, prev.Name)
+		}
+		p.fields[a] = f
+	}
 	return nil
 }
 
 type parseState struct {
-	*Parser                     // reference of the parser config
-	*bytes.Buffer               // query builder
-	values        []interface{} // query values
+	*Parser                      // reference of the parser config
+	*bytes.Buffer                // query builder
+	values         []interface{} // query values
+	args           map[interface{}]int
+	joins          []*relation
+	ctx            context.Context
+	allowed        map[string]bool
+	defaultLimit   int
+	limitMaxValue  int
+	defaultSort    []string
+	expanding      []string // presets that are being expanded
+	sanitize       bool
+	compiling      bool
+	allowFiltering bool
+	forceIndex     string
+	alias          string                 // the table alias of the columns. See ParseOptions.Alias.
+	totalCount     bool                   // see ParseOptions.TotalCount.
+	vars           map[string]interface{} // server-supplied variables
+	queryVars      map[string]interface{} // variables of the query
+	dropped        []*ParseError
+	warnings       []Warning
+	// nodes and links are the chunks that the filter nodes and their children are allocated
+	// from. They are kept when the state is returned to the pool, and the next parse continues
+	// from their unused capacity, because the used parts are referenced by the returned params.
+	nodes []expr
+	links []*expr
+	// scratch is the buffer of the sort and select expressions.
+	scratch []byte
+	// now is the current time of the relative time values. It is resolved once per parse call.
+	now time.Time
+	// prev holds the expressions of the params that are being reused, if any.
+	prev struct{ sort, selects, filter string }
 }
 
 var parseStatePool sync.Pool
 
-func (p *Parser) newParseState() (ps *parseState) {
+// chunkSize is the number of filter nodes (or children) in a chunk of a parse state.
+const chunkSize = 32
+
+// newExpr allocates the given node in the current chunk of the parse state.
+func (p *parseState) newExpr(e expr) *expr {
+	if len(p.nodes) == cap(p.nodes) {
+		p.nodes = make([]expr, 0, chunkSize)
+	}
+	p.nodes = append(p.nodes, e)
+	return &p.nodes[len(p.nodes)-1]
+}
+
+// newChildren allocates an empty list of children with a capacity of n in the current
+// chunk of the parse state. Appending more than n children reallocates the list.
+func (p *parseState) newChildren(n int) []*expr {
+	if n > chunkSize/2 {
+		return make([]*expr, 0, n)
+	}
+	if cap(p.links)-len(p.links) < n {
+		p.links = make([]*expr, 0, chunkSize)
+	}
+	i := len(p.links)
+	p.links = p.links[:i+n]
+	return p.links[i : i : i+n]
+}
+
+func (p *Parser) newParseState(ctx context.Context, opts ParseOptions) (ps *parseState) {
 	if v := parseStatePool.Get(); v != nil {
 		ps = v.(*parseState)
-		ps.Reset()
-		ps.values = nil
 	} else {
 		ps = new(parseState)
 		// currently we're using an arbitrary size as the capacity of initial buffer.
 		// What we can do in the future is to track the size of parse results, and use
-		// the average value. Same thing applies to the `values` field below.
+		// the average value. Same thing applies to the `values` field (see parse).
 		ps.Buffer = bytes.NewBuffer(make([]byte, 0, 64))
 	}
-	ps.values = make([]interface{}, 0, 8)
+	p.checkConfig()
 	ps.Parser = p
+	ps.reset(ctx, opts)
 	return
 }
 
+// reset clears the state of the previous parse call, and applies the given options.
+func (ps *parseState) reset(ctx context.Context, opts ParseOptions) {
+	ps.Reset()
+	ps.values = nil
+	ps.args = nil
+	ps.joins = nil
+	ps.dropped = nil
+	ps.warnings = nil
+	ps.expanding = nil
+	ps.compiling = false
+	ps.allowFiltering = false
+	ps.queryVars = nil
+	ps.now = time.Time{}
+	ps.prev.sort, ps.prev.selects, ps.prev.filter = "", "", ""
+	ps.scratch = ps.scratch[:0]
+	p := ps.Parser
+	ps.ctx = ctx
+	ps.allowed = nil
+	if opts.AllowedFields != nil {
+		ps.allowed = make(map[string]bool, len(opts.AllowedFields))
+		for _, f := range opts.AllowedFields {
+			ps.allowed[f] = true
+		}
+	}
+	ps.defaultLimit = p.conf.DefaultLimit
+	if opts.DefaultLimit > 0 {
+		ps.defaultLimit = opts.DefaultLimit
+	}
+	ps.limitMaxValue = p.conf.LimitMaxValue
+	if opts.LimitMaxValue > 0 || opts.LimitMaxValue == Unlimited {
+		ps.limitMaxValue = opts.LimitMaxValue
+	}
+	ps.sanitize = p.conf.Sanitize
+	ps.vars = opts.Vars
+	ps.forceIndex = opts.ForceIndex
+	ps.alias = opts.Alias
+	ps.totalCount = opts.TotalCount
+	ps.defaultSort = p.conf.DefaultSort
+	if opts.DefaultSort != nil {
+		ps.defaultSort = opts.DefaultSort
+	}
+}
+
+// lookup returns the field registered under the given name, or nil if
+// it does not exist or it's not allowed to be used in this parse call.
+func (p *parseState) lookup(name string) *field {
+	f := p.get(name)
+	if f == nil || p.allowed != nil && !p.allowed[f.Name] {
+		return nil
+	}
+	return f
+}
+
+// get returns the field that is registered under the given name or alias. In CaseInsensitiveFields
+// mode, it falls back to a case-insensitive match.
+func (p *Parser) get(name string) *field {
+	if f, ok := p.fields[name]; ok || p.folded == nil {
+		return f
+	}
+	// the field must be registered in this parser, as versioned parsers share the folded map.
+	if f := p.folded[strings.ToLower(name)]; f != nil && p.fields[f.Name] == f {
+		return f
+	}
+	return nil
+}
+
+// initFolded builds the case-insensitive index of the fields, when the parser is
+// configured with CaseInsensitiveFields.
+func (p *Parser) initFolded() error {
+	if !p.conf.CaseInsensitiveFields {
+		return nil
+	}
+	p.folded = make(map[string]*field, len(p.fields))
+	for name, f := range p.fields {
+		k := strings.ToLower(name)
+		if prev, ok := p.folded[k]; ok && prev != f {
+			return fmt.Errorf("rql: fields %q and %q conflict in case-insensitive mode", prev.Name, f.Name)
+		}
+		p.folded[k] = f
+	}
+	return nil
+}
+
 // sort build the sort clause.
-func (p *Parser) sort(fields []string) string {
-	sortParams := make([]string, len(fields))
+// It returns the expression, and the sort fields that were used.
+func (p *parseState) sort(fields []string) (string, []string) {
+	// the common case of one field without direction doesn't allocate.
+	if len(fields) == 1 {
+		expect(fields[0] != "", CodeInvalidQuery, "", "sort field can not be empty")
+		switch c, dir := p.sortField(fields[0]); {
+		case c == "":
+			return "", fields[:0]
+		case dir == "":
+			return c, fields
+		}
+	}
+	b := p.scratch[:0]
+	used, dropped := fields, false
 	for i, field := range fields {
-		expect(field != "", "sort field can not be empty")
-		var orderBy string
-		// if the sort field prefixed by an order indicator.
-		if order, ok := sortDirection[field[0]]; ok {
-			orderBy = order
-			field = field[1:]
+		expect(field != "", CodeInvalidQuery, "", "sort field can not be empty")
+		c, dir := p.sortField(field)
+		switch {
+		case c == "" && !dropped:
+			used, dropped = append(fields[:0:0], fields[:i]...), true
+		case c == "":
+		default:
+			if len(b) > 0 {
+				b = append(b, ", "...)
+			}
+			b = append(b, c...)
+			if dir != "" {
+				b = append(append(b, ' '), dir...)
+			}
+			if dropped {
+				used = append(used, field)
+			}
+		}
+	}
+	p.scratch = b
+	return reuse(b, p.prev.sort), used
+}
+
+// reuse returns prev if it is equal to the given bytes, in order to avoid the allocation
+// of strings that didn't change since the previous use of pooled params.
+func reuse(b []byte, prev string) string {
+	if string(b) == prev {
+		return prev
+	}
+	return string(b)
+}
+
+// sortField returns the sort expression of the given field, and its direction. In sanitize
+// mode, it returns an empty expression if the field is rejected.
+func (p *parseState) sortField(field string) (c, dir string) {
+	if p.sanitize {
+		defer p.drop(nil)
+	}
+	if c, dir, ok := p.sortExpr(field); ok {
+		return c, dir
+	}
+	field, dir = p.sortToken(field)
+	return p.sortColumn(field), dir
+}
+
+// sortToken splits the given sort field to its name and its direction ("asc", "desc", or
+// empty if the direction was not set). See Config.SortAsc and Config.SortWords.
+func (p *Parser) sortToken(field string) (string, string) {
+	if p.conf.SortWords && strings.Contains(field, " ") {
+		if w := strings.Fields(field); len(w) == 2 {
+			for _, d := range []string{"asc", "desc"} {
+				switch {
+				case strings.EqualFold(w[1], d):
+					return w[0], d
+				case strings.EqualFold(w[0], d):
+					return w[1], d
+				}
+			}
 		}
-		expect(p.fields[field] != nil, "unrecognized key %q for sorting", field)
-		expect(p.fields[field].Sortable, "field %q is not sortable", field)
-		colName := p.colName(field)
-		if orderBy != "" {
-			colName += " " + orderBy
+	}
+	switch {
+	case field == "":
+		return field, ""
+	// "+" is decoded as a space in query strings.
+	case field[0] == ' ':
+		return strings.TrimLeft(field, " "), "asc"
+	case strings.IndexByte(p.conf.SortAsc, field[0]) >= 0:
+		return field[1:], "asc"
+	case strings.IndexByte(p.conf.SortDesc, field[0]) >= 0:
+		return field[1:], "desc"
+	}
+	return field, ""
+}
+
+// sortColumn returns the column (or the expression) of the given sort field.
+func (p *parseState) sortColumn(field string) string {
+	if c, ok := p.conf.Computed[field]; ok && (p.allowed == nil || p.allowed[field]) {
+		expect(c.Sortable, CodeNotSortable, field, "field %q is not sortable", field)
+		return c.SQL
+	}
+	f, r := p.lookupPath(field)
+	if f == nil {
+		p.unknownField(field, "unrecognized key "+strconv.Quote(field)+" for sorting", false, sortableField)
+	}
+	expectStr(f.Sortable, CodeNotSortable, field, "field %q is not sortable", field)
+	p.deprecated(f, field)
+	if f.Collate != "" {
+		return p.column(f, r) + " COLLATE " + f.Collate
+	}
+	return p.column(f, r)
+}
+
+// selects returns the expression for the SELECT clause, and the select keys that were used.
+func (p *parseState) selects(keys []string) (string, []string) {
+	b := p.scratch[:0]
+	used := keys[:0:0]
+	for _, k := range keys {
+		if c := p.selectKey(k); c != "" {
+			if len(b) > 0 {
+				b = append(b, ", "...)
+			}
+			b = append(b, c...)
+			used = append(used, k)
 		}
-		sortParams[i] = colName
 	}
-	return strings.Join(sortParams, ", ")
+	if len(used) == len(keys) {
+		used = keys
+	}
+	p.scratch = b
+	return reuse(b, p.prev.selects), used
 }
 
-func (p *parseState) and(f map[string]interface{}) {
-	var i int
-	for k, v := range f {
-		if i > 0 {
-			p.WriteString(" AND ")
+// selectKey returns the column of the given select key. In sanitize mode,
+// it returns an empty string if the key is rejected.
+func (p *parseState) selectKey(k string) string {
+	if p.sanitize {
+		defer p.drop(nil)
+	}
+	if w, ok := p.windows[k]; ok && (p.allowed == nil || p.allowed[k]) {
+		return w
+	}
+	if c, ok := p.conf.Computed[k]; ok && (p.allowed == nil || p.allowed[k]) {
+		return c.SQL + " AS " + p.colName(k)
+	}
+	f := p.lookup(k)
+	if f == nil {
+		p.unknownField(k, "unrecognized selection key "+strconv.Quote(k), false, anyField)
+	}
+	p.deprecated(f, k)
+	if f.Expr != "" {
+		return f.Expr + " AS " + p.ident(nil, p.colName(f.Name))
+	}
+	return p.column(f, nil)
+}
+
+// tableAlias matches the aliases that are accepted in ParseOptions.Alias.
+var tableAlias = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// tableName matches the tables that are accepted in Config.TableName. For example, "users" or "public.users".
+var tableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)?$`)
+
+// castType matches the types that are accepted in casts. For example, "uuid", "jsonb",
+// "numeric(10, 2)", "text[]" or "timestamp with time zone".
+var castType = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_ .,()\[\]]*$`)
+
+// identPart matches one part of a qualified column: a plain identifier, or a quoted one.
+const identPart = `(?:[a-zA-Z_][a-zA-Z0-9_]*|"(?:[^"]|"")+"|` + "`(?:[^`]|``)+`)"
+
+// strictIdent matches the columns that are accepted in StrictIdentifiers mode.
+var strictIdent = regexp.MustCompile(`^` + identPart + `(?:\.` + identPart + `)*$`)
+
+// ident validates the given column of the field in StrictIdentifiers mode, and returns it.
+// Columns of fields that are mapped to SQL expressions are not validated.
+func (p *parseState) ident(f *field, column string) string {
+	if p.conf.StrictIdentifiers && (f == nil || f.Expr == "") {
+		expect(strictIdent.MatchString(column), CodeInvalidQuery, "", "invalid column identifier %q", column)
+	}
+	return column
+}
+
+// deprecated records a deprecation warning for the given field, if it is deprecated.
+// The warning is recorded once per query, and the OnDeprecated hook is called for it.
+func (p *parseState) deprecated(f *field, name string) {
+	if f.Deprecated == "" {
+		return
+	}
+	for _, w := range p.warnings {
+		if w.Code == WarnDeprecated && w.Field == name {
+			return
 		}
+	}
+	p.warn(WarnDeprecated, name, "field %q is deprecated: %s", name, f.Deprecated)
+	if p.conf.OnDeprecated != nil {
+		p.conf.OnDeprecated(name)
+	}
+}
+
+// drop recovers from the rejection of a disallowed field, operator or value, and records it.
+// Other panics, like malformed queries or context errors, are propagated. If e is not nil,
+// it is set to an empty term that is skipped by its parent.
+func (p *parseState) drop(e **expr) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	err, ok := r.(*ParseError)
+	if !ok {
+		panic(r)
+	}
+	switch err.Code {
+	case CodeUnknownField, CodeNotFilterable, CodeNotSortable:
+		p.warn(WarnIgnoredField, err.Field, "%s", err.msg)
+	case CodeInvalidOp, CodeDisabledOp:
+		p.warn(WarnIgnoredOp, err.Field, "%s", err.msg)
+	case CodeInvalidValue:
+		p.warn(WarnIgnoredValue, err.Field, "%s", err.msg)
+	default:
+		panic(r)
+	}
+	p.dropped = append(p.dropped, err)
+	if e != nil {
+		*e = &expr{op: AND}
+	}
+}
+
+// warn adds a warning to the parse result.
+func (p *parseState) warn(code WarningCode, field string, msg string, args ...interface{}) {
+	p.warnings = append(p.warnings, Warning{Code: code, Field: field, Message: fmt.Sprintf(msg, args...)})
+}
+
+// and parses the given filter object into a conjunction of its terms.
+func (p *parseState) and(f map[string]interface{}) *expr {
+	e := p.newExpr(expr{op: AND, children: p.newChildren(len(f))})
+	for k, v := range f {
+		p.checkCtx()
 		switch {
 		case k == p.op(OR):
+			expectStr(p.conf.Dialect != DialectCQL, CodeInvalidOp, "", "%s is not supported by the CQL dialect", p.op(OR))
+			p.disabledOp("", OR)
 			terms, ok := v.([]interface{})
-			expect(ok, "$or must be type array")
-			p.relOp(OR, terms)
+			expect(ok, CodeInvalidQuery, "", "$or must be type array")
+			e.add(p.relOp(OR, terms))
 		case k == p.op(AND):
+			p.disabledOp("", AND)
 			terms, ok := v.([]interface{})
-			expect(ok, "$and must be type array")
-			p.relOp(AND, terms)
-		case p.fields[k] != nil:
-			expect(p.fields[k].Filterable, "field %q is not filterable", k)
-			p.field(p.fields[k], v)
+			expect(ok, CodeInvalidQuery, "", "$and must be type array")
+			e.add(p.relOp(AND, terms))
+		case k == p.op(PRESET):
+			p.disabledOp("", PRESET)
+			e.add(p.preset(v))
+		case k == p.op(TUPLE):
+			p.disabledOp("", TUPLE)
+			e.add(p.tuple(v))
 		default:
-			expect(false, "unrecognized key %q for filtering", k)
+			e.add(p.term(k, v))
 		}
-		i++
 	}
+	return e
+}
+
+// term parses the filter of the given relation or field key. In sanitize mode,
+// rejected terms are dropped.
+func (p *parseState) term(k string, v interface{}) (e *expr) {
+	if p.sanitize {
+		defer p.drop(&e)
+	}
+	if r := p.relation(k); r != nil {
+		return p.relFilter(r, v)
+	}
+	f, r := p.lookupPath(k)
+	if f == nil {
+		p.unknownField(k, "unrecognized key "+strconv.Quote(k)+" for filtering", true, filterableField)
+	}
+	expectStr(f.Filterable, CodeNotFilterable, k, "field %q is not filterable", k)
+	p.deprecated(f, k)
+	if p.conf.Dialect == DialectCQL && f.Key == RegularColumn {
+		expect(p.conf.AllowFiltering, CodeNotFilterable, k, "filtering on non-key field %q requires the AllowFiltering option", k)
+		p.allowFiltering = true
+	}
+	return p.field(f, r, v)
 }
 
-func (p *parseState) relOp(op Op, terms []interface{}) {
-	var i int
-	if len(terms) > 1 {
-		p.WriteByte('(')
+func (p *parseState) relOp(op Op, terms []interface{}) *expr {
+	if max := p.conf.MaxBranches; max > 0 && len(terms) > max {
+		expect(false, CodeTooManyBranches, "", "too many terms in %s: %d (max %d)", p.op(op), len(terms), max)
 	}
+	e := p.newExpr(expr{op: op, paren: true, children: p.newChildren(len(terms))})
 	for _, t := range terms {
-		if i > 0 {
-			p.WriteByte(' ')
-			p.WriteString(op.SQL())
-			p.WriteByte(' ')
-		}
 		mt, ok := t.(map[string]interface{})
-		expect(ok, "expressions for $%s operator must be type object", op)
-		p.and(mt)
-		i++
-	}
-	if len(terms) > 1 {
-		p.WriteByte(')')
+		expectStr(ok, CodeInvalidQuery, "", "expressions for $%s operator must be type object", string(op))
+		e.add(p.and(mt))
 	}
+	return e
 }
 
-func (p *parseState) field(f *field, v interface{}) {
+// field parses the filter of the given field. r is the joined relation of the field, if any.
+func (p *parseState) field(f *field, r *relation, v interface{}) *expr {
+	// fields without equality (e.g. ranges) accept only objects of operators.
+	if _, ok := v.(map[string]interface{}); !ok && !f.FilterOps[p.op(EQ)] {
+		p.unknownOp(f, p.op(EQ))
+	}
+	if name, ok := p.variable(v); ok {
+		p.allowOp(f, EQ)
+		if p.compiling {
+			return p.varPredicate(f, r, EQ, name)
+		}
+		v = p.varValue(f, name)
+	}
+	if v == nil && (p.conf.NullAsIsNull || f == p.softDelete) {
+		p.allowOp(f, EQ)
+		return p.nullPredicate(f, r, EQ)
+	}
+	v = p.normString(f, EQ, v)
+	if p.ignoreEmpty(f, v) {
+		return &expr{op: AND}
+	}
 	terms, ok := v.(map[string]interface{})
 	// default equality check.
 	if !ok {
-		must(f.ValidateFn(v), "invalid datatype for field %q", f.Name)
-		p.WriteString(p.fmtOp(f.Name, EQ))
-		p.values = append(p.values, f.CovertFn(v))
-	}
-	var i int
-	if len(terms) > 1 {
-		p.WriteByte('(')
+		p.allowOp(f, EQ)
+		mustStr(p.validateElem(f, EQ, v), f.Name, "invalid datatype for field %q", f.Name)
+		return p.predicate(f, r, EQ, v)
 	}
+	e := p.newExpr(expr{op: AND, paren: true, children: p.newChildren(len(terms))})
 	for opName, opVal := range terms {
-		if i > 0 {
-			p.WriteString(" AND ")
+		e.add(p.opTerm(f, r, opName, opVal))
+	}
+	if len(e.children) == 1 {
+		return e.children[0]
+	}
+	return e
+}
+
+// opTerm parses one operator of a field filter. In sanitize mode, rejected operators are dropped.
+func (p *parseState) opTerm(f *field, r *relation, opName string, v interface{}) (e *expr) {
+	if p.sanitize {
+		defer p.drop(&e)
+	}
+	if op, ok := p.opAliases[opName]; ok {
+		opName = op
+	}
+	if !f.FilterOps[opName] {
+		p.unknownOp(f, opName)
+	}
+	op := Op(strings.TrimPrefix(opName, p.conf.OpPrefix))
+	p.allowOp(f, op)
+	if pf := f.parts[op]; pf != nil {
+		return p.field(pf, r, v)
+	}
+	if name, ok := p.variable(v); ok {
+		if p.compiling {
+			return p.varPredicate(f, r, op, name)
 		}
-		expect(f.FilterOps[opName], "can not apply op %q on field %q", opName, f.Name)
-		must(f.ValidateFn(opVal), "invalid datatype or format for field %q", f.Name)
-		p.WriteString(p.fmtOp(f.Name, Op(opName[1:])))
-		p.values = append(p.values, f.CovertFn(opVal))
-		i++
+		v = p.varValue(f, name)
+	}
+	if v == nil && (p.conf.NullAsIsNull || f == p.softDelete) && (op == EQ || op == NEQ) {
+		return p.nullPredicate(f, r, op)
 	}
-	if len(terms) > 1 {
-		p.WriteByte(')')
+	v = p.normString(f, op, v)
+	if p.ignoreEmpty(f, v) {
+		return &expr{op: AND}
 	}
+	if op.list() {
+		return p.listPredicate(f, r, op, v)
+	}
+	if op == OVERLAPS {
+		return p.overlaps(f, r, v)
+	}
+	mustStr(p.validate(f, op, v), f.Name, "invalid datatype or format for field %q", f.Name)
+	return p.predicate(f, r, op, v)
+}
+
+// listPredicate creates a comparison node for operators that accept a list of values.
+func (p *parseState) listPredicate(f *field, r *relation, op Op, v interface{}) *expr {
+	vs, ok := v.([]interface{})
+	expect(ok && len(vs) > 0, CodeInvalidValue, f.Name, "%s%s on field %q must be a non-empty array", p.conf.OpPrefix, op, f.Name)
+	values := make([]interface{}, len(vs))
+	for i := range vs {
+		mustStr(p.validateElem(f, op, vs[i]), f.Name, "invalid datatype or format for field %q", f.Name)
+		values[i] = p.convert(f, op, vs[i])
+	}
+	return p.newExpr(expr{
+		op:     op,
+		field:  f,
+		column: p.column(f, r),
+		join:   r,
+		raw:    v,
+		value:  values,
+	})
 }
 
-// fmtOp create a string for the operation with a placeholder.
-// for example: "name = ?", or "age >= ?".
-func (p *Parser) fmtOp(field string, op Op) string {
-	colName := p.colName(field)
-	return colName + " " + op.SQL() + " ?"
+// predicate creates a comparison node for the given field, operator and its raw value.
+func (p *parseState) predicate(f *field, r *relation, op Op, v interface{}) *expr {
+	return p.newExpr(expr{
+		op:     op,
+		field:  f,
+		column: p.column(f, r),
+		join:   r,
+		sql:    p.opSQL(op),
+		raw:    v,
+		value:  p.convert(f, op, v),
+	})
+}
+
+// allowOp rejects the given operator on the field, if it is not allowed by the AllowOp option.
+func (p *parseState) allowOp(f *field, op Op) {
+	p.disabledOp(f.Name, op)
+	if p.conf.AllowOp != nil && !p.conf.AllowOp(p.ctx, f.meta, op) {
+		expectStr(false, CodeInvalidOp, f.Name, "op %q is not allowed on field %q", p.op(op), f.Name)
+	}
+}
+
+// normString applies the NormalizeStrings option on the given value, or on the elements of
+// a list value.
+func (p *parseState) normString(f *field, op Op, v interface{}) interface{} {
+	if p.conf.NormalizeStrings == 0 {
+		return v
+	}
+	switch v := v.(type) {
+	case string:
+		s := p.normalizeString(v)
+		expect(s != "" || v == "" || op != LIKE, CodeInvalidValue, f.Name, "search value for field %q contains only whitespace", f.Name)
+		return s
+	case []interface{}:
+		vs := make([]interface{}, len(v))
+		for i := range v {
+			vs[i] = p.normString(f, op, v[i])
+		}
+		return vs
+	}
+	return v
+}
+
+// normalizeString applies the NormalizeStrings option on the given string.
+func (p *parseState) normalizeString(s string) string {
+	if p.conf.NormalizeStrings&TrimSpace != 0 {
+		s = strings.TrimSpace(s)
+	}
+	if p.conf.NormalizeStrings&CollapseSpace != 0 {
+		s = collapseSpace(s)
+	}
+	if p.conf.NormalizeStrings&NFC != 0 {
+		s = norm.NFC.String(s)
+	}
+	return s
+}
+
+// collapseSpace replaces each run of whitespace in s with one space.
+func collapseSpace(s string) string {
+	var (
+		b     strings.Builder
+		space bool
+	)
+	b.Grow(len(s))
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			b.WriteRune(r)
+		} else if !space {
+			b.WriteByte(' ')
+		}
+		space = unicode.IsSpace(r)
+	}
+	return b.String()
+}
+
+// ignoreEmpty applies the EmptyValues policy on the given value. It reports if the value is
+// an empty string or an empty array, and the predicate should be ignored.
+func (p *parseState) ignoreEmpty(f *field, v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		if v != "" || p.conf.EmptyValues == EmptyPass {
+			return false
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			return false
+		}
+	default:
+		return false
+	}
+	expect(p.conf.EmptyValues == EmptyIgnore, CodeInvalidValue, f.Name, "empty value for field %q", f.Name)
+	p.warn(WarnIgnoredValue, f.Name, "empty value for field %q was ignored", f.Name)
+	return true
+}
+
+// nullPredicate creates an "IS NULL" (EQ), or an "IS NOT NULL" (NEQ) check for the given field.
+func (p *parseState) nullPredicate(f *field, r *relation, op Op) *expr {
+	return &expr{
+		op:     op,
+		field:  f,
+		column: p.column(f, r),
+		join:   r,
+		null:   true,
+	}
+}
+
+// convert converts the given raw value of the field, and applies the transformer
+// of the operator on it, if there is one.
+func (p *Parser) convert(f *field, op Op, v interface{}) interface{} {
+	if spec := p.extraOps[op]; spec != nil && spec.Converter != nil {
+		v = spec.Converter(v)
+	} else {
+		v = f.CovertFn(v)
+	}
+	if t, ok := p.conf.OpTransformers[op]; ok {
+		v = t(v)
+	}
+	return v
+}
+
+// fieldColumn returns the database column of the given field.
+func (p *Parser) fieldColumn(f *field) string {
+	switch {
+	case f.Column != "":
+		return f.Column
+	case f.column != "":
+		return f.column
+	}
+	return p.colName(f.Name)
 }
 
 // colName formats the query field to database column name in cases the user configured a custom
 // field separator. for example: if the user configured the field separator to be ".", the fields
 // like "address.name" will be changed to "address_name".
 func (p *Parser) colName(field string) string {
-	if p.FieldSep != DefaultFieldSep {
-		return strings.Replace(field, p.FieldSep, DefaultFieldSep, -1)
+	if p.conf.FieldSep != DefaultFieldSep {
+		return strings.Replace(field, p.conf.FieldSep, DefaultFieldSep, -1)
 	}
 	return field
 }
 
 func (p *Parser) op(op Op) string {
-	return p.OpPrefix + string(op)
+	if s, ok := p.ops[op]; ok {
+		return s
+	}
+	return p.conf.OpPrefix + string(op)
+}
+
+// ctxError wraps a context error that stopped the parsing.
+type ctxError struct {
+	err error
+}
+
+// checkCtx panics if the parsing context was canceled or its deadline was exceeded.
+func (p *parseState) checkCtx() {
+	if err := p.ctx.Err(); err != nil {
+		panic(ctxError{err})
+	}
+}
+
+// expect panic if the condition is false. The code and the field are attached to the error.
+func expect(cond bool, code ErrorCode, field string, msg string, args ...interface{}) {
+	if !cond {
+		panic(&ParseError{Code: code, Field: field, msg: fmt.Sprintf(msg, args...)})
+	}
 }
 
-// expect panic if the condition is false.
-func expect(cond bool, msg string, args ...interface{}) {
+// expectStr is like expect, for messages that are formatted with string arguments. Unlike
+// expect, its arguments are not boxed into interfaces when the condition holds, and it's used
+// in the hot paths of the parser.
+func expectStr(cond bool, code ErrorCode, field string, msg string, args ...string) {
 	if !cond {
-		panic(&ParseError{fmt.Sprintf(msg, args...)})
+		expect(cond, code, field, msg, strArgs(args)...)
 	}
 }
 
-// must panics if the error is not nil.
-func must(err error, msg string, args ...interface{}) {
+// mustStr is like must, for messages that are formatted with string arguments. See expectStr.
+func mustStr(err error, field string, msg string, args ...string) {
+	if err != nil {
+		must(err, field, msg, strArgs(args)...)
+	}
+}
+
+func strArgs(args []string) []interface{} {
+	vs := make([]interface{}, len(args))
+	for i := range args {
+		vs[i] = args[i]
+	}
+	return vs
+}
+
+// must panics if the validation error of the given field is not nil.
+func must(err error, field string, msg string, args ...interface{}) {
 	if err != nil {
 		args = append(args, err)
-		panic(&ParseError{fmt.Sprintf(msg+": %s", args...)})
+		panic(&ParseError{Code: CodeInvalidValue, Field: field, msg: fmt.Sprintf(msg+": %s", args...)})
 	}
 }
 
+// reject reports the given error to the OnReject hooks, and returns it.
+func (p *Parser) reject(ctx context.Context, err *ParseError) *ParseError {
+	if p.conf.OnReject != nil {
+		p.conf.OnReject(err.Code, err.Field)
+	}
+	if p.conf.OnRejectContext != nil {
+		p.conf.OnRejectContext(ctx, err.Code, err.Field)
+	}
+	return err
+}
+
 // indirect returns the item at the end of indirection.
 func indirect(t reflect.Type) reflect.Type {
 	for ; t.Kind() == reflect.Ptr; t = t.Elem() {
@@ -521,6 +2488,22 @@ func errorType(v interface{}, expected string) error {
 	return fmt.Errorf("expect <%s>, got <%s>", expected, actual)
 }
 
+// coerceFn returns a function that parses strings into the JSON type of the given field
+// type (bool or float64), or nil if the values of the type are not coerced.
+func coerceFn(t reflect.Type) func(string) (interface{}, error) {
+	parseBool := func(s string) (interface{}, error) { return strconv.ParseBool(s) }
+	parseFloat := func(s string) (interface{}, error) { return strconv.ParseFloat(strings.TrimSpace(s), 64) }
+	switch t.Kind() {
+	case reflect.Bool:
+		return parseBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return parseFloat
+	}
+	return nil
+}
+
 // validate that the underlined element of given interface is a boolean.
 func validateBool(v interface{}) error {
 	if _, ok := v.(bool); !ok {
@@ -537,21 +2520,63 @@ func validateString(v interface{}) error {
 	return nil
 }
 
+// validate that the underlined element of given interface is a base64 encoded string.
+func validateBytes(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return errorType(v, "string")
+	}
+	_, err := base64.StdEncoding.DecodeString(s)
+	return err
+}
+
+// validate that the given value can be encoded as a JSON document.
+func validateJSON(v interface{}) error {
+	_, err := json.Marshal(v)
+	return err
+}
+
 // validate that the underlined element of given interface is a float.
 func validateFloat(v interface{}) error {
-	if _, ok := v.(float64); !ok {
+	n, ok := v.(float64)
+	if !ok {
 		return errorType(v, "float64")
 	}
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return errors.New("not a finite number")
+	}
 	return nil
 }
 
+// validateFloatKind returns a validator for floats that fit in the given float type, and
+// that don't exceed the FloatPrecision option.
+func (p *Parser) validateFloatKind(t reflect.Type) func(interface{}) error {
+	precision := p.conf.FloatPrecision
+	return func(v interface{}) error {
+		if err := validateFloat(v); err != nil {
+			return err
+		}
+		n := v.(float64)
+		if t.Kind() == reflect.Float32 && math.Abs(n) > math.MaxFloat32 {
+			return fmt.Errorf("number %v overflows float32", n)
+		}
+		if precision > 0 {
+			s := strconv.FormatFloat(n, 'f', -1, 64)
+			if i := strings.IndexByte(s, '.'); i != -1 && len(s)-i-1 > precision {
+				return fmt.Errorf("number %v exceeds the precision of %d decimal places", n, precision)
+			}
+		}
+		return nil
+	}
+}
+
 // validate that the underlined element of given interface is an int.
 func validateInt(v interface{}) error {
 	n, ok := v.(float64)
 	if !ok {
 		return errorType(v, "int")
 	}
-	if math.Trunc(n) != n {
+	if math.IsInf(n, 0) || math.Trunc(n) != n {
 		return errors.New("not an integer")
 	}
 	return nil
@@ -568,6 +2593,56 @@ func validateUInt(v interface{}) error {
 	return nil
 }
 
+// validateIntKind returns a validator for integers that fit in the given integer type.
+func validateIntKind(t reflect.Type) func(interface{}) error {
+	var min, max float64
+	switch bits := t.Bits(); t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		min, max = -math.Exp2(float64(bits-1)), math.Exp2(float64(bits-1))
+	default:
+		min, max = 0, math.Exp2(float64(bits))
+	}
+	return func(v interface{}) error {
+		if err := validateInt(v); err != nil {
+			return err
+		}
+		// the upper bound is exclusive, because 2^(bits) - 1 is not representable in float64 for 64-bit types.
+		if n := v.(float64); n < min || n >= max {
+			return fmt.Errorf("integer %v overflows %s", n, t.Kind())
+		}
+		return nil
+	}
+}
+
+// convertIntKind returns a converter of integers to the kind of the given integer type
+// (e.g. int8 for a field of type int8, or of a type that is defined as int8).
+func convertIntKind(t reflect.Type) func(interface{}) interface{} {
+	switch t.Kind() {
+	case reflect.Int:
+		return convertInt
+	case reflect.Int8:
+		return func(v interface{}) interface{} { return int8(v.(float64)) }
+	case reflect.Int16:
+		return func(v interface{}) interface{} { return int16(v.(float64)) }
+	case reflect.Int32:
+		return func(v interface{}) interface{} { return int32(v.(float64)) }
+	case reflect.Int64:
+		return func(v interface{}) interface{} { return int64(v.(float64)) }
+	case reflect.Uint8:
+		return func(v interface{}) interface{} { return uint8(v.(float64)) }
+	case reflect.Uint16:
+		return func(v interface{}) interface{} { return uint16(v.(float64)) }
+	case reflect.Uint32:
+		return func(v interface{}) interface{} { return uint32(v.(float64)) }
+	case reflect.Uint64:
+		return func(v interface{}) interface{} { return uint64(v.(float64)) }
+	case reflect.Uintptr:
+		return func(v interface{}) interface{} { return uintptr(v.(float64)) }
+	default:
+		return func(v interface{}) interface{} { return uint(v.(float64)) }
+	}
+}
+
 // validate that the underlined element of this interface is a "datetime" string.
 func validateTime(layout string) func(interface{}) error {
 	return func(v interface{}) error {
@@ -593,11 +2668,197 @@ func convertTime(layout string) func(interface{}) interface{} {
 	}
 }
 
+// validate that the underlined element of given interface is a hex encoded ObjectID.
+func validateObjectID(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return errorType(v, "string")
+	}
+	if b, err := hex.DecodeString(s); err != nil || len(b) != 12 {
+		return fmt.Errorf("invalid ObjectID %q", s)
+	}
+	return nil
+}
+
+// objectID reports if the given type is a MongoDB ObjectID, like primitive.ObjectID.
+func objectID(t reflect.Type) bool {
+	return t.Name() == "ObjectID" && t.Kind() == reflect.Array && t.Len() == 12 && t.Elem().Kind() == reflect.Uint8
+}
+
+// enumValues returns the lookup of the enum names of the given integer type, or nil if the type
+// is not an enum. The mapping is the one that is named in the "enummap" option, or derived from
+// the UnmarshalText method of the type. The String method is not used for detecting enums, because
+// many integer types implement it without being enums, and it can't be inverted without calling
+// it on every possible value.
+func (p *Parser) enumValues(t reflect.Type, name string) (func(string) (float64, bool), error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if name != "" {
+			return nil, fmt.Errorf("enummap option is supported only on integer fields")
+		}
+		return nil, nil
+	}
+	switch {
+	case name != "":
+		m, ok := p.conf.Enums[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown enum mapping %q", name)
+		}
+		return func(s string) (float64, bool) {
+			n, ok := m[s]
+			return float64(n), ok
+		}, nil
+	case reflect.PtrTo(t).Implements(textUnmarshalerType):
+		return func(s string) (float64, bool) {
+			v := reflect.New(t)
+			if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				return 0, false
+			}
+			return intValue(v.Elem()), true
+		}, nil
+	}
+	return nil, nil
+}
+
+// intValue returns the value of an integer kind as a float64, the type of JSON numbers.
+func intValue(v reflect.Value) float64 {
+	if k := v.Kind(); k >= reflect.Int && k <= reflect.Int64 {
+		return float64(v.Int())
+	}
+	return float64(v.Uint())
+}
+
+// objectIDFormats holds the conversions of ObjectID values by the "objectid" option. ObjectIDs
+// are passed to SQL databases as lowercase hex strings by default, or as 12 bytes. The native
+// format passes them as values of the field type (e.g. primitive.ObjectID).
+var objectIDFormats = map[string]func(reflect.Type, string) interface{}{
+	"": func(_ reflect.Type, s string) interface{} {
+		return strings.ToLower(s)
+	},
+	"hex": func(_ reflect.Type, s string) interface{} {
+		return strings.ToLower(s)
+	},
+	"bytes": func(_ reflect.Type, s string) interface{} {
+		b, _ := hex.DecodeString(s)
+		return b
+	},
+	"native": func(t reflect.Type, s string) interface{} {
+		b, _ := hex.DecodeString(s)
+		v := reflect.New(t).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v.Interface()
+	},
+}
+
+// convert base64 encoded string to bytes.
+func convertBytes(v interface{}) interface{} {
+	b, _ := base64.StdEncoding.DecodeString(v.(string))
+	return b
+}
+
+// convertJSON returns a function that converts JSON values to encoded documents of the given
+// type. Types that implement driver.Valuer (e.g. datatypes.JSON) are encoded by their Value method.
+func convertJSON(t reflect.Type) func(interface{}) interface{} {
+	return func(v interface{}) interface{} {
+		b, _ := json.Marshal(v)
+		if !t.Implements(valuerType) {
+			return json.RawMessage(b)
+		}
+		dv, _ := reflect.ValueOf(b).Convert(t).Interface().(driver.Valuer).Value()
+		return dv
+	}
+}
+
+// jsonDoc reports if the given type is a JSON document, like json.RawMessage or datatypes.JSON.
+func jsonDoc(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && (t == reflect.TypeOf(json.RawMessage(nil)) || t.Implements(jsonMarshalerType))
+}
+
+// arrayValue validates the elements of the given value (a list, or a single element), and encodes
+// them using the Value method of the given array type. ef is the field of the array elements.
+func arrayValue(t reflect.Type, ef *field, v interface{}) (driver.Value, error) {
+	vs, ok := v.([]interface{})
+	if !ok {
+		vs = []interface{}{v}
+	}
+	et := t.Elem()
+	a := reflect.MakeSlice(t, 0, len(vs))
+	for _, v := range vs {
+		if err := ef.ValidateFn(v); err != nil {
+			return nil, err
+		}
+		ev := reflect.ValueOf(ef.CovertFn(v))
+		if it := indirect(et); !ev.IsValid() || !ev.Type().ConvertibleTo(it) {
+			return nil, fmt.Errorf("can not convert %v to %v", v, et)
+		}
+		if et.Kind() == reflect.Ptr {
+			pv := reflect.New(et.Elem())
+			pv.Elem().Set(ev.Convert(et.Elem()))
+			ev = pv
+		} else {
+			ev = ev.Convert(et)
+		}
+		a = reflect.Append(a, ev)
+	}
+	return a.Interface().(driver.Valuer).Value()
+}
+
 // nop converter.
 func valueFn(v interface{}) interface{} {
 	return v
 }
 
+var (
+	valuerType          = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// nullTypes maps the sql.Null* types to the types of their values. sql.NullString is not
+// included, because only the equality operators are supported on it.
+var nullTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(sql.NullBool{}):    reflect.TypeOf(false),
+	reflect.TypeOf(sql.NullInt32{}):   reflect.TypeOf(int32(0)),
+	reflect.TypeOf(sql.NullInt64{}):   reflect.TypeOf(int64(0)),
+	reflect.TypeOf(sql.NullFloat64{}): reflect.TypeOf(float64(0)),
+	reflect.TypeOf(sql.NullTime{}):    reflect.TypeOf(time.Time{}),
+}
+
+// nullType returns the type of the values of the given sql.Null* type, or of a type that
+// is defined on one of them, like gorm.DeletedAt.
+func nullType(t reflect.Type) (reflect.Type, bool) {
+	if vt, ok := nullTypes[t]; ok {
+		return vt, true
+	}
+	for nt, vt := range nullTypes {
+		if t.Kind() == reflect.Struct && t.ConvertibleTo(nt) {
+			return vt, true
+		}
+	}
+	return nil, false
+}
+
+// hashes holds the hash functions that are supported by the "hash" option of []byte fields.
+var hashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// hintTypes holds the types of the "as" option, that are used for validating and converting
+// the values of interface fields. JSON fields are passed to the database as encoded documents.
+var hintTypes = map[string]reflect.Type{
+	"string": reflect.TypeOf(""),
+	"int":    reflect.TypeOf(int64(0)),
+	"float":  reflect.TypeOf(float64(0)),
+	"bool":   reflect.TypeOf(false),
+	"time":   reflect.TypeOf(time.Time{}),
+	"json":   reflect.TypeOf(json.RawMessage(nil)),
+	"jsonb":  reflect.TypeOf(json.RawMessage(nil)),
+}
+
 // layouts holds all standard time.Time layouts.
 var layouts = map[string]string{
 	"ANSIC":       time.ANSIC,
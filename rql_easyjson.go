@@ -28,7 +28,7 @@ func easyjson4bc42f5bDecodeGithubComA8mRql(in *jlexer.Lexer, out *Query) {
 	}
 	in.Delim('{')
 	for !in.IsDelim('}') {
-		key := in.UnsafeString()
+		key := in.UnsafeFieldName(false)
 		in.WantColon()
 		if in.IsNull() {
 			in.Skip()
@@ -64,37 +64,42 @@ func easyjson4bc42f5bDecodeGithubComA8mRql(in *jlexer.Lexer, out *Query) {
 				in.Delim(']')
 			}
 		case "sort":
+			(out.Sort).UnmarshalEasyJSON(in)
+		case "filter":
 			if in.IsNull() {
 				in.Skip()
-				out.Sort = nil
 			} else {
-				in.Delim('[')
-				if out.Sort == nil {
-					if !in.IsDelim(']') {
-						out.Sort = make([]string, 0, 4)
-					} else {
-						out.Sort = []string{}
-					}
+				in.Delim('{')
+				if !in.IsDelim('}') {
+					out.Filter = make(map[string]interface{})
 				} else {
-					out.Sort = (out.Sort)[:0]
+					out.Filter = nil
 				}
-				for !in.IsDelim(']') {
-					var v2 string
-					v2 = string(in.String())
-					out.Sort = append(out.Sort, v2)
+				for !in.IsDelim('}') {
+					key := string(in.String())
+					in.WantColon()
+					var v2 interface{}
+					if m, ok := v2.(easyjson.Unmarshaler); ok {
+						m.UnmarshalEasyJSON(in)
+					} else if m, ok := v2.(json.Unmarshaler); ok {
+						_ = m.UnmarshalJSON(in.Raw())
+					} else {
+						v2 = in.Interface()
+					}
+					(out.Filter)[key] = v2
 					in.WantComma()
 				}
-				in.Delim(']')
+				in.Delim('}')
 			}
-		case "filter":
+		case "vars":
 			if in.IsNull() {
 				in.Skip()
 			} else {
 				in.Delim('{')
 				if !in.IsDelim('}') {
-					out.Filter = make(map[string]interface{})
+					out.Vars = make(map[string]interface{})
 				} else {
-					out.Filter = nil
+					out.Vars = nil
 				}
 				for !in.IsDelim('}') {
 					key := string(in.String())
@@ -107,11 +112,40 @@ func easyjson4bc42f5bDecodeGithubComA8mRql(in *jlexer.Lexer, out *Query) {
 					} else {
 						v3 = in.Interface()
 					}
-					(out.Filter)[key] = v3
+					(out.Vars)[key] = v3
 					in.WantComma()
 				}
 				in.Delim('}')
 			}
+		case "after":
+			out.After = string(in.String())
+		case "before":
+			out.Before = string(in.String())
+		case "search":
+			out.Search = string(in.String())
+		case "distinct_on":
+			if in.IsNull() {
+				in.Skip()
+				out.DistinctOn = nil
+			} else {
+				in.Delim('[')
+				if out.DistinctOn == nil {
+					if !in.IsDelim(']') {
+						out.DistinctOn = make([]string, 0, 4)
+					} else {
+						out.DistinctOn = []string{}
+					}
+				} else {
+					out.DistinctOn = (out.DistinctOn)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v4 string
+					v4 = string(in.String())
+					out.DistinctOn = append(out.DistinctOn, v4)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.AddError(&jlexer.LexerError{
 				Offset: in.GetPos(),
@@ -132,12 +166,8 @@ func easyjson4bc42f5bEncodeGithubComA8mRql(out *jwriter.Writer, in Query) {
 	_ = first
 	if in.Limit != 0 {
 		const prefix string = ",\"limit\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		first = false
+		out.RawString(prefix[1:])
 		out.Int(int(in.Limit))
 	}
 	if in.Offset != 0 {
@@ -160,11 +190,11 @@ func easyjson4bc42f5bEncodeGithubComA8mRql(out *jwriter.Writer, in Query) {
 		}
 		{
 			out.RawByte('[')
-			for v4, v5 := range in.Select {
-				if v4 > 0 {
+			for v5, v6 := range in.Select {
+				if v5 > 0 {
 					out.RawByte(',')
 				}
-				out.String(string(v5))
+				out.String(string(v6))
 			}
 			out.RawByte(']')
 		}
@@ -177,19 +207,40 @@ func easyjson4bc42f5bEncodeGithubComA8mRql(out *jwriter.Writer, in Query) {
 		} else {
 			out.RawString(prefix)
 		}
+		(in.Sort).MarshalEasyJSON(out)
+	}
+	if len(in.Filter) != 0 {
+		const prefix string = ",\"filter\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
 		{
-			out.RawByte('[')
-			for v6, v7 := range in.Sort {
-				if v6 > 0 {
+			out.RawByte('{')
+			v7First := true
+			for v7Name, v7Value := range in.Filter {
+				if v7First {
+					v7First = false
+				} else {
 					out.RawByte(',')
 				}
-				out.String(string(v7))
+				out.String(string(v7Name))
+				out.RawByte(':')
+				if m, ok := v7Value.(easyjson.Marshaler); ok {
+					m.MarshalEasyJSON(out)
+				} else if m, ok := v7Value.(json.Marshaler); ok {
+					out.Raw(m.MarshalJSON())
+				} else {
+					out.Raw(json.Marshal(v7Value))
+				}
 			}
-			out.RawByte(']')
+			out.RawByte('}')
 		}
 	}
-	if len(in.Filter) != 0 {
-		const prefix string = ",\"filter\":"
+	if len(in.Vars) != 0 {
+		const prefix string = ",\"vars\":"
 		if first {
 			first = false
 			out.RawString(prefix[1:])
@@ -199,7 +250,7 @@ func easyjson4bc42f5bEncodeGithubComA8mRql(out *jwriter.Writer, in Query) {
 		{
 			out.RawByte('{')
 			v8First := true
-			for v8Name, v8Value := range in.Filter {
+			for v8Name, v8Value := range in.Vars {
 				if v8First {
 					v8First = false
 				} else {
@@ -218,6 +269,55 @@ func easyjson4bc42f5bEncodeGithubComA8mRql(out *jwriter.Writer, in Query) {
 			out.RawByte('}')
 		}
 	}
+	if in.After != "" {
+		const prefix string = ",\"after\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.After))
+	}
+	if in.Before != "" {
+		const prefix string = ",\"before\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.Before))
+	}
+	if in.Search != "" {
+		const prefix string = ",\"search\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.Search))
+	}
+	if len(in.DistinctOn) != 0 {
+		const prefix string = ",\"distinct_on\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		{
+			out.RawByte('[')
+			for v9, v10 := range in.DistinctOn {
+				if v9 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v10))
+			}
+			out.RawByte(']')
+		}
+	}
 	out.RawByte('}')
 }
 
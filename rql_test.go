@@ -1,11 +1,24 @@
 package rql
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/jinzhu/gorm"
 )
 
 func TestInit(t *testing.T) {
@@ -117,6 +130,115 @@ func TestInit(t *testing.T) {
 	}
 }
 
+func TestConfigFreeze(t *testing.T) {
+	sort, key := []string{"age"}, []byte("secret")
+	enums := map[string]map[string]int64{"role": {"admin": 1}}
+	var logs []string
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"sort"`
+			Name string `rql:"sort"`
+			Role int    `rql:"filter,enummap=role"`
+		}),
+		DefaultSort: sort,
+		CursorKey:   key,
+		Enums:       enums,
+		// presets are parsed when the parser is created.
+		Presets: map[string]string{"admins": `{"role": "admin"}`},
+		Log: func(format string, args ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, args...))
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	sort[0] = "name"
+	key[0] = 0
+	enums["role"]["admin"] = 2
+	if _, err := p.Parse([]byte(`{}`)); err != nil || len(logs) != 0 {
+		t.Fatalf("expect changes of the given config to be ignored: %v %v", err, logs)
+	}
+	p.Config.DefaultSort[0] = "name"
+	p.DefaultLimit = 1
+	p.Config.Enums["role"]["admin"] = 3
+	for i := 0; i < 2*configCheckRate; i++ {
+		out, err := p.Parse([]byte(`{}`))
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		if out.Sort != "age" || out.Limit != DefaultLimit {
+			t.Fatalf("unexpected params: %+v", out)
+		}
+	}
+	if len(logs) != 1 || !strings.Contains(logs[0], "changed after NewParser") {
+		t.Fatalf("expect the change to be reported once: %v", logs)
+	}
+}
+
+func TestConfigClone(t *testing.T) {
+	var c Config
+	fill(reflect.ValueOf(&c).Elem())
+	clone := c.clone()
+	if path, ok := shared(reflect.ValueOf(c), reflect.ValueOf(clone), "Config"); ok {
+		t.Fatalf("%s is shared between the config and its clone. Copy it in Config.clone", path)
+	}
+	if !sameValue(reflect.ValueOf(c), reflect.ValueOf(clone)) {
+		t.Fatal("expect the clone to be equal to the config")
+	}
+}
+
+// fill sets a non-zero value to the maps and the slices of the given value, recursively.
+func fill(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				fill(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0))
+	case reflect.Map:
+		e := reflect.New(v.Type().Elem()).Elem()
+		fill(e)
+		v.Set(reflect.MakeMap(v.Type()))
+		v.SetMapIndex(reflect.Zero(v.Type().Key()), e)
+	}
+}
+
+// shared returns the path of the first map or slice that is shared between the two values.
+func shared(a, b reflect.Value, path string) (string, bool) {
+	switch a.Kind() {
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if p, ok := shared(a.Field(i), b.Field(i), path+"."+a.Type().Field(i).Name); ok {
+				return p, true
+			}
+		}
+	case reflect.Slice:
+		if a.Pointer() == b.Pointer() {
+			return path, true
+		}
+		for i := 0; i < a.Len(); i++ {
+			if p, ok := shared(a.Index(i), b.Index(i), path+"[]"); ok {
+				return p, true
+			}
+		}
+	case reflect.Map:
+		if a.Pointer() == b.Pointer() {
+			return path, true
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			if p, ok := shared(iter.Value(), b.MapIndex(iter.Key()), path+"[]"); ok {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
 func TestParse(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -308,7 +430,7 @@ func TestParse(t *testing.T) {
 			wantOut: &Params{
 				Limit:      25,
 				FilterExp:  "bool = ? AND int8 = ? AND uint8 = ? AND null_bool = ? AND ptr_null_bool = ?",
-				FilterArgs: []interface{}{true, 1, 1, true, true},
+				FilterArgs: []interface{}{true, int8(1), uint8(1), true, true},
 			},
 		},
 		{
@@ -337,8 +459,63 @@ func TestParse(t *testing.T) {
 			wantOut: &Params{
 				Limit:      25,
 				FilterExp:  "null_int64 = ? AND ptr_null_int64 = ? AND null_float64 = ? AND ptr_null_float64 = ? AND null_string = ? AND ptr_null_string = ?",
-				FilterArgs: []interface{}{1, 1, 1.0, 1.0, "", ""},
+				FilterArgs: []interface{}{int64(1), int64(1), 1.0, 1.0, "", ""},
+			},
+		},
+		{
+			name: "integer bounds",
+			conf: Config{
+				Model: struct {
+					Int8   int8   `rql:"filter"`
+					Uint8  uint8  `rql:"filter"`
+					Int16  int16  `rql:"filter"`
+					Uint32 uint32 `rql:"filter"`
+				}{},
+				DefaultLimit: 25,
+			},
+			input: []byte(`{
+				"filter": {
+					"int8": {"$gte": -128, "$lte": 127},
+					"uint8": {"$in": [0, 255]},
+					"int16": -32768,
+					"uint32": 4294967295
+				}
+			}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "(int8 >= ? AND int8 <= ?) AND uint8 IN (?, ?) AND int16 = ? AND uint32 = ?",
+				FilterArgs: []interface{}{int8(-128), int8(127), uint8(0), uint8(255), int16(-32768), uint32(4294967295)},
+			},
+		},
+		{
+			name: "int8 overflow",
+			conf: Config{
+				Model: struct {
+					Int8 int8 `rql:"filter"`
+				}{},
+			},
+			input:   []byte(`{"filter": {"int8": 300}}`),
+			wantErr: true,
+		},
+		{
+			name: "uint8 underflow",
+			conf: Config{
+				Model: struct {
+					Uint8 uint8 `rql:"filter"`
+				}{},
+			},
+			input:   []byte(`{"filter": {"uint8": {"$in": [1, -1]}}}`),
+			wantErr: true,
+		},
+		{
+			name: "int64 overflow",
+			conf: Config{
+				Model: struct {
+					Int64 int64 `rql:"filter"`
+				}{},
 			},
+			input:   []byte(`{"filter": {"int64": 9223372036854775808}}`),
+			wantErr: true,
 		},
 		{
 			name: "time",
@@ -634,6 +811,54 @@ func TestParse(t *testing.T) {
 				Sort:       "full_name",
 			},
 		},
+		{
+			name: "qualified column names",
+			conf: Config{
+				Model: struct {
+					Name  string  `rql:"filter,sort,column=users.name"`
+					Total float64 `rql:"filter,sort,column=orders.total"`
+				}{},
+				FieldSep: ".",
+			},
+			input: []byte(`{
+				"filter": {
+					"name": "a8m",
+					"total": { "$gt": 10 }
+				},
+				"sort": ["-total"],
+				"select": ["name", "total"]
+			}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  `"users"."name" = ? AND "orders"."total" > ?`,
+				FilterArgs: []interface{}{"a8m", 10.0},
+				Sort:       `"orders"."total" desc`,
+				Select:     `"users"."name", "orders"."total"`,
+			},
+		},
+		{
+			name: "qualified column names in mysql",
+			conf: Config{
+				Model: struct {
+					Name  string  `rql:"filter,sort,column=users.name"`
+					Total float64 "rql:\"filter,sort,column=`orders`.total\""
+				}{},
+				Dialect: DialectMySQL,
+			},
+			input: []byte(`{
+				"filter": {
+					"name": "a8m",
+					"total": { "$gt": 10 }
+				},
+				"sort": ["-total"]
+			}`),
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "`users`.`name` = ? AND `orders`.`total` > ?",
+				FilterArgs: []interface{}{"a8m", 10.0},
+				Sort:       "`orders`.`total` desc",
+			},
+		},
 		{
 			name: "naming columns",
 			conf: Config{
@@ -911,6 +1136,47 @@ func TestParse(t *testing.T) {
 				Offset: 4,
 			},
 		},
+		{
+			name: "no default limit",
+			conf: Config{
+				Model: new(struct {
+					Age int `rql:"filter"`
+				}),
+				NoDefaultLimit: true,
+			},
+			input: []byte(`{}`),
+			wantOut: &Params{
+				Limit: 0,
+			},
+		},
+		{
+			name: "unlimited max value",
+			conf: Config{
+				Model: new(struct {
+					Age int `rql:"filter"`
+				}),
+				LimitMaxValue: Unlimited,
+			},
+			input: []byte(`{
+				"limit": 100000
+			}`),
+			wantOut: &Params{
+				Limit: 100000,
+			},
+		},
+		{
+			name: "unlimited max value and invalid limit",
+			conf: Config{
+				Model: new(struct {
+					Age int `rql:"filter"`
+				}),
+				LimitMaxValue: Unlimited,
+			},
+			input: []byte(`{
+				"limit": -1
+			}`),
+			wantErr: true,
+		},
 		{
 			name: "invalid offset",
 			conf: Config{
@@ -981,7 +1247,7 @@ func assertParams(t *testing.T, got *Params, want *Params) {
 	if !equalExp(got.FilterExp, want.FilterExp) || !equalExp(want.FilterExp, got.FilterExp) {
 		t.Fatalf("filter expr:\n\tgot: %q\n\twant %q", got.FilterExp, want.FilterExp)
 	}
-	if !equalArgs(got.FilterArgs, got.FilterArgs) || !equalArgs(want.FilterArgs, got.FilterArgs) {
+	if !equalArgs(want.FilterArgs, got.FilterArgs) {
 		t.Fatalf("filter args:\n\tgot: %v\n\twant %v", got.FilterArgs, want.FilterArgs)
 	}
 }
@@ -1033,15 +1299,20 @@ func equalExp(e1, e2 string) bool {
 func split(e string) []string {
 	var s []string
 	for len(e) > 0 {
-		if e[0] == '(' {
-			end := strings.LastIndexByte(e, ')') + 1
-			s = append(s, e[:end])
-			e = e[end:]
-		} else {
-			end := strings.IndexByte(e, '?') + 1
-			s = append(s, e[:end])
-			e = e[end:]
+		// find the end of the term, that is the first separator outside of parentheses.
+		end, depth := len(e), 0
+		for i := 0; i < len(e) && end == len(e); i++ {
+			switch {
+			case e[i] == '(':
+				depth++
+			case e[i] == ')':
+				depth--
+			case depth == 0 && (strings.HasPrefix(e[i:], " AND ") || strings.HasPrefix(e[i:], " OR ")):
+				end = i
+			}
 		}
+		s = append(s, e[:end])
+		e = e[end:]
 		e = strings.TrimPrefix(e, " AND ")
 		e = strings.TrimPrefix(e, " OR ")
 	}
@@ -1052,3 +1323,2227 @@ func mustParseTime(layout, s string) time.Time {
 	t, _ := time.Parse(layout, s)
 	return t
 }
+
+func TestParseWithOptions(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age   int    `rql:"filter,sort"`
+			Name  string `rql:"filter,sort"`
+			Email string `rql:"filter,sort"`
+		}),
+		DefaultSort: []string{"email"},
+		Log:         t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		name    string
+		opts    ParseOptions
+		input   []byte
+		wantErr bool
+		wantOut *Params
+	}{
+		{
+			name:  "no overrides",
+			input: []byte(`{"filter": {"email": "a8m"}, "limit": 50}`),
+			wantOut: &Params{
+				Limit:      50,
+				Sort:       "email",
+				FilterExp:  "email = ?",
+				FilterArgs: []interface{}{"a8m"},
+			},
+		},
+		{
+			name: "default limit and sort",
+			opts: ParseOptions{
+				DefaultLimit: 10,
+				DefaultSort:  []string{"-age"},
+			},
+			input: []byte(`{"filter": {"name": "a8m"}}`),
+			wantOut: &Params{
+				Limit:      10,
+				Sort:       "age desc",
+				FilterExp:  "name = ?",
+				FilterArgs: []interface{}{"a8m"},
+			},
+		},
+		{
+			name:    "limit max value",
+			opts:    ParseOptions{LimitMaxValue: 10},
+			input:   []byte(`{"limit": 11}`),
+			wantErr: true,
+		},
+		{
+			name: "allowed fields",
+			opts: ParseOptions{
+				AllowedFields: []string{"age", "name"},
+				DefaultSort:   []string{},
+			},
+			input: []byte(`{"filter": {"age": 1}, "sort": ["name"], "select": ["age"]}`),
+			wantOut: &Params{
+				Limit:      25,
+				Sort:       "name",
+				Select:     "age",
+				FilterExp:  "age = ?",
+				FilterArgs: []interface{}{1},
+			},
+		},
+		{
+			name:    "filter by a field that is not allowed",
+			opts:    ParseOptions{AllowedFields: []string{"age"}},
+			input:   []byte(`{"filter": {"email": "a8m"}}`),
+			wantErr: true,
+		},
+		{
+			name:    "sort by a field that is not allowed",
+			opts:    ParseOptions{AllowedFields: []string{"age"}},
+			input:   []byte(`{"sort": ["name"]}`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := p.ParseWithOptions(tt.input, tt.opts)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("want: %v\ngot:%v\nerr: %v", tt.wantErr, err != nil, err)
+			}
+			assertParams(t, out, tt.wantOut)
+		})
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter"`
+			Name string `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	input := []byte(`{"filter": {"age": 1, "$or": [{"name": "a"}, {"name": "b"}]}}`)
+	if _, err := p.ParseContext(context.Background(), input); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.ParseContext(ctx, input); err != context.Canceled {
+		t.Fatalf("want context.Canceled, got: %v", err)
+	}
+	if _, err := p.ParseQueryContext(ctx, &Query{}); err != context.Canceled {
+		t.Fatalf("want context.Canceled, got: %v", err)
+	}
+}
+
+func TestOnReject(t *testing.T) {
+	type reject struct {
+		code  ErrorCode
+		field string
+	}
+	var got []reject
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter"`
+			Name string `rql:"filter,sort"`
+		}),
+		LimitMaxValue: 10,
+		OnReject: func(code ErrorCode, field string) {
+			got = append(got, reject{code, field})
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		input string
+		want  reject
+	}{
+		{`{"filter": {"age": 1}}`, reject{}},
+		{`{"filter": [}`, reject{CodeInvalidJSON, ""}},
+		{`{"filter": {"email": "a"}}`, reject{CodeUnknownField, "email"}},
+		{`{"filter": {"age": "a"}}`, reject{CodeInvalidValue, "age"}},
+		{`{"filter": {"age": {"$like": "a"}}}`, reject{CodeInvalidOp, "age"}},
+		{`{"filter": {"$or": {}}}`, reject{CodeInvalidQuery, ""}},
+		{`{"sort": ["age"]}`, reject{CodeNotSortable, "age"}},
+		{`{"limit": 11}`, reject{CodeInvalidLimit, ""}},
+		{`{"offset": -1}`, reject{CodeInvalidOffset, ""}},
+	}
+	for _, tt := range tests {
+		got = got[:0]
+		_, err := p.Parse([]byte(tt.input))
+		if tt.want == (reject{}) {
+			if err != nil || len(got) > 0 {
+				t.Errorf("%s: unexpected rejection: %v", tt.input, err)
+			}
+			continue
+		}
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s: got rejections %v, want %v", tt.input, got, tt.want)
+		}
+		perr, ok := err.(*ParseError)
+		if !ok || perr.Code != tt.want.code || perr.Field != tt.want.field {
+			t.Errorf("%s: unexpected error: %#v", tt.input, err)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age     int    `rql:"filter,sort"`
+			Name    string `rql:"filter,sort"`
+			Address string `rql:"sort"`
+		}),
+		Sanitize: true,
+		Log:      t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		input       string
+		wantOut     *Params
+		wantDropped []string
+		wantErr     bool
+	}{
+		{
+			input: `{"filter": {"age": 1, "email": "a"}, "sort": ["-age", "email"], "select": ["name", "email"]}`,
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "age = ?",
+				FilterArgs: []interface{}{1},
+				Sort:       "age desc",
+				Select:     "name",
+			},
+			wantDropped: []string{"unknown_field:email", "unknown_field:email", "unknown_field:email"},
+		},
+		{
+			input: `{"filter": {"$or": [{"age": {"$gt": 1, "$like": "a"}}, {"address": "a"}, {"name": 1}]}, "sort": ["name"]}`,
+			wantOut: &Params{
+				Limit:      25,
+				FilterExp:  "age > ?",
+				FilterArgs: []interface{}{1},
+				Sort:       "name",
+			},
+			wantDropped: []string{"invalid_op:age", "invalid_value:name", "not_filterable:address"},
+		},
+		{
+			input:   `{"filter": {"$or": {"age": 1}}}`,
+			wantErr: true,
+		},
+		{
+			input:   `{"filter": {"email": "a"}, "limit": -1}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			out, err := p.Parse([]byte(tt.input))
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("want error: %v, got: %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			assertParams(t, out, tt.wantOut)
+			var dropped []string
+			for _, d := range out.Dropped {
+				dropped = append(dropped, string(d.Code)+":"+d.Field)
+			}
+			sort.Strings(dropped)
+			if !reflect.DeepEqual(dropped, tt.wantDropped) {
+				t.Fatalf("dropped: got %v, want %v", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter"`
+		}),
+		LimitMaxValue: 50,
+		ClampLimit:    true,
+		Sanitize:      true,
+		Log:           t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"email": "a"}, {"age": {"$like": "a"}}, {"name": 1}]}, "limit": 100}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.Limit != 50 {
+		t.Fatalf("limit: got %d, want 50", out.Limit)
+	}
+	want := []Warning{
+		{Code: WarnClampedLimit, Message: "limit 100 was reduced to 50"},
+		{Code: WarnIgnoredField, Field: "email", Message: `unrecognized key "email" for filtering`},
+		{Code: WarnIgnoredOp, Field: "age", Message: `can not apply op "$like" on field "age"`},
+		{Code: WarnIgnoredValue, Field: "name", Message: `invalid datatype for field "name": expect <string>, got <float64>`},
+	}
+	if !reflect.DeepEqual(out.Warnings, want) {
+		t.Fatalf("warnings:\n\tgot: %+v\n\twant: %+v", out.Warnings, want)
+	}
+	if out, err = p.Parse([]byte(`{"limit": 10}`)); err != nil || len(out.Warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v, %v", out, err)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	var used []string
+	p, err := NewParser(Config{
+		Model: new(struct {
+			CreatedAt time.Time `rql:"filter,sort"`
+			Created   time.Time `rql:"filter,sort,deprecated=use created_at"`
+			Old       int       `rql:"filter,deprecated"`
+		}),
+		OnDeprecated: func(field string) { used = append(used, field) },
+		Log:          t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"created": {"$gt": "2018-01-01T00:00:00Z", "$lt": "2019-01-01T00:00:00Z"}}, "sort": ["-created"], "select": ["old"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Warning{
+		{Code: WarnDeprecated, Field: "created", Message: `field "created" is deprecated: use created_at`},
+		{Code: WarnDeprecated, Field: "old", Message: `field "old" is deprecated: it will be removed in a future version`},
+	}
+	if !reflect.DeepEqual(out.Warnings, want) {
+		t.Fatalf("warnings:\n\tgot: %+v\n\twant: %+v", out.Warnings, want)
+	}
+	if !reflect.DeepEqual(used, []string{"created", "old"}) {
+		t.Fatalf("OnDeprecated calls: %v", used)
+	}
+	if out, err = p.Parse([]byte(`{"filter": {"created_at": "2018-01-01T00:00:00Z"}}`)); err != nil || len(out.Warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v, %v", out, err)
+	}
+}
+
+func TestAlias(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			CreatedAt time.Time `rql:"filter,sort,alias=created|creation_time"`
+			Name      string    `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"creation_time": "2018-01-01T00:00:00Z"}, "sort": ["-created"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "created_at = ?",
+		FilterArgs: []interface{}{mustParseTime(time.RFC3339, "2018-01-01T00:00:00Z")},
+		Sort:       "created_at desc",
+	})
+	out, err = p.ParseWithOptions([]byte(`{"filter": {"created": "2018-01-01T00:00:00Z"}}`), ParseOptions{AllowedFields: []string{"created_at"}})
+	if err != nil || out.FilterExp != "created_at = ?" {
+		t.Fatalf("alias of an allowed field: %v, %v", out, err)
+	}
+	if fs := p.Fields(); len(fs) != 2 || !reflect.DeepEqual(fs[0].Aliases, []string{"created", "creation_time"}) {
+		t.Fatalf("unexpected fields: %+v", fs)
+	}
+	_, err = NewParser(Config{
+		Model: new(struct {
+			Name     string `rql:"filter"`
+			FullName string `rql:"filter,alias=name"`
+		}),
+	})
+	if err == nil {
+		t.Fatal("expect alias conflict error")
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter,sort"`
+			City string `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.ParseAll(
+		[]byte(`{"filter": {"$or": [{"name": "a"}, {"name": "b"}]}, "sort": ["-age"]}`),
+		[]byte(`{"filter": {"$or": [{"city": "TLV"}, {"city": "NYC"}]}, "sort": ["name"], "limit": 10}`),
+		[]byte(`{}`),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      10,
+		FilterExp:  "((name = ? OR name = ?) AND (city = ? OR city = ?))",
+		FilterArgs: []interface{}{"a", "b", "TLV", "NYC"},
+		Sort:       "age desc",
+	})
+	out, err = p.ParseAll([]byte(`{"filter": {"age": 1}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{Limit: 25, FilterExp: "age = ?", FilterArgs: []interface{}{1}})
+	if _, err := p.ParseAll([]byte(`{}`), []byte(`{`)); err == nil {
+		t.Fatal("expect error for invalid document")
+	}
+	if _, err := p.ParseAll([]byte(`{}`), []byte(`{"filter": {"email": "a"}}`)); err == nil {
+		t.Fatal("expect error for invalid filter")
+	}
+	// the variables of a document are not visible to the others.
+	out, err = p.ParseAll(
+		[]byte(`{"filter": {"name": {"$var": "name"}}, "vars": {"name": "a8m", "min": 100}}`),
+		[]byte(`{"filter": {"age": {"$gte": {"$var": "min"}}}, "vars": {"min": 18}}`),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{Limit: 25, FilterExp: "(name = ? AND age >= ?)", FilterArgs: []interface{}{"a8m", 18}})
+	if _, err := p.ParseAll([]byte(`{"vars": {"min": 100}}`), []byte(`{"filter": {"age": {"$gte": {"$var": "min"}}}}`)); err == nil {
+		t.Fatal("expect error for a variable that is defined in another document")
+	}
+}
+
+func TestExprField(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name     string  `rql:"filter"`
+			MaxOrder float64 `rql:"filter,sort,expr=(SELECT MAX(total) FROM orders o WHERE o.user_id = users.id)"`
+			Orders   int     `rql:"filter,expr=(SELECT COUNT(*), 1 FROM orders o WHERE o.user_id = users.id)"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"max_order": {"$gt": 100}}, "sort": ["-max_order"], "select": ["name", "max_order"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sub := "(SELECT MAX(total) FROM orders o WHERE o.user_id = users.id)"
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  sub + " > ?",
+		FilterArgs: []interface{}{100.0},
+		Sort:       sub + " desc",
+		Select:     "name, " + sub + " AS max_order",
+	})
+	if _, err := p.Parse([]byte(`{"filter": {"max_order": "a"}}`)); err == nil {
+		t.Fatal("expect type validation on expression fields")
+	}
+	if _, err := p.Parse([]byte(`{"sort": ["orders"]}`)); err == nil {
+		t.Fatal("expect options before expr to be respected")
+	}
+}
+
+func TestCollate(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name  string `rql:"filter,sort"`
+			City  string `rql:"filter,sort,collate=utf8mb4_german2_ci"`
+			Age   int    `rql:"filter,sort"`
+			Email string `rql:"filter"`
+		}),
+		Collate: func(f *FieldMeta) string {
+			if f.Sortable && f.Type.Kind() == reflect.String {
+				return `"de_DE"`
+			}
+			return ""
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"sort": ["-name", "city", "age"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `name COLLATE "de_DE" desc, city COLLATE utf8mb4_german2_ci, age`; out.Sort != want {
+		t.Fatalf("sort:\n\tgot: %q\n\twant: %q", out.Sort, want)
+	}
+}
+
+func TestStrictIdentifiers(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name  string `rql:"filter,sort"`
+			Email string `rql:"filter,sort,column=users.email"`
+			Lower string `rql:"filter,sort,expr=LOWER(name)"`
+		}),
+		ColumnFn: func(s string) string {
+			if s == "Name" {
+				return "name; DROP TABLE users"
+			}
+			return Column(s)
+		},
+		StrictIdentifiers: true,
+		Log:               t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"email": "a8m@example.com", "lower": "a8m"}, "sort": ["lower"], "select": ["email", "lower"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  `"users"."email" = ? AND LOWER(name) = ?`,
+		FilterArgs: []interface{}{"a8m@example.com", "a8m"},
+		Sort:       "LOWER(name)",
+		Select:     `"users"."email", LOWER(name) AS lower`,
+	})
+	for _, input := range []string{
+		`{"filter": {"name; DROP TABLE users": "a8m"}}`,
+		`{"sort": ["name; DROP TABLE users"]}`,
+		`{"select": ["name; DROP TABLE users"]}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+}
+
+func TestOpTransformers(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+		}),
+		OpTransformers: map[Op]Converter{
+			LIKE: func(v interface{}) interface{} {
+				return "%" + strings.ToLower(v.(string)) + "%"
+			},
+			IN: func(v interface{}) interface{} {
+				return strings.ToLower(v.(string))
+			},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$or": [{"name": "A8M"}, {"name": {"$like": "A8M"}}, {"name": {"$in": ["A", "B"]}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(name = ? OR name LIKE ? OR name IN (?, ?))",
+		FilterArgs: []interface{}{"A8M", "%a8m%", "a", "b"},
+	})
+}
+
+func TestConverters(t *testing.T) {
+	type Product struct {
+		ID    int     `rql:"filter,convert=obfuscate"`
+		Price float64 `rql:"filter,convert=cents"`
+	}
+	p, err := NewParser(Config{
+		Model: Product{},
+		Converters: map[string]Converter{
+			"cents": func(v interface{}) interface{} {
+				return int64(math.Round(v.(float64) * 100))
+			},
+			"obfuscate": func(v interface{}) interface{} {
+				return v.(int) ^ 0x5f
+			},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"price": {"$gte": 9.99}}, {"id": {"$in": [1, 2]}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(price >= ? AND id IN (?, ?))",
+		FilterArgs: []interface{}{int64(999), 1 ^ 0x5f, 2 ^ 0x5f},
+	})
+	_, err = NewParser(Config{Model: Product{}, Log: t.Logf})
+	if err == nil || !strings.Contains(err.Error(), "unknown converter") {
+		t.Fatalf("expect unknown converter error, got: %v", err)
+	}
+}
+
+func TestCaseInsensitiveFields(t *testing.T) {
+	type Order struct {
+		Status string `rql:"filter"`
+	}
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name      string `rql:"filter,sort"`
+			CreatedAt string `rql:"filter,sort,alias=created"`
+		}),
+		CaseInsensitiveFields: true,
+		FieldSep:              ".",
+		Relations: map[string]Relation{
+			"orders": {Table: "orders", On: "orders.user_id = users.id", Model: Order{}},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"Name": "a8m"}, {"CREATED": "2020"}, {"orders.STATUS": "paid"}]}, "sort": ["-NAME"], "select": ["Created_At"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(name = ? AND created_at = ? AND orders.status = ?)",
+		FilterArgs: []interface{}{"a8m", "2020", "paid"},
+		Sort:       "name desc",
+		Select:     "created_at",
+	})
+	_, err = NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+			NAME string `rql:"filter"`
+		}),
+		ColumnFn:              func(s string) string { return s },
+		CaseInsensitiveFields: true,
+	})
+	if err == nil {
+		t.Fatal("expect error for fields that differ only in case")
+	}
+	if _, err := MustNewParser(Config{Model: new(struct {
+		Name string `rql:"filter"`
+	})}).Parse([]byte(`{"filter": {"NAME": "a8m"}}`)); err == nil {
+		t.Fatal("expect case-sensitive matching by default")
+	}
+}
+
+func TestFieldNameFn(t *testing.T) {
+	type Order struct {
+		TotalPrice float64 `rql:"filter"`
+	}
+	p, err := NewParser(Config{
+		Model: new(struct {
+			FullName string `rql:"filter,sort"`
+			HTTPCode int    `rql:"filter,column=status_code"`
+			Address  struct {
+				ZipCode string `rql:"filter"`
+			}
+		}),
+		FieldNameFn: CamelCase,
+		FieldSep:    ".",
+		Relations: map[string]Relation{
+			"orders": {Table: "orders", On: "orders.user_id = users.id", Model: Order{}},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"fullName": "a8m"}, {"httpCode": 200}, {"address.zipCode": "10001"}, {"orders.totalPrice": {"$gt": 10}}]}, "sort": ["-fullName"], "select": ["fullName"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(full_name = ? AND status_code = ? AND address_zip_code = ? AND orders.total_price > ?)",
+		FilterArgs: []interface{}{"a8m", 200, "10001", 10.0},
+		Sort:       "full_name desc",
+		Select:     "full_name",
+	})
+	if _, err := p.Parse([]byte(`{"filter": {"full_name": "a8m"}}`)); err == nil {
+		t.Fatal("expect columns to not be accepted as query fields")
+	}
+	for s, want := range map[string]string{
+		"Username":     "username",
+		"FullName":     "fullName",
+		"HTTPCode":     "httpCode",
+		"Address.Name": "address.name",
+	} {
+		if got := CamelCase(s); got != want {
+			t.Errorf("CamelCase(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestInputGuards(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+		}),
+		MaxInputBytes: 128,
+		MaxJSONDepth:  4,
+		Log:           t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	// {query {filter {$or [ {term} ]}}} is at depth 4.
+	if _, err := p.Parse([]byte(`{"filter": {"$or": [{"name": "a8m"}, {"name": "[{\"}"}]}}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name  string
+		input string
+		code  ErrorCode
+	}{
+		{name: "size", input: `{"filter": {"name": "` + strings.Repeat("a", 128) + `"}}`, code: CodeInputTooLarge},
+		{name: "depth", input: `{"filter": {"$or": [{"$and": [{"name": "a8m"}]}]}}`, code: CodeInputTooLarge},
+		{name: "invalid", input: `{"filter": `, code: CodeInvalidJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Parse([]byte(tt.input))
+			if perr, ok := err.(*ParseError); !ok || perr.Code != tt.code {
+				t.Fatalf("expect error with code %q, got: %v", tt.code, err)
+			}
+			if _, err := p.ParseAll([]byte(`{}`), []byte(tt.input)); err == nil {
+				t.Fatal("expect ParseAll to enforce the guards")
+			}
+		})
+	}
+	if _, err := NewParser(Config{Model: new(struct{}), MaxJSONDepth: -1}); err == nil {
+		t.Fatal("expect error for negative depth")
+	}
+}
+
+func TestRejectDuplicateKeys(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+			Age  int    `rql:"filter,sort"`
+		}),
+		RejectDuplicateKeys: true,
+		Log:                 t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	for _, input := range []string{
+		`{"filter": {"name": "age", "age": 1}}`,
+		`{"filter": {"$or": [{"name": "a8m"}, {"name": "a8m"}]}, "sort": ["age", "age"]}`,
+		`{"filter": {"age": {"$gt": 1, "$lt": 5}}, "limit": 10}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err != nil {
+			t.Fatalf("unexpected error for input %s: %v", input, err)
+		}
+	}
+	for input, key := range map[string]string{
+		`{"filter": {"age": 1, "age": 2}}`:                           "age",
+		`{"filter": {"age": {"$gt": 1, "$gt": 5}}}`:                  "$gt",
+		`{"filter": {"$or": [{"name": "a", "name": "b"}]}}`:          "name",
+		`{"limit": 1, "filter": {"name": "a8m"}, "limit": 100}`:      "limit",
+		`{"filter": {"$or": [{"age": {"$in": [1, 2]}}], "$or": []}}`: "$or",
+	} {
+		_, err := p.Parse([]byte(input))
+		if perr, ok := err.(*ParseError); !ok || perr.Code != CodeInvalidJSON || perr.Field != key {
+			t.Fatalf("expect duplicate key %q for input %s, got: %v", key, input, err)
+		}
+	}
+}
+
+func TestFloatValidation(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Price float64 `rql:"filter"`
+			Ratio float32 `rql:"filter"`
+			Count int     `rql:"filter"`
+		}),
+		FloatPrecision: 2,
+		Log:            t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	if _, err := p.Parse([]byte(`{"filter": {"price": 10.99, "ratio": 1e30, "count": 1e3}}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, filter := range []map[string]interface{}{
+		{"price": math.NaN()},
+		{"price": math.Inf(1)},
+		{"price": map[string]interface{}{"$in": []interface{}{1.0, math.Inf(-1)}}},
+		{"count": math.Inf(1)},
+		{"price": 10.999},
+		{"ratio": 1e39},
+	} {
+		if _, err := p.ParseQuery(&Query{Filter: filter}); err == nil {
+			t.Fatalf("expect error for filter: %v", filter)
+		}
+	}
+}
+
+func TestCoerceStrings(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Admin  bool            `rql:"filter"`
+			Age    int8            `rql:"filter"`
+			Score  float64         `rql:"filter"`
+			Count  sql.NullInt64   `rql:"filter"`
+			Name   string          `rql:"filter"`
+			Active *sql.NullBool   `rql:"filter"`
+			Rate   sql.NullFloat64 `rql:"filter"`
+		}),
+		CoerceStrings: true,
+		Log:           t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"admin": "true"}, {"age": {"$in": ["42", 7]}}, {"score": {"$gt": "3.14"}}, {"count": "5"}, {"name": "42"}, {"active": "false"}, {"rate": "0.5"}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(admin = ? AND age IN (?, ?) AND score > ? AND count = ? AND name = ? AND active = ? AND rate = ?)",
+		FilterArgs: []interface{}{true, int8(42), int8(7), 3.14, int64(5), "42", false, 0.5},
+	})
+	for _, input := range []string{
+		`{"filter": {"admin": "yes"}}`,
+		`{"filter": {"age": "4.2"}}`,
+		`{"filter": {"age": "300"}}`,
+		`{"filter": {"score": "NaN"}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+	if _, err := MustNewParser(Config{Model: new(struct {
+		Admin bool `rql:"filter"`
+	})}).Parse([]byte(`{"filter": {"admin": "true"}}`)); err == nil {
+		t.Fatal("expect strings to be rejected by default")
+	}
+}
+
+func TestNullAsIsNull(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name      string `rql:"filter"`
+			ManagerID int    `rql:"filter"`
+		}),
+		NullAsIsNull:         true,
+		Normalize:            true,
+		DetectContradictions: true,
+		Log:                  t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"manager_id": null}, {"name": {"$neq": null}}, {"$or": [{"manager_id": null}, {"manager_id": 1}]}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "manager_id IS NULL AND name IS NOT NULL AND (manager_id IS NULL OR manager_id = ?)",
+		FilterArgs: []interface{}{1},
+	})
+	if out.Unsatisfiable {
+		t.Fatal("unexpected unsatisfiable filter")
+	}
+	for _, input := range []string{
+		`{"filter": {"manager_id": {"$gt": null}}}`,
+		`{"filter": {"manager_id": {"$in": [null]}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+	if _, err := MustNewParser(Config{Model: new(struct {
+		ManagerID int `rql:"filter"`
+	})}).Parse([]byte(`{"filter": {"manager_id": null}}`)); err == nil {
+		t.Fatal("expect null to be rejected by default")
+	}
+}
+
+func TestEmptyValues(t *testing.T) {
+	model := new(struct {
+		ID   int    `rql:"filter"`
+		Name string `rql:"filter"`
+	})
+	tests := []struct {
+		policy  EmptyPolicy
+		input   string
+		wantErr bool
+		want    *Params
+	}{
+		{
+			policy: EmptyPass,
+			input:  `{"filter": {"name": ""}}`,
+			want:   &Params{Limit: 25, FilterExp: "name = ?", FilterArgs: []interface{}{""}},
+		},
+		{
+			policy:  EmptyPass,
+			input:   `{"filter": {"id": {"$in": []}}}`,
+			wantErr: true,
+		},
+		{
+			policy:  EmptyReject,
+			input:   `{"filter": {"name": {"$neq": ""}}}`,
+			wantErr: true,
+		},
+		{
+			policy:  EmptyReject,
+			input:   `{"filter": {"id": {"$nin": []}}}`,
+			wantErr: true,
+		},
+		{
+			policy: EmptyIgnore,
+			input:  `{"filter": {"name": "", "id": {"$in": []}}}`,
+			want:   &Params{Limit: 25, FilterExp: "", FilterArgs: []interface{}{}},
+		},
+		{
+			policy: EmptyIgnore,
+			input:  `{"filter": {"$or": [{"name": ""}, {"id": 1}]}}`,
+			want:   &Params{Limit: 25, FilterExp: "id = ?", FilterArgs: []interface{}{1}},
+		},
+	}
+	for _, tt := range tests {
+		p := MustNewParser(Config{Model: model, EmptyValues: tt.policy})
+		out, err := p.Parse([]byte(tt.input))
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("expect error for input: %s", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error for input %s: %v", tt.input, err)
+		}
+		assertParams(t, out, tt.want)
+		if tt.policy == EmptyIgnore && len(out.Warnings) == 0 {
+			t.Fatalf("expect warnings for ignored values: %s", tt.input)
+		}
+	}
+}
+
+func TestNormalizeStrings(t *testing.T) {
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter,search"`
+		}),
+		NormalizeStrings: TrimSpace | CollapseSpace | NFC,
+		EmptyValues:      EmptyReject,
+	})
+	out, err := p.Parse([]byte(`{"filter": {"name": " foo \t  bar\n", "$or": [{"name": {"$in": [" Cafe\u0301 ", "x"]}}, {"name": {"$like": "  %a% "}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	args := out.FilterArgs
+	if len(args) != 4 {
+		t.Fatalf("unexpected args: %v", args)
+	}
+	for _, want := range []string{"foo bar", "Caf\u00e9", "x", "%a%"} {
+		var found bool
+		for _, arg := range args {
+			found = found || arg == want
+		}
+		if !found {
+			t.Fatalf("expect %q in args: %v", want, args)
+		}
+	}
+	for _, input := range []string{
+		`{"filter": {"name": {"$like": "   "}}}`,
+		`{"filter": {"name": " \t "}}`,
+		`{"search": "    "}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+	out, err = p.Parse([]byte(`{"search": "  a \t b "}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out.SearchArgs) != 1 || out.SearchArgs[0] != "%a b%" {
+		t.Fatalf("unexpected search args: %v", out.SearchArgs)
+	}
+	if got := collapseSpace(" a \t\n b  "); got != " a b " {
+		t.Fatalf("collapseSpace: got %q", got)
+	}
+}
+
+func TestBytesFields(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Blob  []byte          `rql:"filter"`
+			Token []byte          `rql:"filter,hash=sha256"`
+			Meta  json.RawMessage `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"blob": "AQID"}, {"token": {"$neq": "c2VjcmV0"}}, {"meta": {"$eq": {"plan": "pro"}}}, {"meta": {"$in": [1, "a"]}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sum := sha256.Sum256([]byte("secret"))
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(blob = ? AND token <> ? AND meta = ? AND meta IN (?, ?))",
+		FilterArgs: []interface{}{[]byte{1, 2, 3}, sum[:], json.RawMessage(`{"plan":"pro"}`), json.RawMessage(`1`), json.RawMessage(`"a"`)},
+	})
+	for _, input := range []string{
+		`{"filter": {"blob": "not base64!"}}`,
+		`{"filter": {"blob": 1}}`,
+		`{"filter": {"blob": {"$gt": "AQID"}}}`,
+		`{"filter": {"meta": {"plan": "pro"}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+	for _, model := range []interface{}{
+		new(struct {
+			Name string `rql:"filter,hash=sha256"`
+		}),
+		new(struct {
+			Blob []byte `rql:"filter,hash=md4"`
+		}),
+		new(struct {
+			Tags []string `rql:"filter"`
+		}),
+	} {
+		if _, err := NewParser(Config{Model: model}); err == nil {
+			t.Fatalf("expect error for model: %T", model)
+		}
+	}
+}
+
+func TestNullTypes(t *testing.T) {
+	// DeletedAt has the same definition as gorm.DeletedAt of gorm.io/gorm.
+	type DeletedAt sql.NullTime
+	p, err := NewParser(Config{
+		Model: new(struct {
+			DeletedAt DeletedAt     `rql:"filter"`
+			Age       sql.NullInt32 `rql:"filter"`
+			SeenAt    sql.NullTime  `rql:"filter,layout=2006-01-02"`
+			Verified  sql.NullBool  `rql:"filter"`
+		}),
+		CoerceStrings: true,
+		Log:           t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"deleted_at": {"$lt": "2020-01-01T00:00:00Z"}}, {"age": {"$gt": "18"}}, {"seen_at": {"$lt": "2020-01-02"}}, {"verified": true}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(deleted_at < ? AND age > ? AND seen_at < ? AND verified = ?)",
+		FilterArgs: []interface{}{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), int32(18), time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), true},
+	})
+	for _, input := range []string{
+		`{"filter": {"age": 2147483648}}`,
+		`{"filter": {"seen_at": "2020-01-02T00:00:00Z"}}`,
+		`{"filter": {"age": {"$like": "1"}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+}
+
+func TestCustomTypes(t *testing.T) {
+	type UUID [16]byte
+	type Money struct{ Cents int64 }
+	p, err := NewParser(Config{
+		Model: new(struct {
+			ID    UUID  `rql:"filter"`
+			Price Money `rql:"filter,sort"`
+		}),
+		Types: map[reflect.Type]FieldType{
+			reflect.TypeOf(UUID{}): {
+				Underlying: reflect.TypeOf(""),
+				Validator: func(v interface{}) error {
+					if len(v.(string)) != 36 {
+						return errors.New("invalid uuid")
+					}
+					return nil
+				},
+			},
+			reflect.TypeOf(Money{}): {
+				Underlying: reflect.TypeOf(float64(0)),
+				Converter: func(v interface{}) interface{} {
+					return int64(math.Round(v.(float64) * 100))
+				},
+			},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"id": "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"}, {"price": {"$gt": 9.99}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(id = ? AND price > ?)",
+		FilterArgs: []interface{}{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", int64(999)},
+	})
+	for _, input := range []string{
+		`{"filter": {"id": "a0eebc99"}}`,
+		`{"filter": {"price": "9.99"}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+	_, err = NewParser(Config{
+		Model: new(struct {
+			ID UUID `rql:"filter"`
+		}),
+		Types: map[reflect.Type]FieldType{reflect.TypeOf(UUID{}): {}},
+	})
+	if err == nil {
+		t.Fatal("expect error for a custom type without an underlying type")
+	}
+}
+
+// JSON and Strings have the same structure as the datatypes.JSON and
+// datatypes.JSONSlice[string] types of gorm.
+type (
+	JSON    json.RawMessage
+	Strings []string
+)
+
+func (j JSON) Value() (driver.Value, error)    { return string(j), nil }
+func (j JSON) MarshalJSON() ([]byte, error)    { return j, nil }
+func (s Strings) Value() (driver.Value, error) { return json.Marshal(s) }
+
+func TestJSONTypes(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Attrs JSON    `rql:"filter"`
+			Tags  Strings `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"attrs": {"$contains": {"plan": "pro"}}}, {"tags": {"$contains": "go"}}, {"tags": {"$neq": ["a", "b"]}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(attrs @> ? AND tags @> ? AND tags <> ?)",
+		FilterArgs: []interface{}{`{"plan":"pro"}`, []byte(`["go"]`), []byte(`["a","b"]`)},
+	})
+	for _, input := range []string{
+		`{"filter": {"tags": {"$contains": 1}}}`,
+		`{"filter": {"tags": [1, 2]}}`,
+		`{"filter": {"tags": {"$gt": "a"}}}`,
+		`{"filter": {"attrs": {"$like": "a"}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+}
+
+func TestGormModel(t *testing.T) {
+	type User struct {
+		gorm.Model
+		Name string `rql:"filter"`
+	}
+	p, err := NewParser(Config{
+		Model:     User{},
+		GormModel: true,
+		Log:       t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		input string
+		want  *Params
+	}{
+		{
+			input: `{"filter": {"id": {"$gt": 10}}, "sort": ["-created_at"]}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "id > ? AND deleted_at IS NULL",
+				FilterArgs: []interface{}{uint(10)},
+				Sort:       "created_at desc",
+			},
+		},
+		{
+			input: `{"filter": {"$or": [{"name": "a8m"}, {"updated_at": {"$gte": "2020-01-01T00:00:00Z"}}]}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "(name = ? OR updated_at >= ?) AND deleted_at IS NULL",
+				FilterArgs: []interface{}{"a8m", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			input: `{"filter": {"deleted_at": {"$neq": null}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "deleted_at IS NOT NULL",
+				FilterArgs: []interface{}{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		out, err := p.Parse([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("unexpected error for input %s: %v", tt.input, err)
+		}
+		assertParams(t, out, tt.want)
+	}
+	if _, err := p.Parse([]byte(`{"filter": {"name": null}}`)); err == nil {
+		t.Fatal("expect null values to be rejected for other fields")
+	}
+	if _, err := MustNewParser(Config{Model: User{}}).Parse([]byte(`{"filter": {"id": 1}}`)); err == nil {
+		t.Fatal("expect gorm.Model fields to be ignored by default")
+	}
+}
+
+// ObjectID has the same definition as primitive.ObjectID of the MongoDB driver.
+type ObjectID [12]byte
+
+func TestObjectID(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			ID      ObjectID  `rql:"filter,sort"`
+			OwnerID ObjectID  `rql:"filter,objectid=bytes"`
+			OrgID   *ObjectID `rql:"filter,objectid=native"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"id": {"$gt": "5F2B6D3A9E1C4B0012345678"}}, {"owner_id": "5f2b6d3a9e1c4b0012345678"}, {"org_id": {"$in": ["5f2b6d3a9e1c4b0012345678"]}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	oid := ObjectID{0x5f, 0x2b, 0x6d, 0x3a, 0x9e, 0x1c, 0x4b, 0x00, 0x12, 0x34, 0x56, 0x78}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(id > ? AND owner_id = ? AND org_id IN (?))",
+		FilterArgs: []interface{}{"5f2b6d3a9e1c4b0012345678", oid[:], oid},
+	})
+	for _, input := range []string{
+		`{"filter": {"id": "5f2b6d3a9e1c4b00123456"}}`,
+		`{"filter": {"id": "zz2b6d3a9e1c4b0012345678"}}`,
+		`{"filter": {"id": 1}}`,
+		`{"filter": {"id": {"$like": "5f%"}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+	for _, model := range []interface{}{
+		new(struct {
+			ID ObjectID `rql:"filter,objectid=base64"`
+		}),
+		new(struct {
+			ID string `rql:"filter,objectid=hex"`
+		}),
+		new(struct {
+			ID [12]byte `rql:"filter"`
+		}),
+	} {
+		if _, err := NewParser(Config{Model: model}); err == nil {
+			t.Fatalf("expect error for model: %T", model)
+		}
+	}
+}
+
+// Status is an enum in the format that is generated by stringer. Its names are
+// declared using the "enummap" option.
+type Status int
+
+const (
+	Active Status = iota + 1
+	Suspended
+)
+
+func (s Status) String() string {
+	switch s {
+	case Active:
+		return "active"
+	case Suspended:
+		return "suspended"
+	default:
+		return "Status(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// Level is an enum that is parsed by its UnmarshalText method.
+type Level uint8
+
+func (l *Level) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %q", b)
+	}
+	return nil
+}
+
+func TestEnums(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Status  Status        `rql:"filter,enummap=status"`
+			Kind    Status        `rql:"filter"`
+			Level   *Level        `rql:"filter"`
+			Role    int16         `rql:"filter,enummap=role"`
+			Timeout time.Duration `rql:"filter"`
+		}),
+		Enums: map[string]map[string]int64{
+			"role":   {"admin": 1, "member": 2},
+			"status": {"active": int64(Active), "suspended": int64(Suspended)},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"status": "active"}, {"level": {"$in": ["low", 2]}}, {"role": {"$neq": "member"}}, {"status": {"$neq": 2}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(status = ? AND level IN (?, ?) AND role <> ? AND status <> ?)",
+		FilterArgs: []interface{}{1, uint8(1), uint8(2), int16(2), 2},
+	})
+	for _, input := range []string{
+		`{"filter": {"status": "deleted"}}`,
+		`{"filter": {"status": "Status(3)"}}`,
+		`{"filter": {"level": "medium"}}`,
+		`{"filter": {"role": "owner"}}`,
+		`{"filter": {"timeout": "1ns"}}`,
+		// String methods are not used for detecting enums.
+		`{"filter": {"kind": "active"}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+	for _, model := range []interface{}{
+		new(struct {
+			Role int `rql:"filter,enummap=unknown"`
+		}),
+		new(struct {
+			Role string `rql:"filter,enummap=role"`
+		}),
+	} {
+		if _, err := NewParser(Config{Model: model, Enums: map[string]map[string]int64{"role": {}}}); err == nil {
+			t.Fatalf("expect error for model: %T", model)
+		}
+	}
+}
+
+func TestSortTokens(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			ID   int    `rql:"sort"`
+			Age  int    `rql:"sort"`
+			Name string `rql:"sort"`
+		}),
+		SortDesc:  "-!",
+		SortWords: true,
+		CursorKey: []byte("secret"),
+		Log:       t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"sort": [" name", "age DESC", "asc id", "!age", "+name"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit: 25,
+		Sort:  "name asc, age desc, id asc, age desc, name asc",
+	})
+	token, err := p.EncodeCursor(out, "a8m", 30, 1, 30, "a8m")
+	if err != nil {
+		t.Fatalf("failed to encode cursor: %v", err)
+	}
+	out, err = p.Parse([]byte(`{"sort": [" name", "age DESC", "asc id", "!age", "+name"], "before": "` + token + `"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "name desc, age, id desc, age, name desc"; out.Sort != want {
+		t.Fatalf("reversed sort: got %q, want %q", out.Sort, want)
+	}
+	for _, input := range []string{
+		`{"sort": ["name up"]}`,
+		`{"sort": ["desc"]}`,
+		`{"sort": ["name asc desc"]}`,
+		`{"sort": ["~name"]}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+	for _, conf := range []Config{
+		{SortAsc: "+", SortDesc: "+-"},
+		{SortDesc: "d"},
+		{SortAsc: " "},
+	} {
+		conf.Model = new(struct{})
+		if _, err := NewParser(conf); err == nil {
+			t.Fatalf("expect error for sort prefixes: %q, %q", conf.SortAsc, conf.SortDesc)
+		}
+	}
+}
+
+func TestParseInto(t *testing.T) {
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter,sort"`
+		}),
+		Log: t.Logf,
+	})
+	var out Params
+	if err := p.ParseInto([]byte(`{"filter": {"age": {"$in": [1, 2, 3]}}, "sort": ["-age", "name"], "limit": 10}`), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, &out, &Params{
+		Limit:      10,
+		FilterExp:  "age IN (?, ?, ?)",
+		FilterArgs: []interface{}{1, 2, 3},
+		Sort:       "age desc, name",
+	})
+	args := out.FilterArgs
+	if err := p.ParseInto([]byte(`{"filter": {"name": "a8m"}}`), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, &out, &Params{
+		Limit:      25,
+		FilterExp:  "name = ?",
+		FilterArgs: []interface{}{"a8m"},
+	})
+	if &args[0] != &out.FilterArgs[0] || args[1] != nil {
+		t.Fatal("expect the arguments slice to be reused and cleared")
+	}
+	if err := p.ParseInto([]byte(`{"filter": {"age": "a8m"}}`), &out); err == nil {
+		t.Fatal("expect error for invalid value")
+	}
+	if err := p.ParseInto([]byte(`{"filter": `), &out); err == nil {
+		t.Fatal("expect error for invalid JSON")
+	}
+}
+
+func TestParamsRelease(t *testing.T) {
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter,sort"`
+		}),
+		Log: t.Logf,
+	})
+	for i := 0; i < 10; i++ {
+		out, err := p.Parse([]byte(`{"filter": {"age": {"$gt": 1}}, "sort": ["-age", "name"]}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		assertParams(t, out, &Params{
+			Limit:      25,
+			FilterExp:  "age > ?",
+			FilterArgs: []interface{}{1},
+			Sort:       "age desc, name",
+		})
+		out.Release()
+		out, err = p.Parse([]byte(`{"select": ["name"]}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		assertParams(t, out, &Params{
+			Limit:      25,
+			FilterArgs: []interface{}{},
+			Select:     "name",
+		})
+		out.Release()
+	}
+}
+
+func TestReleaseReuse(t *testing.T) {
+	type Order struct {
+		Total float64 `rql:"filter"`
+	}
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter,sort"`
+			Old  int    `rql:"filter,deprecated"`
+		}),
+		FieldSep: ".",
+		Relations: map[string]Relation{
+			"orders": {Table: "orders", Alias: "o", On: "o.user_id = users.id", Model: Order{}},
+		},
+		Log: t.Logf,
+	})
+	exported := func(pr *Params) map[string]string {
+		m := make(map[string]string)
+		v := reflect.ValueOf(pr).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if sf := v.Type().Field(i); sf.PkgPath == "" {
+				m[sf.Name] = fmt.Sprintf("%v", v.Field(i).Interface())
+			}
+		}
+		return m
+	}
+	first := []byte(`{"filter": {"old": 1, "orders.total": {"$gt": 10}}, "offset": 5, "sort": ["-age"], "select": ["name"]}`)
+	second := []byte(`{"filter": {"age": 2}}`)
+	want, err := p.Parse(second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		out, err := p.ParseWithOptions(first, ParseOptions{Alias: "u", Vars: map[string]interface{}{"x": 1}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(out.Joins) == 0 || len(out.Warnings) == 0 || out.Offset != 5 {
+			t.Fatalf("unexpected params: %+v", out)
+		}
+		out.Release()
+		if err := p.ParseInto(second, out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := exported(out), exported(want); !reflect.DeepEqual(got, want) {
+			t.Fatalf("reused params:\n\tgot: %v\n\twant %v", got, want)
+		}
+	}
+	// a reset state is equal to a new one, except for the buffers that are kept.
+	ps := p.newParseState(context.Background(), ParseOptions{Alias: "u", Vars: map[string]interface{}{"x": 1}, AllowedFields: []string{"age"}})
+	ps.parse(&Query{Filter: map[string]interface{}{"age": 1.0}, Sort: []string{"-age"}}, &Params{Sort: "age", Select: "name", FilterExp: "age = ?"})
+	ps.reset(context.Background(), ParseOptions{})
+	fresh := &parseState{Parser: p, Buffer: new(bytes.Buffer)}
+	fresh.reset(context.Background(), ParseOptions{})
+	rs, fs := reflect.ValueOf(ps).Elem(), reflect.ValueOf(fresh).Elem()
+	for i := 0; i < rs.NumField(); i++ {
+		switch name := rs.Type().Field(i).Name; name {
+		case "Buffer", "nodes", "links", "scratch":
+		default:
+			if fmt.Sprint(rs.Field(i)) != fmt.Sprint(fs.Field(i)) {
+				t.Errorf("field %s was not reset: %v", name, rs.Field(i))
+			}
+		}
+	}
+	ps.release()
+}
+
+func TestWideModel(t *testing.T) {
+	wide := func(n int, bad ...int) interface{} {
+		sfs := make([]reflect.StructField, n)
+		for i := range sfs {
+			sfs[i] = reflect.StructField{
+				Name: fmt.Sprintf("Field%d", i),
+				Type: reflect.TypeOf(0),
+				Tag:  `rql:"filter,sort,foo"`,
+			}
+		}
+		for _, i := range bad {
+			sfs[i].Type = reflect.TypeOf(new(interface{})).Elem()
+		}
+		return reflect.New(reflect.StructOf(sfs)).Interface()
+	}
+	var (
+		mu   sync.Mutex
+		logs []string
+	)
+	p, err := NewParser(Config{
+		Model: wide(300),
+		Log: func(format string, args ...interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			logs = append(logs, fmt.Sprintf(format, args...))
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	if n := len(p.Fields()); n != 300 {
+		t.Fatalf("expect 300 fields, got %d", n)
+	}
+	if last := logs[len(logs)-1]; len(logs) != 301 || !strings.HasPrefix(last, "rql: created 300 fields") {
+		t.Fatalf("unexpected logs: %d, %q", len(logs), last)
+	}
+	out, err := p.Parse([]byte(`{"filter": {"field0": 1, "field299": {"$gt": 2}}, "sort": ["-field150"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "field0 = ? AND field299 > ?",
+		FilterArgs: []interface{}{1, 2},
+		Sort:       "field150 desc",
+	})
+	// the error is deterministic, and it is the error of the first invalid field in the
+	// order of the registration, as if the fields were created sequentially.
+	for i := 0; i < 10; i++ {
+		if _, err := NewParser(Config{Model: wide(300, 120, 7, 250), Log: t.Logf}); err == nil || !strings.Contains(err.Error(), `"Field250"`) {
+			t.Fatalf("expect error for the first invalid field, got: %v", err)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	type User struct {
+		Age   int    `rql:"filter, sort"`
+		Name  string `rql:" filter , search "`
+		Email string `rql:"filter,search, column=mail"`
+	}
+	tests := []struct {
+		name  string
+		conf  Config
+		input string
+		want  *Params
+	}{
+		{
+			name:  "and",
+			input: `{"filter": {"age": {"$gt": 20}}, "search": "a8m"}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "age > ? AND (mail LIKE ? OR name LIKE ?)",
+				FilterArgs: []interface{}{20, "%a8m%", "%a8m%"},
+			},
+		},
+		{
+			name:  "or",
+			conf:  Config{SearchOp: OR},
+			input: `{"filter": {"age": {"$gt": 20}, "name": {"$neq": "foo"}}, "search": "a8m"}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "((age > ? AND name <> ?) OR (mail LIKE ? OR name LIKE ?))",
+				FilterArgs: []interface{}{20, "foo", "%a8m%", "%a8m%"},
+			},
+		},
+		{
+			name:  "only search",
+			conf:  Config{SearchOp: OR},
+			input: `{"search": "50%_off"}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "(mail LIKE ? OR name LIKE ?)",
+				FilterArgs: []interface{}{`%50\%\_off%`, `%50\%\_off%`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.conf.Model = new(User)
+			tt.conf.Log = t.Logf
+			p, err := NewParser(tt.conf)
+			if err != nil {
+				t.Fatalf("failed to build parser: %v", err)
+			}
+			out, err := p.Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertParams(t, out, tt.want)
+			pattern := tt.want.FilterArgs[len(tt.want.FilterArgs)-1]
+			if !reflect.DeepEqual(out.SearchArgs, []string{pattern.(string), pattern.(string)}) {
+				t.Fatalf("search args: got %v", out.SearchArgs)
+			}
+			b, err := p.Query(out).MarshalJSON()
+			if err != nil {
+				t.Fatalf("failed to marshal query: %v", err)
+			}
+			again, err := p.Parse(b)
+			if err != nil {
+				t.Fatalf("failed to parse formatted query %s: %v", b, err)
+			}
+			assertParams(t, again, tt.want)
+		})
+	}
+	p := MustNewParser(Config{Model: new(User), DisableSearch: true, Log: t.Logf})
+	if _, err := p.Parse([]byte(`{"search": "a8m"}`)); err == nil {
+		t.Fatal("expect error for disabled search")
+	}
+	p = MustNewParser(Config{Model: new(struct {
+		Name string `rql:"filter"`
+	}), Log: t.Logf})
+	if _, err := p.Parse([]byte(`{"search": "a8m"}`)); err == nil {
+		t.Fatal("expect error for model without searchable fields")
+	}
+	for _, conf := range []Config{
+		{Model: new(User), SearchOp: NEQ},
+		{Model: new(struct {
+			Age int `rql:"filter,search"`
+		})},
+	} {
+		if _, err := NewParser(conf); err == nil {
+			t.Fatalf("expect error for config: %+v", conf)
+		}
+	}
+}
+
+func TestMaxBranches(t *testing.T) {
+	type Order struct {
+		Total int `rql:"filter"`
+	}
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter"`
+			Name string `rql:"filter"`
+		}),
+		Relations: map[string]Relation{
+			"orders": {Model: new(Order), Table: "orders", On: "orders.user_id = users.id"},
+		},
+		MaxBranches: 2,
+		Log:         t.Logf,
+	})
+	out, err := p.Parse([]byte(`{"filter": {"$or": [{"age": 1}, {"$and": [{"name": "a"}, {"name": "b"}]}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(age = ? OR (name = ? AND name = ?))",
+		FilterArgs: []interface{}{1, "a", "b"},
+	})
+	for _, input := range []string{
+		`{"filter": {"$or": [{"age": 1}, {"age": 2}, {"age": 3}]}}`,
+		`{"filter": {"$or": [{"age": 1}, {"$and": [{"age": 1}, {"age": 2}, {"age": 3}]}]}}`,
+		`{"filter": {"orders": {"$has": true, "$where": {"$or": [{"total": 1}, {"total": 2}, {"total": 3}]}}}}`,
+	} {
+		_, err := p.Parse([]byte(input))
+		if perr, ok := err.(*ParseError); !ok || perr.Code != CodeTooManyBranches {
+			t.Fatalf("expect too many branches error for input %s, got: %v", input, err)
+		}
+	}
+	if _, err := NewParser(Config{Model: new(struct{}), MaxBranches: -1}); err == nil {
+		t.Fatal("expect error for negative MaxBranches")
+	}
+}
+
+func TestParseWithAlias(t *testing.T) {
+	type Order struct {
+		Total int `rql:"filter"`
+	}
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Name   string `rql:"filter,sort"`
+			Age    int    `rql:"filter,sort"`
+			Total  int    `rql:"filter,column=orders.total"`
+			Domain string `rql:"filter,expr=split_part(email, '@', 2)"`
+		}),
+		Relations: map[string]Relation{
+			"orders": {Model: new(Order), Table: "orders", On: "orders.user_id = u.id"},
+		},
+		Log: t.Logf,
+	})
+	out, err := p.ParseWithAlias([]byte(`{
+		"filter": {
+			"$and": [
+				{"name": "a8m"},
+				{"total": {"$gt": 1}},
+				{"domain": "example.com"},
+				{"orders": {"$has": true, "$where": {"total": 2}}}
+			]
+		},
+		"sort": ["-age"],
+		"select": ["name"]
+	}`), "u")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  `(u.name = ? AND "orders"."total" > ? AND split_part(email, '@', 2) = ? AND EXISTS (SELECT 1 FROM orders WHERE orders.user_id = u.id AND total = ?))`,
+		FilterArgs: []interface{}{"a8m", 1, "example.com", 2},
+		Sort:       "u.age desc",
+		Select:     "u.name",
+	})
+	for _, alias := range []string{"u.x", "1u", "u; DROP TABLE users"} {
+		if _, err := p.ParseWithAlias([]byte(`{}`), alias); err == nil {
+			t.Fatalf("expect error for alias: %q", alias)
+		}
+	}
+}
+
+func TestTypeHints(t *testing.T) {
+	type Payload interface{}
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Payload `rql:"filter,as=jsonb"`
+			Kind    interface{} `rql:"filter,sort,as=string"`
+			Score   interface{} `rql:"filter,as=float"`
+			SeenAt  interface{} `rql:"filter,as=time,layout=2006-01-02"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{
+		"filter": {
+			"$and": [
+				{"payload": {"$contains": {"type": "click"}}},
+				{"kind": {"$like": "a%"}},
+				{"score": {"$gt": 1.5}},
+				{"seen_at": {"$lt": "2020-01-02"}}
+			]
+		},
+		"sort": ["kind"]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(payload @> ? AND kind LIKE ? AND score > ? AND seen_at < ?)",
+		FilterArgs: []interface{}{json.RawMessage(`{"type":"click"}`), "a%", 1.5, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		Sort:       "kind",
+	})
+	for _, input := range []string{
+		`{"filter": {"kind": 1}}`,
+		`{"filter": {"score": "1"}}`,
+		`{"filter": {"seen_at": "2020-01-02T00:00:00Z"}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+	for _, model := range []interface{}{
+		new(struct {
+			Payload interface{} `rql:"filter"`
+		}),
+		new(struct {
+			Payload interface{} `rql:"filter,as=map"`
+		}),
+		new(struct {
+			Name string `rql:"filter,as=int"`
+		}),
+	} {
+		if _, err := NewParser(Config{Model: model}); err == nil {
+			t.Fatalf("expect error for model: %T", model)
+		}
+	}
+}
+
+func TestAllowOp(t *testing.T) {
+	type ctxKey struct{}
+	var calls int
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+			Age  int    `rql:"filter"`
+		}),
+		AllowOp: func(ctx context.Context, f *FieldMeta, op Op) bool {
+			calls++
+			return op != LIKE || f.Name != "name" || ctx.Value(ctxKey{}) != nil
+		},
+		Log: t.Logf,
+	})
+	input := []byte(`{"filter": {"name": {"$like": "a%"}, "age": 1}}`)
+	if _, err := p.Parse(input); err == nil {
+		t.Fatal("expect error for disallowed operator")
+	} else if perr, ok := err.(*ParseError); !ok || perr.Code != CodeInvalidOp || perr.Field != "name" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.WithValue(context.Background(), ctxKey{}, true)
+	out, err := p.ParseContext(ctx, input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "name LIKE ? AND age = ?",
+		FilterArgs: []interface{}{"a%", 1},
+	})
+	if calls < 3 {
+		t.Fatalf("expect the hook to be called for every operator, got %d calls", calls)
+	}
+	// disallowed operators are dropped in sanitize mode.
+	sp := MustNewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+		}),
+		AllowOp:  func(_ context.Context, _ *FieldMeta, op Op) bool { return op != EQ },
+		Sanitize: true,
+		Log:      t.Logf,
+	})
+	out, err = sp.Parse([]byte(`{"filter": {"$or": [{"name": "a8m"}, {"name": {"$neq": "foo"}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.FilterExp != "name <> ?" || len(out.Dropped) != 1 || out.Dropped[0].Code != CodeInvalidOp {
+		t.Fatalf("unexpected params: %q %v", out.FilterExp, out.Dropped)
+	}
+}
+
+func TestContextHooks(t *testing.T) {
+	type ctxKey struct{}
+	var rejected []interface{}
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Region string `rql:"filter"`
+			Age    int    `rql:"filter"`
+		}),
+		ValidateValue: func(ctx context.Context, f *FieldMeta, op Op, v interface{}) error {
+			if f.Name == "region" && v != ctx.Value(ctxKey{}) {
+				return errors.New("unknown region")
+			}
+			return nil
+		},
+		ConvertValue: func(ctx context.Context, f *FieldMeta, op Op, v interface{}) interface{} {
+			if f.Name == "region" {
+				return v.(string) + "-" + op.SQL()
+			}
+			return v
+		},
+		OnRejectContext: func(ctx context.Context, code ErrorCode, field string) {
+			rejected = append(rejected, ctx.Value(ctxKey{}))
+		},
+		Log: t.Logf,
+	})
+	ctx := context.WithValue(context.Background(), ctxKey{}, "eu")
+	out, err := p.ParseContext(ctx, []byte(`{"filter": {"region": "eu", "age": {"$in": [1, 2]}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "region = ? AND age IN (?, ?)",
+		FilterArgs: []interface{}{"eu-=", 1, 2},
+	})
+	for _, input := range []string{
+		`{"filter": {"region": "us"}}`,
+		`{"filter": {"region": {"$in": ["eu", "us"]}}}`,
+	} {
+		if _, err := p.ParseContext(ctx, []byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		} else if perr, ok := err.(*ParseError); !ok || perr.Code != CodeInvalidValue {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(rejected) != 2 || rejected[0] != "eu" || rejected[1] != "eu" {
+		t.Fatalf("expect the rejections to be reported with the context: %v", rejected)
+	}
+}
+
+func TestAllowOpSearch(t *testing.T) {
+	type ctxKey struct{}
+	conf := Config{
+		Model: new(struct {
+			Name  string `rql:"filter,search"`
+			Email string `rql:"filter,search"`
+		}),
+		AllowOp: func(ctx context.Context, f *FieldMeta, op Op) bool {
+			return op != LIKE || f.Name != "name" || ctx.Value(ctxKey{}) != nil
+		},
+		Log: t.Logf,
+	}
+	p := MustNewParser(conf)
+	input := []byte(`{"search": "a"}`)
+	out, err := p.Parse(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.FilterExp != "email LIKE ?" {
+		t.Fatalf("expect the disallowed field to be skipped, got: %q", out.FilterExp)
+	}
+	out, err = p.ParseContext(context.WithValue(context.Background(), ctxKey{}, true), input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.FilterExp != "(email LIKE ? OR name LIKE ?)" {
+		t.Fatalf("unexpected filter: %q", out.FilterExp)
+	}
+	// searches without allowed fields are rejected, or dropped in sanitize mode.
+	conf.AllowOp = func(_ context.Context, _ *FieldMeta, op Op) bool { return op != LIKE }
+	if _, err := MustNewParser(conf).Parse(input); err == nil {
+		t.Fatal("expect error for disallowed search")
+	} else if perr, ok := err.(*ParseError); !ok || perr.Code != CodeInvalidOp {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conf.Sanitize = true
+	out, err = MustNewParser(conf).Parse([]byte(`{"filter": {"name": "a8m"}, "search": "a"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.FilterExp != "name = ?" || len(out.SearchArgs) != 0 || len(out.Dropped) != 1 || out.Dropped[0].Code != CodeInvalidOp {
+		t.Fatalf("unexpected params: %q %v %v", out.FilterExp, out.SearchArgs, out.Dropped)
+	}
+}
+
+func TestDistinctOn(t *testing.T) {
+	p := MustNewParser(Config{
+		Model: new(struct {
+			UserID    int       `rql:"filter,sort"`
+			Status    string    `rql:"filter,sort"`
+			CreatedAt time.Time `rql:"sort"`
+			Total     int       `rql:"filter"`
+		}),
+		Log: t.Logf,
+	})
+	out, err := p.Parse([]byte(`{"distinct_on": ["user_id", "status"], "sort": ["status", "-user_id", "-created_at"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.DistinctOn != "DISTINCT ON (user_id, status)" || out.Sort != "status, user_id desc, created_at desc" {
+		t.Fatalf("unexpected params: %q, %q", out.DistinctOn, out.Sort)
+	}
+	b, err := p.Query(out).MarshalJSON()
+	if err != nil {
+		t.Fatalf("failed to marshal query: %v", err)
+	}
+	if again, err := p.Parse(b); err != nil || !again.Equal(out) {
+		t.Fatalf("failed to parse formatted query %s: %v", b, err)
+	}
+	for _, input := range []string{
+		`{"distinct_on": ["user_id"]}`,
+		`{"distinct_on": ["user_id"], "sort": ["-created_at", "user_id"]}`,
+		`{"distinct_on": ["user_id", "status"], "sort": ["user_id"]}`,
+		`{"distinct_on": ["user_id", "user_id"], "sort": ["user_id", "status"]}`,
+		`{"distinct_on": ["total"], "sort": ["total"]}`,
+		`{"distinct_on": ["unknown"], "sort": ["user_id"]}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+}
+
+func TestCasing(t *testing.T) {
+	tests := []struct {
+		input                      string
+		column, camel, kebab, caps string
+	}{
+		{"Username", "username", "username", "username", "USERNAME"},
+		{"FullName", "full_name", "fullName", "full-name", "FULL_NAME"},
+		{"HTTPCode", "http_code", "httpCode", "http-code", "HTTP_CODE"},
+		{"ÜberName", "über_name", "überName", "über-name", "ÜBER_NAME"},
+		{"StraßeNummer", "straße_nummer", "straßeNummer", "straße-nummer", "STRAßE_NUMMER"},
+		{"PreisÄnderung", "preis_änderung", "preisÄnderung", "preis-änderung", "PREIS_ÄNDERUNG"},
+		{"ΌνομαΧρήστη", "όνομα_χρήστη", "όνομαΧρήστη", "όνομα-χρήστη", "ΌΝΟΜΑ_ΧΡΉΣΤΗ"},
+	}
+	for _, tt := range tests {
+		for _, c := range []struct {
+			fn   func(string) string
+			want string
+		}{
+			{Column, tt.column},
+			{CamelCase, tt.camel},
+			{KebabCase, tt.kebab},
+			{ScreamingSnakeCase, tt.caps},
+		} {
+			if got := c.fn(tt.input); got != c.want {
+				t.Errorf("%q: got %q, want %q", tt.input, got, c.want)
+			}
+		}
+	}
+	p := MustNewParser(Config{
+		Model: new(struct {
+			ÜberName string `rql:"filter,sort"`
+			Address  struct {
+				StreetName string `rql:"filter"`
+			}
+		}),
+		FieldNameFn: KebabCase,
+		ColumnFn:    ScreamingSnakeCase,
+		Log:         t.Logf,
+	})
+	out, err := p.Parse([]byte(`{"filter": {"über-name": "a8m", "address-street-name": "main"}, "sort": ["über-name"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "ÜBER_NAME = ? AND ADDRESS_STREET_NAME = ?",
+		FilterArgs: []interface{}{"a8m", "main"},
+		Sort:       "ÜBER_NAME",
+	})
+}
+
+func TestMaxSQLLength(t *testing.T) {
+	model := new(struct {
+		Age  int    `rql:"filter"`
+		Name string `rql:"filter"`
+	})
+	p := MustNewParser(Config{
+		Model:        model,
+		MaxSQLLength: 40,
+		Log:          t.Logf,
+	})
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"age": 1}, {"name": "a"}, {"age": {"$gt": 0}}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(age = ? AND name = ? AND age > ?)",
+		FilterArgs: []interface{}{1, "a", 0},
+	})
+	_, err = p.Parse([]byte(`{"filter": {"$and": [{"age": 1}, {"name": "a"}, {"age": {"$gt": 0}}, {"name": {"$neq": "b"}}]}}`))
+	if perr, ok := err.(*ParseError); !ok || perr.Code != CodeSQLTooLong {
+		t.Fatalf("expect sql too long error, got: %v", err)
+	}
+
+	lenient := MustNewParser(Config{
+		Model:        model,
+		MaxSQLLength: 40,
+		Sanitize:     true,
+		Log:          t.Logf,
+	})
+	tests := []struct {
+		input string
+		want  *Params
+	}{
+		{
+			input: `{"filter": {"$and": [{"age": 1}, {"name": "a"}, {"age": {"$gt": 0}}, {"name": {"$neq": "b"}}, {"age": {"$lt": 9}}]}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "(age = ? AND name = ? AND age > ?)",
+				FilterArgs: []interface{}{1, "a", 0},
+			},
+		},
+		{
+			input: `{"filter": {"$or": [{"age": 1}, {"name": "a"}, {"age": {"$gt": 0}}, {"name": {"$neq": "b"}}]}}`,
+			want: &Params{
+				Limit: 25,
+			},
+		},
+	}
+	for _, tt := range tests {
+		out, err := lenient.Parse([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		assertParams(t, out, tt.want)
+		if len(out.Warnings) != 1 || out.Warnings[0].Code != WarnTruncatedFilter {
+			t.Fatalf("expect a truncated filter warning, got: %v", out.Warnings)
+		}
+	}
+	if _, err := NewParser(Config{Model: model, MaxSQLLength: -1}); err == nil {
+		t.Fatal("expect error for negative MaxSQLLength")
+	}
+}
+
+func TestTableName(t *testing.T) {
+	model := new(struct {
+		Name   string `rql:"filter,sort"`
+		Age    int    `rql:"filter,sort"`
+		Total  int    `rql:"filter,column=orders.total"`
+		Domain string `rql:"filter,expr=split_part(email, '@', 2)"`
+	})
+	p := MustNewParser(Config{
+		Model:     model,
+		TableName: "users",
+		Windows: map[string]Window{
+			"rank": {Func: "RANK()", OrderBy: []string{"-age"}},
+		},
+		StrictIdentifiers: true,
+		Log:               t.Logf,
+	})
+	out, err := p.Parse([]byte(`{
+		"filter": {"$and": [{"name": "a8m"}, {"total": {"$gt": 1}}, {"domain": "example.com"}]},
+		"sort": ["-age", "name"],
+		"select": ["name", "rank"]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  `(users.name = ? AND "orders"."total" > ? AND split_part(email, '@', 2) = ?)`,
+		FilterArgs: []interface{}{"a8m", 1, "example.com"},
+		Sort:       "users.age desc, users.name",
+		Select:     "users.name, RANK() OVER (ORDER BY users.age desc) AS rank",
+	})
+	out, err = p.ParseWithAlias([]byte(`{"filter": {"name": "a8m"}}`), "u")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "u.name = ?",
+		FilterArgs: []interface{}{"a8m"},
+	})
+	out, err = MustNewParser(Config{Model: model, TableName: "public.users"}).Parse([]byte(`{"filter": {"age": 1}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "public.users.age = ?",
+		FilterArgs: []interface{}{1},
+	})
+	for _, name := range []string{"users u", "a.b.c", "1users", "users;"} {
+		if _, err := NewParser(Config{Model: model, TableName: name}); err == nil {
+			t.Errorf("expect error for table name: %q", name)
+		}
+	}
+}
+
+func TestParseBatch(t *testing.T) {
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter,sort"`
+		}),
+		Log: t.Logf,
+	})
+	out, errs := p.ParseBatch([][]byte{
+		[]byte(`{"filter": {"age": {"$gt": 10}, "name": "a8m"}, "sort": ["-age"]}`),
+		[]byte(`{"filter": {"name": "foo"}, "limit": 10}`),
+	})
+	if errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	assertParams(t, out[0], &Params{
+		Limit:      25,
+		FilterExp:  "age > ? AND name = ?",
+		FilterArgs: []interface{}{10, "a8m"},
+		Sort:       "age desc",
+	})
+	assertParams(t, out[1], &Params{
+		Limit:      10,
+		FilterExp:  "name = ?",
+		FilterArgs: []interface{}{"foo"},
+	})
+	out, errs = p.ParseBatch([][]byte{
+		[]byte(`{"filter": {"age": "a8m"}}`),
+		[]byte(`{"filter": {"age": 1}}`),
+		[]byte(`{"filter": `),
+	})
+	if len(out) != 3 || len(errs) != 3 {
+		t.Fatalf("expect 3 results and errors, got: %d, %d", len(out), len(errs))
+	}
+	if errs[0] == nil || out[0] != nil {
+		t.Fatal("expect error for invalid value")
+	}
+	if errs[2] == nil || out[2] != nil {
+		t.Fatal("expect error for invalid JSON")
+	}
+	if errs[1] != nil {
+		t.Fatalf("unexpected error: %v", errs[1])
+	}
+	assertParams(t, out[1], &Params{Limit: 25, FilterExp: "age = ?", FilterArgs: []interface{}{1}})
+}
@@ -0,0 +1,16 @@
+module github.com/a8m/rql/rqlpgx
+
+go 1.19
+
+require (
+	github.com/a8m/rql v0.0.0
+	github.com/jackc/pgx/v5 v5.5.0
+)
+
+require (
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/mailru/easyjson v0.7.7 // indirect
+	golang.org/x/text v0.13.0 // indirect
+)
+
+replace github.com/a8m/rql => ../
@@ -0,0 +1,55 @@
+// Package rqlpgx adds support for the types of the pgtype package (github.com/jackc/pgx/v5/pgtype)
+// to rql models. It is a separate module, in order to avoid a dependency on pgx in the rql module.
+// For example:
+//
+//	type User struct {
+//		ID        pgtype.UUID        `rql:"filter"`
+//		Balance   pgtype.Numeric     `rql:"filter,sort"`
+//		CreatedAt pgtype.Timestamptz `rql:"filter,sort"`
+//	}
+//
+//	p, err := rql.NewParser(rql.Config{
+//		Model: User{},
+//		Types: rqlpgx.Types(),
+//	})
+package rqlpgx
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/a8m/rql"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Types returns the handling of the pgtype types. The values of the fields are passed to the
+// database as Go values (e.g. time.Time for pgtype.Timestamptz), that are encoded by pgx.
+// UUIDs are passed as strings, and numerics as float64.
+func Types() map[reflect.Type]rql.FieldType {
+	var (
+		boolType   = reflect.TypeOf(false)
+		stringType = reflect.TypeOf("")
+		timeType   = reflect.TypeOf(time.Time{})
+	)
+	return map[reflect.Type]rql.FieldType{
+		reflect.TypeOf(pgtype.Bool{}):        {Underlying: boolType},
+		reflect.TypeOf(pgtype.Text{}):        {Underlying: stringType},
+		reflect.TypeOf(pgtype.UUID{}):        {Underlying: stringType, Validator: validateUUID},
+		reflect.TypeOf(pgtype.Int2{}):        {Underlying: reflect.TypeOf(int16(0))},
+		reflect.TypeOf(pgtype.Int4{}):        {Underlying: reflect.TypeOf(int32(0))},
+		reflect.TypeOf(pgtype.Int8{}):        {Underlying: reflect.TypeOf(int64(0))},
+		reflect.TypeOf(pgtype.Float4{}):      {Underlying: reflect.TypeOf(float32(0))},
+		reflect.TypeOf(pgtype.Float8{}):      {Underlying: reflect.TypeOf(float64(0))},
+		reflect.TypeOf(pgtype.Numeric{}):     {Underlying: reflect.TypeOf(float64(0))},
+		reflect.TypeOf(pgtype.Date{}):        {Underlying: timeType},
+		reflect.TypeOf(pgtype.Timestamp{}):   {Underlying: timeType},
+		reflect.TypeOf(pgtype.Timestamptz{}): {Underlying: timeType},
+	}
+}
+
+// validateUUID validates that the given string is a UUID, in one of the formats that are
+// accepted by PostgreSQL.
+func validateUUID(v interface{}) error {
+	var u pgtype.UUID
+	return u.Scan(v.(string))
+}
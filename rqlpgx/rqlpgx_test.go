@@ -0,0 +1,60 @@
+package rqlpgx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/a8m/rql"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestTypes(t *testing.T) {
+	p, err := rql.NewParser(rql.Config{
+		Model: new(struct {
+			ID        pgtype.UUID        `rql:"filter"`
+			Age       pgtype.Int4        `rql:"filter"`
+			Balance   pgtype.Numeric     `rql:"filter,sort"`
+			Name      pgtype.Text        `rql:"filter"`
+			CreatedAt pgtype.Timestamptz `rql:"filter,sort"`
+		}),
+		Types: Types(),
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{
+		"filter": {
+			"$and": [
+				{"id": "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"},
+				{"age": {"$gte": 18}},
+				{"balance": {"$lt": 10.5}},
+				{"name": {"$like": "a%"}},
+				{"created_at": {"$gt": "2020-01-01T00:00:00Z"}}
+			]
+		},
+		"sort": ["-balance"]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "(id = ? AND age >= ? AND balance < ? AND name LIKE ? AND created_at > ?)"; out.FilterExp != want {
+		t.Fatalf("unexpected filter: %s", out.FilterExp)
+	}
+	want := []interface{}{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", int32(18), 10.5, "a%", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if !reflect.DeepEqual(out.FilterArgs, want) {
+		t.Fatalf("unexpected args: %v", out.FilterArgs)
+	}
+	if out.Sort != "balance desc" {
+		t.Fatalf("unexpected sort: %s", out.Sort)
+	}
+	for _, input := range []string{
+		`{"filter": {"id": "not-a-uuid"}}`,
+		`{"filter": {"age": 2147483648}}`,
+		`{"filter": {"created_at": "yesterday"}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+}
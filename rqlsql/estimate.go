@@ -0,0 +1,58 @@
+package rqlsql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/a8m/rql"
+)
+
+// Estimate returns an approximate number of rows that match the given params, without
+// running a COUNT(*) query that may take seconds on huge tables. It is useful for showing
+// "~1.2M results" in UIs. For example:
+//
+//	n, err := rqlsql.Estimate(ctx, db, "users", params)
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Printf("~%d results", n)
+//
+// For unfiltered params, the estimate is read from the table statistics (pg_class.reltuples),
+// and otherwise, it is the row estimate of the EXPLAIN output. Estimate supports only PostgreSQL,
+// and its accuracy depends on how up to date the table statistics are (see ANALYZE).
+func Estimate(ctx context.Context, db Querier, table string, p *rql.Params) (int64, error) {
+	if p.FilterExp == "" && len(p.Joins) == 0 {
+		if n, err := reltuples(ctx, db, table); err == nil && n >= 0 {
+			return n, nil
+		}
+	}
+	plan, err := explainJSON(ctx, db, selectStmt(table, p), p.FilterArgs)
+	if err != nil {
+		return 0, err
+	}
+	if len(plan.Nodes) == 0 {
+		return 0, errors.New("rqlsql: empty execution plan")
+	}
+	return int64(plan.Nodes[0].Rows), nil
+}
+
+// reltuples returns the number of rows in the given table, according to its statistics.
+// It returns -1 if the table was never analyzed.
+func reltuples(ctx context.Context, db Querier, table string) (int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT reltuples::bigint FROM pg_class WHERE oid = $1::regclass", table)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("rqlsql: table statistics not found")
+	}
+	var n int64
+	if err := rows.Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, rows.Err()
+}
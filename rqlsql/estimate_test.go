@@ -0,0 +1,90 @@
+package rqlsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/a8m/rql"
+)
+
+func (d *fakeDriver) Connect(context.Context) (driver.Conn, error) { return &fakeConn{d}, nil }
+func (d *fakeDriver) Driver() driver.Driver                        { return d }
+
+func TestEstimate(t *testing.T) {
+	p := rql.MustNewParser(rql.Config{
+		Model: new(struct {
+			Age int `rql:"filter,sort"`
+		}),
+		BindStyle: rql.BindDollar,
+	})
+	filtered, err := p.Parse([]byte(`{"filter": {"age": {"$gt": 20}}}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	unfiltered, err := p.Parse([]byte(`{}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	tests := []struct {
+		name      string
+		params    *rql.Params
+		driver    *fakeDriver
+		want      int64
+		wantQuery string
+	}{
+		{
+			name:   "filtered",
+			params: filtered,
+			driver: &fakeDriver{
+				json: `[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "users", "Plan Rows": 1200000}}]`,
+			},
+			want:      1200000,
+			wantQuery: "EXPLAIN (FORMAT JSON) SELECT * FROM users WHERE age > $1",
+		},
+		{
+			name:   "unfiltered",
+			params: unfiltered,
+			driver: &fakeDriver{
+				columns: []string{"reltuples"},
+				rows:    [][]driver.Value{{int64(5000000)}},
+			},
+			want:      5000000,
+			wantQuery: "SELECT reltuples::bigint FROM pg_class WHERE oid = $1::regclass",
+		},
+		{
+			name:   "never analyzed",
+			params: unfiltered,
+			driver: &fakeDriver{
+				json:    `[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "users", "Plan Rows": 2550}}]`,
+				columns: []string{"reltuples"},
+				rows:    [][]driver.Value{{int64(-1)}},
+			},
+			want:      2550,
+			wantQuery: "EXPLAIN (FORMAT JSON) SELECT * FROM users",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sql.OpenDB(tt.driver)
+			defer db.Close()
+			n, err := Estimate(context.Background(), db, "users", tt.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n != tt.want {
+				t.Errorf("estimate = %d, want %d", n, tt.want)
+			}
+			if last := tt.driver.queries[len(tt.driver.queries)-1]; !strings.HasPrefix(last, tt.wantQuery) {
+				t.Errorf("query = %q, want %q", last, tt.wantQuery)
+			}
+		})
+	}
+	db := sql.OpenDB(&fakeDriver{})
+	defer db.Close()
+	if _, err := Estimate(context.Background(), db, "users", filtered); err == nil {
+		t.Fatal("expect error for database without JSON plans")
+	}
+}
@@ -0,0 +1,222 @@
+// Package rqlsql contains helpers for running rql.Params against a database/sql connection.
+package rqlsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/a8m/rql"
+)
+
+// Querier is the interface implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Plan is a summary of the execution plan returned by the database for a parsed query.
+type Plan struct {
+	// Raw holds the raw output of the EXPLAIN statement.
+	Raw string
+	// Nodes holds the nodes of the plan in depth-first order. For databases
+	// that return a textual plan, it contains only the scan nodes that were
+	// detected in the output.
+	Nodes []Node
+}
+
+// Node is a single step in the execution plan.
+type Node struct {
+	// Type is the type of the node. For example, "Seq Scan" or "Index Scan".
+	Type string
+	// Table is the table the node reads from, if any.
+	Table string
+	// Index is the index used by the node, if any.
+	Index string
+	// Cost is the estimated total cost of the node. Zero if it is unknown.
+	Cost float64
+	// Rows is the estimated number of rows returned by the node. Zero if it is unknown.
+	Rows float64
+	// SeqScan reports if the node scans the entire table.
+	SeqScan bool
+}
+
+// SeqScans returns the names of the tables that are scanned sequentially by the plan.
+func (p *Plan) SeqScans() []string {
+	var tables []string
+	for _, n := range p.Nodes {
+		if n.SeqScan {
+			tables = append(tables, n.Table)
+		}
+	}
+	return tables
+}
+
+// Explain runs EXPLAIN on a SELECT statement built from the given table and params,
+// and returns a summary of the plan. It is useful for flagging filters that cause
+// sequential scans in staging environments. For example:
+//
+//	plan, err := rqlsql.Explain(ctx, db, "users", params)
+//	if err != nil {
+//		return err
+//	}
+//	if tables := plan.SeqScans(); len(tables) > 0 {
+//		log.Printf("query %q scans tables: %v", params.FilterExp, tables)
+//	}
+//
+// Explain tries the PostgreSQL JSON format first, and falls back to a plain EXPLAIN
+// (MySQL, SQLite and others) if the database rejects it. Note that a failed statement
+// aborts the current transaction in PostgreSQL, so Explain should not run inside one
+// on other databases.
+func Explain(ctx context.Context, db Querier, table string, p *rql.Params) (*Plan, error) {
+	stmt := selectStmt(table, p)
+	if plan, err := explainJSON(ctx, db, stmt, p.FilterArgs); err == nil {
+		return plan, nil
+	}
+	return explainText(ctx, db, stmt, p.FilterArgs)
+}
+
+// selectStmt builds the SELECT statement that is explained.
+func selectStmt(table string, p *rql.Params) string {
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	if p.Select != "" {
+		b.WriteString(p.Select)
+	} else {
+		b.WriteString("*")
+	}
+	b.WriteString(" FROM ")
+	b.WriteString(table)
+	for _, j := range p.Joins {
+		b.WriteString(" ")
+		b.WriteString(j.String())
+	}
+	if p.FilterExp != "" {
+		b.WriteString(" WHERE ")
+		b.WriteString(p.FilterExp)
+	}
+	if p.Sort != "" {
+		b.WriteString(" ORDER BY ")
+		b.WriteString(p.Sort)
+	}
+	return b.String()
+}
+
+// pgPlan is a node in the PostgreSQL JSON plan.
+type pgPlan struct {
+	NodeType  string   `json:"Node Type"`
+	Relation  string   `json:"Relation Name"`
+	Index     string   `json:"Index Name"`
+	TotalCost float64  `json:"Total Cost"`
+	PlanRows  float64  `json:"Plan Rows"`
+	Plans     []pgPlan `json:"Plans"`
+}
+
+// explainJSON runs EXPLAIN (FORMAT JSON) and parses its output.
+func explainJSON(ctx context.Context, db Querier, stmt string, args []interface{}) (*Plan, error) {
+	rows, err := db.QueryContext(ctx, "EXPLAIN (FORMAT JSON) "+stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var raw []byte
+	for rows.Next() {
+		var b []byte
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		raw = append(raw, b...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var out []struct {
+		Plan pgPlan `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	plan := &Plan{Raw: string(raw)}
+	var walk func(pgPlan)
+	walk = func(n pgPlan) {
+		plan.Nodes = append(plan.Nodes, Node{
+			Type:    n.NodeType,
+			Table:   n.Relation,
+			Index:   n.Index,
+			Cost:    n.TotalCost,
+			Rows:    n.PlanRows,
+			SeqScan: n.NodeType == "Seq Scan",
+		})
+		for _, c := range n.Plans {
+			walk(c)
+		}
+	}
+	for _, o := range out {
+		walk(o.Plan)
+	}
+	return plan, nil
+}
+
+// scanLine matches sequential scans in textual plans of PostgreSQL ("Seq Scan on users")
+// and SQLite ("SCAN users" or "SCAN TABLE users").
+var scanLine = regexp.MustCompile(`(Seq Scan on|SCAN(?: TABLE)?) ("?[\w.]+"?)`)
+
+// explainText runs a plain EXPLAIN and detects the scan nodes in its output.
+func explainText(ctx context.Context, db Querier, stmt string, args []interface{}) (*Plan, error) {
+	rows, err := db.QueryContext(ctx, "EXPLAIN "+stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	// MySQL returns a row per table, with the access type in the "type" column.
+	tableIdx, typeIdx, keyIdx := -1, -1, -1
+	for i, c := range cols {
+		switch strings.ToLower(c) {
+		case "table":
+			tableIdx = i
+		case "type":
+			typeIdx = i
+		case "key":
+			keyIdx = i
+		}
+	}
+	plan := &Plan{}
+	var lines []string
+	for rows.Next() {
+		values := make([]sql.NullString, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		line := make([]string, len(values))
+		for i, v := range values {
+			line[i] = v.String
+		}
+		lines = append(lines, strings.Join(line, "\t"))
+		switch {
+		case tableIdx != -1 && typeIdx != -1:
+			n := Node{Type: values[typeIdx].String, Table: values[tableIdx].String, SeqScan: values[typeIdx].String == "ALL"}
+			if keyIdx != -1 {
+				n.Index = values[keyIdx].String
+			}
+			plan.Nodes = append(plan.Nodes, n)
+		default:
+			for _, m := range scanLine.FindAllStringSubmatch(lines[len(lines)-1], -1) {
+				plan.Nodes = append(plan.Nodes, Node{Type: m[1], Table: strings.Trim(m[2], `"`), SeqScan: true})
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	plan.Raw = strings.Join(lines, "\n")
+	return plan, nil
+}
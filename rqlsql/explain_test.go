@@ -0,0 +1,153 @@
+package rqlsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/a8m/rql"
+)
+
+// fakeDriver returns canned results for EXPLAIN statements, and records the executed queries.
+type fakeDriver struct {
+	json    string
+	columns []string
+	rows    [][]driver.Value
+	queries []string
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not implemented") }
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.d.queries = append(c.d.queries, query)
+	if strings.HasPrefix(query, "EXPLAIN (FORMAT JSON)") {
+		if c.d.json == "" {
+			return nil, errors.New("syntax error")
+		}
+		return &fakeRows{columns: []string{"QUERY PLAN"}, rows: [][]driver.Value{{[]byte(c.d.json)}}}, nil
+	}
+	return &fakeRows{columns: c.d.columns, rows: c.d.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestExplain(t *testing.T) {
+	p := rql.MustNewParser(rql.Config{
+		Model: new(struct {
+			Age  int    `rql:"filter,sort"`
+			Name string `rql:"filter"`
+		}),
+		BindStyle: rql.BindDollar,
+	})
+	params, err := p.Parse([]byte(`{"filter": {"age": {"$gt": 10}}, "sort": ["-age"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		driver    *fakeDriver
+		wantNodes []Node
+		wantSeq   []string
+	}{
+		{
+			name: "postgres",
+			driver: &fakeDriver{
+				json: `[{"Plan": {"Node Type": "Sort", "Total Cost": 20.5, "Plan Rows": 10, "Plans": [
+					{"Node Type": "Seq Scan", "Relation Name": "users", "Total Cost": 18, "Plan Rows": 10}
+				]}}]`,
+			},
+			wantNodes: []Node{
+				{Type: "Sort", Cost: 20.5, Rows: 10},
+				{Type: "Seq Scan", Table: "users", Cost: 18, Rows: 10, SeqScan: true},
+			},
+			wantSeq: []string{"users"},
+		},
+		{
+			name: "mysql",
+			driver: &fakeDriver{
+				columns: []string{"id", "select_type", "table", "type", "key"},
+				rows: [][]driver.Value{
+					{int64(1), "SIMPLE", "users", "range", "idx_age"},
+					{int64(1), "SIMPLE", "teams", "ALL", nil},
+				},
+			},
+			wantNodes: []Node{
+				{Type: "range", Table: "users", Index: "idx_age"},
+				{Type: "ALL", Table: "teams", SeqScan: true},
+			},
+			wantSeq: []string{"teams"},
+		},
+		{
+			name: "sqlite",
+			driver: &fakeDriver{
+				columns: []string{"id", "parent", "notused", "detail"},
+				rows: [][]driver.Value{
+					{int64(2), int64(0), int64(0), "SCAN TABLE users"},
+					{int64(3), int64(0), int64(0), "USE TEMP B-TREE FOR ORDER BY"},
+				},
+			},
+			wantNodes: []Node{
+				{Type: "SCAN TABLE", Table: "users", SeqScan: true},
+			},
+			wantSeq: []string{"users"},
+		},
+		{
+			name: "index only",
+			driver: &fakeDriver{
+				columns: []string{"QUERY PLAN"},
+				rows:    [][]driver.Value{{"Index Scan using idx_age on users"}},
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "fake-explain-" + string(rune('a'+i))
+			sql.Register(name, tt.driver)
+			db, err := sql.Open(name, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			plan, err := Explain(context.Background(), db, "users", params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(plan.Nodes, tt.wantNodes) {
+				t.Errorf("nodes = %+v, want %+v", plan.Nodes, tt.wantNodes)
+			}
+			if seq := plan.SeqScans(); !reflect.DeepEqual(seq, tt.wantSeq) {
+				t.Errorf("seq scans = %v, want %v", seq, tt.wantSeq)
+			}
+			last := tt.driver.queries[len(tt.driver.queries)-1]
+			if want := "SELECT * FROM users WHERE age > $1 ORDER BY age desc"; !strings.HasSuffix(last, want) {
+				t.Errorf("query = %q, want suffix %q", last, want)
+			}
+		})
+	}
+}
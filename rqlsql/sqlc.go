@@ -0,0 +1,163 @@
+package rqlsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/a8m/rql"
+)
+
+// Markers of the query shells that are composed with params. They are SQL comments, so the
+// shells stay valid SQL for code generators like sqlc.
+const (
+	MarkJoins  = "/*rql:joins*/"  // replaced with the JOIN clauses of the params.
+	MarkFilter = "/*rql:filter*/" // replaced with "AND (<filter>)", or removed if the filter is empty.
+	MarkSort   = "/*rql:sort*/"   // replaced with "ORDER BY <sort>", or removed if the sort is empty.
+	MarkLimit  = "/*rql:limit*/"  // replaced with "LIMIT <limit> OFFSET <offset>", or only the offset if there is no limit.
+)
+
+// Compose composes the given params into a query shell, like the queries that are generated by
+// sqlc, and returns the query and its arguments. It allows sqlc users to add rql filtering to
+// list endpoints, instead of writing a query for each permutation of filters. For example:
+//
+//	-- name: ListUsers :many
+//	SELECT * FROM users /*rql:joins*/
+//	WHERE org_id = $1 /*rql:filter*/
+//	/*rql:sort*/ /*rql:limit*/;
+//
+//	query, args, err := rqlsql.Compose(listUsers, []interface{}{orgID}, params)
+//
+// The placeholders of the filter follow the placeholders of the shell: numbered placeholders
+// ($1) are renumbered after the arguments of the shell, and the arguments of "?" placeholders
+// are inserted according to their position. Compose fails if the params have joins, a filter
+// or a sort, and the shell does not have the matching marker. The limit marker is optional, for
+// queries that paginate on their own.
+func Compose(query string, args []interface{}, p *rql.Params) (string, []interface{}, error) {
+	for _, m := range []struct {
+		mark string
+		used bool
+	}{
+		{MarkJoins, len(p.Joins) > 0},
+		{MarkFilter, p.FilterExp != ""},
+		{MarkSort, p.Sort != ""},
+	} {
+		if m.used && !strings.Contains(query, m.mark) {
+			return "", nil, fmt.Errorf("rqlsql: query is missing the %s marker", m.mark)
+		}
+	}
+	var joins []string
+	for _, j := range p.Joins {
+		joins = append(joins, j.String())
+	}
+	filter, fargs := p.FilterExp, p.FilterArgs
+	if dollar.MatchString(filter) {
+		filter = dollar.ReplaceAllStringFunc(filter, func(s string) string {
+			n, _ := strconv.Atoi(s[1:])
+			return "$" + strconv.Itoa(n+len(args))
+		})
+		args = append(args[:len(args):len(args)], fargs...)
+	} else if i := strings.Index(query, MarkFilter); i >= 0 {
+		n := placeholders(query[:i])
+		if n > len(args) {
+			return "", nil, errors.New("rqlsql: query has more placeholders than arguments")
+		}
+		args = append(append(append([]interface{}(nil), args[:n]...), fargs...), args[n:]...)
+	} else {
+		args = append(args[:len(args):len(args)], fargs...)
+	}
+	if filter != "" {
+		filter = "AND (" + filter + ")"
+	}
+	var sort string
+	if p.Sort != "" {
+		sort = "ORDER BY " + p.Sort
+	}
+	// params without a limit (see rql.Config.NoDefaultLimit) return all rows.
+	var limit string
+	switch {
+	case p.Limit > 0:
+		limit = "LIMIT " + strconv.Itoa(p.Limit) + " OFFSET " + strconv.Itoa(p.Offset)
+	case p.Offset > 0:
+		limit = "OFFSET " + strconv.Itoa(p.Offset)
+	}
+	query = strings.NewReplacer(
+		MarkJoins, strings.Join(joins, " "),
+		MarkFilter, filter,
+		MarkSort, sort,
+		MarkLimit, limit,
+	).Replace(query)
+	return query, args, nil
+}
+
+// dollar matches numbered placeholders.
+var dollar = regexp.MustCompile(`\$\d+`)
+
+// placeholders counts the "?" placeholders in the given SQL, skipping quoted strings,
+// quoted identifiers and comments.
+func placeholders(s string) int {
+	var n int
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '?':
+			n++
+		case c == '\'' || c == '"' || c == '`':
+			if j := strings.IndexByte(s[i+1:], c); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(s)
+			}
+		case strings.HasPrefix(s[i:], "--"):
+			if j := strings.IndexByte(s[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(s)
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			if j := strings.Index(s[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(s)
+			}
+		}
+	}
+	return n
+}
+
+// DBTX is the interface of the database connection that is used by the code generated by sqlc.
+// It is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// WithParams returns a DBTX that composes the given params into the queries that are executed
+// with QueryContext (see Compose). It allows using the methods generated by sqlc as is:
+//
+//	users, err := db.New(rqlsql.WithParams(conn, params)).ListUsers(ctx, orgID)
+//
+// Other methods are passed to the underlying connection as is. Note that prepared queries
+// (the emit_prepared_queries option of sqlc) are not composed.
+func WithParams(db DBTX, p *rql.Params) DBTX {
+	return &paramsDB{DBTX: db, p: p}
+}
+
+type paramsDB struct {
+	DBTX
+	p *rql.Params
+}
+
+// QueryContext composes the params into the query, and executes it.
+func (d *paramsDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args, err := Compose(query, args, d.p)
+	if err != nil {
+		return nil, err
+	}
+	return d.DBTX.QueryContext(ctx, query, args...)
+}
@@ -0,0 +1,133 @@
+package rqlsql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/a8m/rql"
+)
+
+func TestCompose(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		args      []interface{}
+		params    *rql.Params
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   bool
+	}{
+		{
+			name:  "dollar",
+			query: "SELECT * FROM users /*rql:joins*/ WHERE org_id = $1 /*rql:filter*/ /*rql:sort*/ /*rql:limit*/",
+			args:  []interface{}{10},
+			params: &rql.Params{
+				Limit:      25,
+				Offset:     50,
+				FilterExp:  "name = $1 OR age > $2",
+				FilterArgs: []interface{}{"a8m", 20},
+				Sort:       "age desc",
+				Joins:      []rql.JoinClause{{Table: "orgs", On: "orgs.id = users.org_id"}},
+			},
+			wantQuery: "SELECT * FROM users JOIN orgs ON orgs.id = users.org_id WHERE org_id = $1 AND (name = $2 OR age > $3) ORDER BY age desc LIMIT 25 OFFSET 50",
+			wantArgs:  []interface{}{10, "a8m", 20},
+		},
+		{
+			name:  "question",
+			query: "SELECT * FROM users WHERE org_id = ? /*rql:filter*/ AND name <> '?' AND status = ? /*rql:sort*/",
+			args:  []interface{}{10, "active"},
+			params: &rql.Params{
+				Limit:      25,
+				FilterExp:  "age > ?",
+				FilterArgs: []interface{}{20},
+			},
+			wantQuery: "SELECT * FROM users WHERE org_id = ? AND (age > ?) AND name <> '?' AND status = ? ",
+			wantArgs:  []interface{}{10, 20, "active"},
+		},
+		{
+			name:   "empty",
+			query:  "SELECT * FROM users WHERE org_id = $1 /*rql:filter*/",
+			args:   []interface{}{10},
+			params: &rql.Params{Limit: 25},
+			// the limit marker is optional.
+			wantQuery: "SELECT * FROM users WHERE org_id = $1 ",
+			wantArgs:  []interface{}{10},
+		},
+		{
+			name:      "no limit",
+			query:     "SELECT * FROM users WHERE org_id = $1 /*rql:limit*/",
+			args:      []interface{}{10},
+			params:    &rql.Params{},
+			wantQuery: "SELECT * FROM users WHERE org_id = $1 ",
+			wantArgs:  []interface{}{10},
+		},
+		{
+			name:      "offset without limit",
+			query:     "SELECT * FROM users WHERE org_id = $1 /*rql:limit*/",
+			args:      []interface{}{10},
+			params:    &rql.Params{Offset: 50},
+			wantQuery: "SELECT * FROM users WHERE org_id = $1 OFFSET 50",
+			wantArgs:  []interface{}{10},
+		},
+		{
+			name:    "missing filter marker",
+			query:   "SELECT * FROM users",
+			params:  &rql.Params{Limit: 25, FilterExp: "age > ?", FilterArgs: []interface{}{20}},
+			wantErr: true,
+		},
+		{
+			name:    "missing sort marker",
+			query:   "SELECT * FROM users /*rql:filter*/",
+			params:  &rql.Params{Limit: 25, Sort: "age"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := Compose(tt.query, tt.args, tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expect error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if query != tt.wantQuery {
+				t.Fatalf("query:\n\tgot:  %q\n\twant: %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Fatalf("args:\n\tgot:  %v\n\twant: %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// recordDB records the queries that were executed with QueryContext.
+type recordDB struct {
+	DBTX
+	queries []string
+}
+
+func (d *recordDB) QueryContext(_ context.Context, query string, _ ...interface{}) (*sql.Rows, error) {
+	d.queries = append(d.queries, query)
+	return nil, nil
+}
+
+func TestWithParams(t *testing.T) {
+	rec := &recordDB{}
+	db := WithParams(rec, &rql.Params{Limit: 10, FilterExp: "age > $1", FilterArgs: []interface{}{20}})
+	if _, err := db.QueryContext(context.Background(), "SELECT * FROM users WHERE TRUE /*rql:filter*/ /*rql:limit*/"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := db.QueryContext(context.Background(), "SELECT * FROM users"); err == nil || !strings.Contains(err.Error(), "marker") {
+		t.Fatalf("expect missing marker error, got: %v", err)
+	}
+	if want := []string{"SELECT * FROM users WHERE TRUE AND (age > $1) LIMIT 10 OFFSET 0"}; !reflect.DeepEqual(rec.queries, want) {
+		t.Fatalf("unexpected queries: %q", rec.queries)
+	}
+}
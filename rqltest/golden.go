@@ -0,0 +1,46 @@
+package rqltest
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/a8m/rql"
+)
+
+// update is the flag for rewriting the golden files with the actual output:
+//
+//	go test ./... -rqltest.update
+var update = flag.Bool("rqltest.update", false, "update rqltest golden files")
+
+// AssertGolden fails the test if the canonical form of the params (see rql.Params.Canonical)
+// does not match the content of the golden file at the given path. If the -rqltest.update
+// flag is set, the golden file is created or overwritten instead. For example:
+//
+//	params, err := parser.Parse(input)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	rqltest.AssertGolden(t, "testdata/users_by_age.golden", params)
+func AssertGolden(t testing.TB, path string, p *rql.Params) {
+	t.Helper()
+	got := p.Canonical() + "\n"
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("create golden dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("write golden file: %v", err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -rqltest.update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Fatalf("golden file %s mismatch:\n\tgot:\n%s\n\twant:\n%s", path, got, want)
+	}
+}
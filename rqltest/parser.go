@@ -0,0 +1,71 @@
+package rqltest
+
+import (
+	"sync"
+
+	"github.com/a8m/rql"
+)
+
+// Parser is a fake implementation of rql.QueryParser that returns canned results,
+// and records its inputs. It is safe for concurrent use. For example:
+//
+//	p := &rqltest.Parser{Params: &rql.Params{Limit: 10, FilterExp: "age > ?", FilterArgs: []interface{}{10}}}
+//	h := NewHandler(db, p)
+//	h.ServeHTTP(rec, req)
+//	if len(p.Inputs()) != 1 {
+//		t.Fatal("expect handler to parse the query")
+//	}
+type Parser struct {
+	// Params and Err are the results returned from all calls.
+	Params *rql.Params
+	Err    error
+	// ParseFn, if not nil, is called instead of returning Params and Err.
+	ParseFn func(*rql.Query) (*rql.Params, error)
+
+	mu      sync.Mutex
+	inputs  [][]byte
+	queries []*rql.Query
+}
+
+var _ rql.QueryParser = (*Parser)(nil)
+
+// Parse records the given buffer and returns the canned result. If ParseFn is set, the
+// buffer is decoded into a Query before it is passed to it.
+func (p *Parser) Parse(b []byte) (*rql.Params, error) {
+	p.mu.Lock()
+	p.inputs = append(p.inputs, append([]byte(nil), b...))
+	p.mu.Unlock()
+	if p.ParseFn == nil {
+		return p.Params, p.Err
+	}
+	q := &rql.Query{}
+	if err := q.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	return p.ParseFn(q)
+}
+
+// ParseQuery records the given query and returns the canned result.
+func (p *Parser) ParseQuery(q *rql.Query) (*rql.Params, error) {
+	p.mu.Lock()
+	p.queries = append(p.queries, q)
+	p.mu.Unlock()
+	if p.ParseFn != nil {
+		return p.ParseFn(q)
+	}
+	return p.Params, p.Err
+}
+
+// Inputs returns the buffers that were passed to Parse.
+func (p *Parser) Inputs() [][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]byte(nil), p.inputs...)
+}
+
+// Queries returns the queries that were passed to ParseQuery.
+func (p *Parser) Queries() []*rql.Query {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*rql.Query(nil), p.queries...)
+}
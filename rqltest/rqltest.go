@@ -0,0 +1,178 @@
+// Package rqltest provides helpers for testing rql parser configurations.
+//
+// The generated filter expressions are deterministic for a given input, but the order of
+// the terms in a conjunction or disjunction depends on the order of the keys in the JSON
+// object. The helpers in this package compare expressions and arguments regardless of
+// this order. For example:
+//
+//	func TestUsersQuery(t *testing.T) {
+//		params, err := parser.Parse([]byte(`{"filter": {"age": 10, "name": "a8m"}}`))
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		rqltest.AssertParams(t, params, &rql.Params{
+//			Limit:      25,
+//			FilterExp:  "name = ? AND age = ?",
+//			FilterArgs: []interface{}{"a8m", 10},
+//		})
+//	}
+package rqltest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/a8m/rql"
+)
+
+// AssertParams fails the test if the given params are not equal. The filter expressions
+// are compared using AssertSQLEquivalent rules, and the filter arguments are compared
+// regardless of their order.
+func AssertParams(t testing.TB, got, want *rql.Params) {
+	t.Helper()
+	if got == nil || want == nil {
+		if got != want {
+			t.Fatalf("params: got: %v want %v", got, want)
+		}
+		return
+	}
+	if got.Limit != want.Limit {
+		t.Fatalf("limit: got: %v want %v", got.Limit, want.Limit)
+	}
+	if got.Offset != want.Offset {
+		t.Fatalf("offset: got: %v want %v", got.Offset, want.Offset)
+	}
+	if got.Sort != want.Sort {
+		t.Fatalf("sort: got: %q want %q", got.Sort, want.Sort)
+	}
+	if got.Select != want.Select {
+		t.Fatalf("select: got: %q want %q", got.Select, want.Select)
+	}
+	if !equivalent(got.FilterExp, want.FilterExp) {
+		t.Fatalf("filter expr:\n\tgot: %q\n\twant %q", got.FilterExp, want.FilterExp)
+	}
+	if !equalArgs(got.FilterArgs, want.FilterArgs) {
+		t.Fatalf("filter args:\n\tgot: %v\n\twant %v", got.FilterArgs, want.FilterArgs)
+	}
+	if len(got.Joins) > 0 || len(want.Joins) > 0 {
+		if !reflect.DeepEqual(got.Joins, want.Joins) {
+			t.Fatalf("joins:\n\tgot: %v\n\twant %v", got.Joins, want.Joins)
+		}
+	}
+}
+
+// AssertSQLEquivalent fails the test if the given filter expressions are not equivalent.
+// Two expressions are equivalent if they contain the same terms joined by the same operator,
+// regardless of their order. Parenthesized groups are compared recursively.
+func AssertSQLEquivalent(t testing.TB, got, want string) {
+	t.Helper()
+	if !equivalent(got, want) {
+		t.Fatalf("filter expr:\n\tgot: %q\n\twant %q", got, want)
+	}
+}
+
+// equalArgs reports if the two lists contain the same arguments, regardless of their order.
+func equalArgs(a, b []interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make([]bool, len(b))
+	for _, arg1 := range a {
+		var found bool
+		for i, arg2 := range b {
+			// skip values that matched before.
+			if !seen[i] && reflect.DeepEqual(arg1, arg2) {
+				seen[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// equivalent reports if the two expressions are equal, regardless of the order of their terms.
+func equivalent(e1, e2 string) bool {
+	s1, op1 := split(e1)
+	s2, op2 := split(e2)
+	if len(s1) != len(s2) || op1 != op2 {
+		return false
+	}
+	seen := make([]bool, len(s2))
+	for i := range s1 {
+		var found bool
+		for j := range s2 {
+			if seen[j] {
+				continue
+			}
+			if g1, g2 := group(s1[i]), group(s2[j]); g1 && g2 {
+				found = equivalent(s1[i][1:len(s1[i])-1], s2[j][1:len(s2[j])-1])
+			} else {
+				found = s1[i] == s2[j]
+			}
+			if found {
+				seen[j] = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// group reports if the term is entirely wrapped with parentheses.
+func group(s string) bool {
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return false
+	}
+	depth := 0
+	for i := 0; i < len(s)-1; i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if depth == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// split splits the expression into its top-level terms, and returns them with
+// the operator that joins them.
+func split(e string) ([]string, string) {
+	var (
+		s  []string
+		op string
+	)
+	for len(e) > 0 {
+		// find the end of the term, that is the first separator outside of parentheses.
+		end, sep, depth := len(e), "", 0
+		for i := 0; i < len(e) && end == len(e); i++ {
+			switch {
+			case e[i] == '(':
+				depth++
+			case e[i] == ')':
+				depth--
+			case depth == 0 && strings.HasPrefix(e[i:], " AND "):
+				end, sep = i, " AND "
+			case depth == 0 && strings.HasPrefix(e[i:], " OR "):
+				end, sep = i, " OR "
+			}
+		}
+		s = append(s, e[:end])
+		e = e[end+len(sep):]
+		if sep != "" {
+			op = sep
+		}
+	}
+	return s, op
+}
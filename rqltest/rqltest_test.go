@@ -0,0 +1,105 @@
+package rqltest
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/a8m/rql"
+)
+
+func TestEquivalent(t *testing.T) {
+	tests := []struct {
+		e1, e2 string
+		want   bool
+	}{
+		{"", "", true},
+		{"a = ?", "a = ?", true},
+		{"a = ? AND b = ?", "b = ? AND a = ?", true},
+		{"a = ? AND (b = ? OR c = ?)", "(c = ? OR b = ?) AND a = ?", true},
+		{"a IN (?, ?) AND b = ?", "b = ? AND a IN (?, ?)", true},
+		{"a = ? AND b = ?", "a = ? OR b = ?", false},
+		{"a = ? AND a = ?", "a = ? AND b = ?", false},
+		{"a = ?", "a = ? AND b = ?", false},
+		{"(a = ? OR b = ?)", "(a = ? AND b = ?)", false},
+		{"(SELECT COUNT(*) FROM t) > ?", "(SELECT COUNT(*) FROM t) > ?", true},
+	}
+	for _, tt := range tests {
+		if got := equivalent(tt.e1, tt.e2); got != tt.want {
+			t.Errorf("equivalent(%q, %q) = %v, want %v", tt.e1, tt.e2, got, tt.want)
+		}
+		if got := equivalent(tt.e2, tt.e1); got != tt.want {
+			t.Errorf("equivalent(%q, %q) = %v, want %v", tt.e2, tt.e1, got, tt.want)
+		}
+	}
+}
+
+func TestAssertParams(t *testing.T) {
+	p := rql.MustNewParser(rql.Config{
+		Model: new(struct {
+			Age  int    `rql:"filter"`
+			Name string `rql:"filter"`
+		}),
+	})
+	params, err := p.Parse([]byte(`{"filter": {"$or": [{"age": 1}, {"name": "a"}], "age": {"$gt": 0}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertParams(t, params, &rql.Params{
+		Limit:      25,
+		FilterExp:  "age > ? AND (name = ? OR age = ?)",
+		FilterArgs: []interface{}{"a", 0, 1},
+	})
+	AssertSQLEquivalent(t, params.FilterExp, "(age = ? OR name = ?) AND age > ?")
+}
+
+func TestAssertGolden(t *testing.T) {
+	p := rql.MustNewParser(rql.Config{
+		Model: new(struct {
+			Age  int    `rql:"filter"`
+			Name string `rql:"filter"`
+		}),
+	})
+	params, err := p.Parse([]byte(`{"filter": {"name": "a", "age": {"$gt": 0}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "testdata", "params.golden")
+	*update = true
+	AssertGolden(t, path, params)
+	*update = false
+	AssertGolden(t, path, params)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "filter: age > 0 AND name = 'a'\nlimit: 25\noffset: 0\n"; string(b) != want {
+		t.Fatalf("golden file: got %q, want %q", b, want)
+	}
+}
+
+func TestParser(t *testing.T) {
+	want := &rql.Params{Limit: 10}
+	p := &Parser{Params: want}
+	if got, err := p.Parse([]byte(`{"limit": 10}`)); err != nil || got != want {
+		t.Fatalf("Parse = %v, %v", got, err)
+	}
+	if got, err := p.ParseQuery(&rql.Query{Limit: 10}); err != nil || got != want {
+		t.Fatalf("ParseQuery = %v, %v", got, err)
+	}
+	if in := p.Inputs(); len(in) != 1 || string(in[0]) != `{"limit": 10}` {
+		t.Fatalf("unexpected inputs: %q", in)
+	}
+	if qs := p.Queries(); len(qs) != 1 || qs[0].Limit != 10 {
+		t.Fatalf("unexpected queries: %v", qs)
+	}
+	p = &Parser{ParseFn: func(q *rql.Query) (*rql.Params, error) {
+		return &rql.Params{Limit: q.Limit}, nil
+	}}
+	if got, err := p.Parse([]byte(`{"limit": 5}`)); err != nil || got.Limit != 5 {
+		t.Fatalf("Parse = %v, %v", got, err)
+	}
+	if _, err := p.Parse([]byte(`{`)); err == nil {
+		t.Fatal("expect error for invalid input")
+	}
+}
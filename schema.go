@@ -0,0 +1,136 @@
+package rql
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// FieldSpec describes a field that is added to a parser after its creation. See Parser.AddField.
+type FieldSpec struct {
+	// Name is the name of the field in the query. Unless Options has the "column" or the "expr"
+	// options, the column of the field is derived from its name.
+	Name string
+	// Type is the Go type of the field values. For example, reflect.TypeOf("") or
+	// reflect.TypeOf(time.Time{}). All types that are supported in the model are accepted.
+	Type reflect.Type
+	// Options are the options of the field, in the format of the struct tag. For example,
+	// "filter,sort" or "filter,expr=attrs->>'color'".
+	Options string
+}
+
+// AddField adds a field to the parser after its creation. It allows long-running services to
+// expose new fields, like custom attributes, without rebuilding the parser. For example:
+//
+//	err := p.AddField(rql.FieldSpec{
+//		Name:    "color",
+//		Type:    reflect.TypeOf(""),
+//		Options: "filter,sort,expr=attrs->>'color'",
+//	})
+//
+// It is safe to call AddField while the parser is used by other goroutines. Parsers that
+// were returned from For before the call are not changed.
+func (p *Parser) AddField(spec FieldSpec) error {
+	if spec.Name == "" || spec.Type == nil {
+		return fmt.Errorf("rql: field spec must have a 'Name' and a 'Type'")
+	}
+	f, err := p.newField(reflect.StructField{
+		Name: spec.Name,
+		Type: spec.Type,
+		Tag:  reflect.StructTag(p.conf.TagName + ":" + strconv.Quote(spec.Options)),
+	}, p.conf.Log)
+	if err != nil {
+		return err
+	}
+	// the "column" option renames the field, and in this case its name is used as the column.
+	if f.Column == "" && f.Name != p.conf.ColumnFn(spec.Name) {
+		f.Column = p.colName(f.Name)
+	}
+	f.Name = spec.Name
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, name := range append([]string{f.Name}, f.Aliases...) {
+		switch {
+		case p.fields[name] != nil:
+			return fmt.Errorf("rql: field %q conflicts with an existing field", name)
+		case p.relations[name] != nil:
+			return fmt.Errorf("rql: field %q conflicts with a relation with the same name", name)
+		case p.windows[name] != "":
+			return fmt.Errorf("rql: field %q conflicts with a window with the same name", name)
+		}
+		if _, ok := p.conf.Computed[name]; ok {
+			return fmt.Errorf("rql: field %q conflicts with a computed field with the same name", name)
+		}
+	}
+	if p.conf.Collate != nil && f.Collate == "" {
+		f.Collate = p.conf.Collate(p.meta(f))
+	}
+	for _, spec := range p.extraOps {
+		p.extraFieldOp(f, spec)
+	}
+	f.meta = p.meta(f)
+	skipped := f.skipped
+	if p.conf.Dialect == DialectCQL {
+		if r := p.cqlField(f); r != "" {
+			skipped = append(skipped, r)
+		}
+	}
+	if p.conf.StrictTags && len(skipped) > 0 {
+		return fmt.Errorf("rql: strict tags: field %q: %s", f.Name, skipped[0])
+	}
+	fields := make(map[string]*field, len(p.fields)+1+len(f.Aliases))
+	for name, f := range p.fields {
+		fields[name] = f
+	}
+	prev := p.fields
+	p.fields = fields
+	if err := p.addField(f); err != nil {
+		p.fields = prev
+		return err
+	}
+	if err := p.initFolded(); err != nil {
+		p.fields = prev
+		p.initFolded()
+		return err
+	}
+	for _, r := range skipped {
+		p.skipped = append(p.skipped, Skipped{Field: f.Name, Reason: r})
+	}
+	p.versions = &sync.Map{}
+	p.initSchema()
+	return nil
+}
+
+// RemoveField removes the field with the given name, and its aliases, from the parser.
+// Queries that use the field are rejected after the call, as if it never existed. It is
+// safe to call RemoveField while the parser is used by other goroutines. Parsers that
+// were returned from For before the call are not changed.
+func (p *Parser) RemoveField(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, ok := p.fields[name]
+	if !ok || f.Name != name {
+		return fmt.Errorf("rql: unknown field %q", name)
+	}
+	for name, fi := range p.fields {
+		if fi == f {
+			delete(p.fields, name)
+		}
+	}
+	if f == p.softDelete {
+		p.softDelete = nil
+	}
+	skipped := p.skipped[:0:0]
+	for _, s := range p.skipped {
+		if s.Field != name {
+			skipped = append(skipped, s)
+		}
+	}
+	p.skipped = skipped
+	// errors are not possible, because removing fields doesn't add conflicts.
+	p.initFolded()
+	p.versions = &sync.Map{}
+	p.initSchema()
+	return nil
+}
@@ -0,0 +1,111 @@
+package rql
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAddField(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter,sort"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	if _, err := p.Parse([]byte(`{"filter": {"color": "red"}}`)); err == nil {
+		t.Fatal("expect unknown field error before AddField")
+	}
+	v1 := p.For("v1")
+	specs := []FieldSpec{
+		{Name: "color", Type: reflect.TypeOf(""), Options: "filter,sort,expr=attrs->>'color'"},
+		{Name: "size", Type: reflect.TypeOf(0), Options: "filter,alias=sz"},
+		{Name: "seen", Type: reflect.TypeOf(time.Time{}), Options: "filter,column=seen_at"},
+	}
+	for _, spec := range specs {
+		if err := p.AddField(spec); err != nil {
+			t.Fatalf("unexpected error for field %q: %v", spec.Name, err)
+		}
+	}
+	out, err := p.Parse([]byte(`{"filter": {"$and": [{"color": "red"}, {"sz": {"$gt": 1}}, {"seen": {"$gte": "2020-01-01T00:00:00Z"}}]}, "sort": ["-color"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertParams(t, out, &Params{
+		Limit:      25,
+		FilterExp:  "(attrs->>'color' = ? AND size > ? AND seen_at >= ?)",
+		FilterArgs: []interface{}{"red", 1, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Sort:       "attrs->>'color' desc",
+	})
+	if _, err := v1.Parse([]byte(`{"filter": {"color": "red"}}`)); err == nil {
+		t.Fatal("expect versioned parsers to keep their fields")
+	}
+	if _, err := p.For("v1").Parse([]byte(`{"filter": {"color": "red"}}`)); err != nil {
+		t.Fatalf("expect new versioned parsers to have the added field: %v", err)
+	}
+	for _, spec := range []FieldSpec{
+		{Name: "name", Type: reflect.TypeOf("")},
+		{Name: "weight", Type: reflect.TypeOf(0), Options: "filter,alias=size"},
+		{Name: "tags", Type: reflect.TypeOf([]string{})},
+		{Name: "", Type: reflect.TypeOf("")},
+	} {
+		if err := p.AddField(spec); err == nil {
+			t.Fatalf("expect error for field spec: %+v", spec)
+		}
+	}
+	if err := p.RemoveField("size"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, input := range []string{
+		`{"filter": {"size": 1}}`,
+		`{"filter": {"sz": 1}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil || !strings.Contains(err.Error(), "unrecognized key") {
+			t.Fatalf("expect unrecognized key error for input %s, got: %v", input, err)
+		}
+	}
+	if err := p.RemoveField("sz"); err == nil {
+		t.Fatal("expect error for removing an unknown field")
+	}
+	for _, m := range p.Fields() {
+		if m.Name == "size" {
+			t.Fatal("expect removed field to be excluded from Fields")
+		}
+	}
+}
+
+func TestAddFieldConcurrently(t *testing.T) {
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+		}),
+		CaseInsensitiveFields: true,
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := p.Parse([]byte(`{"filter": {"NAME": "a8m"}}`)); err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		if err := p.AddField(FieldSpec{Name: "color", Type: reflect.TypeOf(""), Options: "filter"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := p.RemoveField("color"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,71 @@
+package rql
+
+import (
+	"sort"
+	"strings"
+)
+
+// likeEscaper escapes the wildcards of the LIKE operator in search terms,
+// using the default escape character of MySQL and PostgreSQL.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// search matches the search term of the query against the searchable fields, and combines
+// it with the filter according to the SearchOp option. For example, with SearchOp OR:
+//
+//	{"filter": {"status": "active"}, "search": "a8m"}
+//	=> "status = ? OR (name LIKE ? OR email LIKE ?)"
+//
+// Searchable fields that LIKE is not allowed on by the AllowOp hook are skipped. If there are
+// no other fields, the search is rejected (or dropped in Sanitize mode).
+//
+// The search node is placed under the root conjunction of the filter, in order to keep the
+// predicates that are added later (e.g. soft-delete and cursors) applied on all rows.
+func (p *parseState) search(pr *Params, term string) {
+	expect(!p.conf.DisableSearch, CodeInvalidQuery, "", "search is disabled")
+	if p.conf.NormalizeStrings != 0 {
+		term = p.normalizeString(term)
+		expect(term != "", CodeInvalidValue, "", "search term contains only whitespace")
+	}
+	expect(!p.disabledOps[LIKE], CodeDisabledOp, "", "search is disabled, because op %q is disabled", p.op(LIKE))
+	var (
+		fields []*field
+		denied bool
+	)
+	for name, f := range p.fields {
+		if name != f.Name || !f.Searchable || p.allowed != nil && !p.allowed[name] {
+			continue
+		}
+		// fields that LIKE is not allowed on (see Config.AllowOp) are not searched.
+		if p.conf.AllowOp != nil && !p.conf.AllowOp(p.ctx, f.meta, LIKE) {
+			denied = true
+			continue
+		}
+		fields = append(fields, f)
+	}
+	if denied && len(fields) == 0 {
+		if p.sanitize {
+			defer p.drop(nil)
+		}
+		expect(false, CodeInvalidOp, "", "search is not allowed, because op %q is not allowed on the searchable fields", p.op(LIKE))
+	}
+	expect(len(fields) > 0, CodeInvalidQuery, "", "search is not supported, because there are no searchable fields")
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	pattern := "%" + likeEscaper.Replace(term) + "%"
+	s := p.newExpr(expr{op: OR, paren: true, search: true, children: p.newChildren(len(fields))})
+	for _, f := range fields {
+		s.add(p.predicate(f, nil, LIKE, pattern))
+		pr.SearchArgs = append(pr.SearchArgs, pattern)
+	}
+	pr.search = term
+	if p.conf.SearchOp != OR || pr.filter.empty() {
+		pr.filter.add(s)
+		return
+	}
+	filter := pr.filter
+	filter.paren = true
+	or := p.newExpr(expr{op: OR, paren: true, children: p.newChildren(2)})
+	or.add(filter)
+	or.add(s)
+	pr.filter = p.newExpr(expr{op: AND, children: p.newChildren(1)})
+	pr.filter.add(or)
+}
@@ -0,0 +1,132 @@
+package rql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mailru/easyjson/jlexer"
+	"github.com/mailru/easyjson/jwriter"
+)
+
+// sortExprToken matches the sort entries that reference an expression of Config.SortExprs.
+// The objects of the sort array are decoded into these entries. For example:
+//
+//	{"expr": "priority"}                => "expr(priority)"
+//	{"expr": "priority", "dir": "desc"} => "expr(priority) desc"
+var sortExprToken = regexp.MustCompile(`^expr\(([^()]+)\)(?: (asc|desc))?$`)
+
+// initSortExprs validates the sort expressions of the parser.
+func (p *Parser) initSortExprs() error {
+	for name, sql := range p.conf.SortExprs {
+		switch {
+		case name == "" || strings.ContainsAny(name, "()"):
+			return fmt.Errorf("rql: invalid name for sort expression %q", name)
+		case strings.TrimSpace(sql) == "":
+			return fmt.Errorf("rql: sort expression %q: missing SQL", name)
+		}
+	}
+	return nil
+}
+
+// sortExpr returns the expression of the given sort entry and its direction, if it
+// references an expression of Config.SortExprs.
+func (p *parseState) sortExpr(field string) (c, dir string, ok bool) {
+	m := sortExprToken.FindStringSubmatch(field)
+	if m == nil {
+		return "", "", false
+	}
+	c, ok = p.conf.SortExprs[m[1]]
+	expect(ok && (p.allowed == nil || p.allowed[m[1]]), CodeUnknownField, m[1], "unrecognized sort expression %q", m[1])
+	return c, m[2], true
+}
+
+// SortFields are the entries of the sort array of a query. Objects of the array reference
+// the expressions of Config.SortExprs, and they are decoded into "expr(name)" entries.
+type SortFields []string
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface.
+func (s *SortFields) UnmarshalEasyJSON(in *jlexer.Lexer) {
+	if in.IsNull() {
+		in.Skip()
+		*s = nil
+		return
+	}
+	in.Delim('[')
+	fs := make(SortFields, 0, 4)
+	for !in.IsDelim(']') {
+		if in.IsDelim('{') {
+			fs = append(fs, decodeSortExpr(in))
+		} else {
+			fs = append(fs, in.String())
+		}
+		in.WantComma()
+	}
+	in.Delim(']')
+	*s = fs
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface. Entries of sort
+// expressions are encoded as objects.
+func (s SortFields) MarshalEasyJSON(out *jwriter.Writer) {
+	if s == nil {
+		out.RawString("null")
+		return
+	}
+	out.RawByte('[')
+	for i, f := range s {
+		if i > 0 {
+			out.RawByte(',')
+		}
+		encodeSortEntry(out, f)
+	}
+	out.RawByte(']')
+}
+
+// decodeSortExpr decodes an object of the sort array into its entry.
+func decodeSortExpr(in *jlexer.Lexer) string {
+	var name, dir string
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "expr":
+			name = in.String()
+		case "dir":
+			dir = strings.ToLower(in.String())
+		default:
+			in.AddError(fmt.Errorf("unknown key %q in sort object", key))
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	switch {
+	case name == "":
+		in.AddError(errors.New("sort object must have an expr"))
+	case dir != "" && dir != "asc" && dir != "desc":
+		in.AddError(fmt.Errorf("invalid direction %q in sort object", dir))
+	case dir != "":
+		return "expr(" + name + ") " + dir
+	}
+	return "expr(" + name + ")"
+}
+
+// encodeSortEntry encodes an entry of the sort array. Entries of sort expressions
+// are encoded as objects.
+func encodeSortEntry(out *jwriter.Writer, s string) {
+	m := sortExprToken.FindStringSubmatch(s)
+	if m == nil {
+		out.String(s)
+		return
+	}
+	out.RawString(`{"expr":`)
+	out.String(m[1])
+	if m[2] != "" {
+		out.RawString(`,"dir":`)
+		out.String(m[2])
+	}
+	out.RawByte('}')
+}
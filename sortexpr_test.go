@@ -0,0 +1,83 @@
+package rql
+
+import "testing"
+
+func TestSortExprs(t *testing.T) {
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Name      string `rql:"filter,sort"`
+			CreatedAt int    `rql:"sort"`
+		}),
+		SortExprs: map[string]string{
+			"priority": "CASE status WHEN 'urgent' THEN 0 WHEN 'high' THEN 1 ELSE 2 END",
+			"length":   "LENGTH(name)",
+		},
+		Log: t.Logf,
+	})
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{"sort": [{"expr": "priority"}]}`, "CASE status WHEN 'urgent' THEN 0 WHEN 'high' THEN 1 ELSE 2 END"},
+		{`{"sort": [{"expr": "length", "dir": "DESC"}, "-created_at", "name"]}`, "LENGTH(name) desc, created_at desc, name"},
+		{`{"sort": ["name", {"dir": "asc", "expr": "length"}]}`, "name, LENGTH(name) asc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			out, err := p.Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.Sort != tt.want {
+				t.Fatalf("sort:\n\tgot: %q\n\twant: %q", out.Sort, tt.want)
+			}
+			b, err := p.Query(out).MarshalJSON()
+			if err != nil {
+				t.Fatalf("failed to marshal query: %v", err)
+			}
+			again, err := p.Parse(b)
+			if err != nil {
+				t.Fatalf("failed to parse formatted query %s: %v", b, err)
+			}
+			if again.Sort != tt.want {
+				t.Fatalf("sort of formatted query %s:\n\tgot: %q\n\twant: %q", b, again.Sort, tt.want)
+			}
+		})
+	}
+	for _, input := range []string{
+		`{"sort": [{"expr": "LENGTH(name)"}]}`,
+		`{"sort": [{"expr": "unknown"}]}`,
+		`{"sort": [{"expr": "name"}]}`,
+		`{"sort": [{"expr": "priority", "dir": "sideways"}]}`,
+		`{"sort": [{"expr": "priority", "sql": "1"}]}`,
+		`{"sort": [{}]}`,
+		`{"sort": ["priority"]}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Errorf("expected error for input: %s", input)
+		}
+	}
+	if _, err := p.ParseWithOptions([]byte(`{"sort": [{"expr": "priority"}]}`), ParseOptions{AllowedFields: []string{"name"}}); err == nil {
+		t.Error("expect sort expressions to respect the allowed fields")
+	}
+	out, err := MustNewParser(Config{
+		Model:     new(struct{ Name string }),
+		SortExprs: map[string]string{"priority": "1"},
+		Sanitize:  true,
+	}).Parse([]byte(`{"sort": [{"expr": "unknown"}, {"expr": "priority"}]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.Sort != "1" || len(out.Dropped) != 1 {
+		t.Fatalf("expect the unknown expression to be dropped, got: %q %v", out.Sort, out.Dropped)
+	}
+	for _, es := range []map[string]string{
+		{"": "1"},
+		{"p(x)": "1"},
+		{"priority": " "},
+	} {
+		if _, err := NewParser(Config{Model: new(struct{ Name string }), SortExprs: es}); err == nil {
+			t.Errorf("expect error for sort expressions: %v", es)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package rql
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unknown panics with a ParseError of the given code for the unrecognized name. In Suggest
+// mode, the closest names of the given candidates are added to the error. For example:
+//
+//	unrecognized key "nmae" for filtering, did you mean "name"?
+func (p *parseState) unknown(code ErrorCode, field, name, msg string, candidates func() []string) {
+	err := &ParseError{Code: code, Field: field, msg: msg}
+	if p.conf.Suggest {
+		err.Suggestions = suggest(name, candidates())
+	}
+	if n := len(err.Suggestions); n > 0 {
+		quoted := make([]string, n)
+		for i, s := range err.Suggestions {
+			quoted[i] = strconv.Quote(s)
+		}
+		err.msg += ", did you mean " + strings.Join(quoted, " or ") + "?"
+	}
+	panic(err)
+}
+
+// unknownField panics with an unrecognized key error for the given field name. The suggested
+// names are the fields (and the relations, for filtering) that the keep function accepts.
+func (p *parseState) unknownField(name, msg string, relations bool, keep func(*field) bool) {
+	p.unknown(CodeUnknownField, name, name, msg, func() []string {
+		var names []string
+		for k, f := range p.fields {
+			if k == f.Name && keep(f) && (p.allowed == nil || p.allowed[k]) {
+				names = append(names, k)
+			}
+		}
+		for k := range p.relations {
+			if relations && p.relation(k) != nil {
+				names = append(names, k)
+			}
+		}
+		return names
+	})
+}
+
+// Filters of the suggested fields.
+var (
+	anyField        = func(*field) bool { return true }
+	sortableField   = func(f *field) bool { return f.Sortable }
+	filterableField = func(f *field) bool { return f.Filterable }
+)
+
+// unknownOp panics with an invalid operator error for the given field. Operators are suggested
+// only for names that are not registered, because a known operator that is not supported by the
+// field (e.g. "$like" on a number) is not a typo.
+func (p *parseState) unknownOp(f *field, opName string) {
+	var known bool
+	for _, name := range p.ops {
+		known = known || name == opName
+	}
+	_, extra := p.extraOps[Op(strings.TrimPrefix(opName, p.conf.OpPrefix))]
+	p.unknown(CodeInvalidOp, f.Name, opName, "can not apply op "+strconv.Quote(opName)+" on field "+strconv.Quote(f.Name), func() []string {
+		if known || extra {
+			return nil
+		}
+		names := make([]string, 0, len(f.FilterOps))
+		for op := range f.FilterOps {
+			names = append(names, op)
+		}
+		return names
+	})
+}
+
+// maxSuggestions is the maximum number of suggestions in an error.
+const maxSuggestions = 3
+
+// suggest returns the closest candidates to the given name (i.e. the ones with the minimal
+// edit distance), ordered by name. The comparison is case-insensitive, and candidates that
+// their distance exceeds a third of the name length (rounded up) are not suggested.
+func suggest(name string, candidates []string) []string {
+	type match struct {
+		name string
+		dist int
+	}
+	var (
+		ms    []match
+		lower = strings.ToLower(name)
+		limit = (len([]rune(name)) + 2) / 3
+	)
+	for _, c := range candidates {
+		if d := levenshtein(lower, strings.ToLower(c)); d <= limit {
+			ms = append(ms, match{name: c, dist: d})
+		}
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].dist != ms[j].dist {
+			return ms[i].dist < ms[j].dist
+		}
+		return ms[i].name < ms[j].name
+	})
+	var names []string
+	for _, m := range ms {
+		if m.dist > ms[0].dist || len(names) == maxSuggestions {
+			break
+		}
+		names = append(names, m.name)
+	}
+	return names
+}
+
+// levenshtein returns the edit distance between the two strings. Transpositions of adjacent
+// characters (e.g. "nmae") are counted as one edit, as they are common typos.
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// prev2, prev and row are the last three rows of the distances matrix.
+	prev2, prev, row := make([]int, len(t)+1), make([]int, len(t)+1), make([]int, len(t)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		prev2, prev, row = prev, row, prev2
+		row[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			row[j] = min3(prev[j]+1, row[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] && prev2[j-2]+1 < row[j] {
+				row[j] = prev2[j-2] + 1
+			}
+		}
+	}
+	return row[len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
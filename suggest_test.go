@@ -0,0 +1,104 @@
+package rql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	model := new(struct {
+		Name      string `rql:"filter,sort"`
+		Email     string `rql:"filter"`
+		CreatedAt int    `rql:"sort"`
+	})
+	tests := []struct {
+		input string
+		code  ErrorCode
+		want  []string
+		msg   string
+	}{
+		{
+			input: `{"filter": {"nmae": "a8m"}}`,
+			code:  CodeUnknownField,
+			want:  []string{"name"},
+			msg:   `unrecognized key "nmae" for filtering, did you mean "name"?`,
+		},
+		{
+			input: `{"filter": {"Emial": "a8m"}}`,
+			code:  CodeUnknownField,
+			want:  []string{"email"},
+		},
+		{
+			input: `{"filter": {"created_ta": 1}}`,
+			code:  CodeUnknownField,
+			want:  nil,
+		},
+		{
+			input: `{"sort": ["-created_ta"]}`,
+			code:  CodeUnknownField,
+			want:  []string{"created_at"},
+		},
+		{
+			input: `{"select": ["mail"]}`,
+			code:  CodeUnknownField,
+			want:  []string{"email"},
+		},
+		{
+			input: `{"filter": {"name": {"$lkie": "a8m"}}}`,
+			code:  CodeInvalidOp,
+			want:  []string{"$like"},
+			msg:   `can not apply op "$lkie" on field "name", did you mean "$like"?`,
+		},
+		{
+			input: `{"filter": {"name": {"$contains": "a8m"}}}`,
+			code:  CodeInvalidOp,
+			want:  nil,
+		},
+		{
+			input: `{"filter": {"xyz": 1}}`,
+			code:  CodeUnknownField,
+			want:  nil,
+			msg:   `unrecognized key "xyz" for filtering`,
+		},
+	}
+	p := MustNewParser(Config{Model: model, Suggest: true, Log: t.Logf})
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := p.Parse([]byte(tt.input))
+			var perr *ParseError
+			if !errors.As(err, &perr) || perr.Code != tt.code {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(perr.Suggestions, tt.want) {
+				t.Fatalf("suggestions:\n\tgot: %q\n\twant %q", perr.Suggestions, tt.want)
+			}
+			if tt.msg != "" && err.Error() != tt.msg {
+				t.Fatalf("message:\n\tgot: %s\n\twant %s", err, tt.msg)
+			}
+		})
+	}
+	p = MustNewParser(Config{Model: model, Log: t.Logf})
+	_, err := p.Parse([]byte(`{"filter": {"nmae": "a8m"}}`))
+	if perr, ok := err.(*ParseError); !ok || perr.Suggestions != nil || err.Error() != `unrecognized key "nmae" for filtering` {
+		t.Fatalf("expect no suggestions without the Suggest option, got: %v", err)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"name", "", 4},
+		{"name", "nmae", 1},
+		{"abc", "ca", 3},
+		{"kitten", "sitting", 3},
+		{"día", "dia", 1},
+	} {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
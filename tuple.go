@@ -0,0 +1,59 @@
+package rql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// tupleOps are the operators that can be applied on tuples.
+var tupleOps = map[Op]bool{EQ: true, NEQ: true, LT: true, LTE: true, GT: true, GTE: true}
+
+// tuple parses a row-value comparison of several fields. It is used for keyset filters on
+// multiple columns, where a comparison of each column is incorrect. For example:
+//
+//	{"$tuple": {"fields": ["year", "month"], "$gte": [2024, 6]}}
+//	=> "(year, month) >= (?, ?)"
+//
+// Several operators are combined using AND. The fields must support the operators.
+func (p *parseState) tuple(v interface{}) *expr {
+	expect(p.conf.Dialect == DialectSQL || p.conf.Dialect == DialectMySQL, CodeInvalidOp, "", "%s is supported only by the SQL and MySQL dialects", p.op(TUPLE))
+	terms, ok := v.(map[string]interface{})
+	expectStr(ok, CodeInvalidQuery, "", "%s must be type object", p.op(TUPLE))
+	names, ok := terms["fields"].([]interface{})
+	expectStr(ok && len(names) > 0, CodeInvalidQuery, "", "fields of %s must be a non-empty array", p.op(TUPLE))
+	fields := make([]*field, len(names))
+	for i, n := range names {
+		k, ok := n.(string)
+		expectStr(ok, CodeInvalidQuery, "", "fields of %s must be strings", p.op(TUPLE))
+		f := p.lookup(k)
+		if f == nil {
+			p.unknownField(k, "unrecognized key "+strconv.Quote(k)+" for filtering", false, filterableField)
+		}
+		expectStr(f.Filterable, CodeNotFilterable, k, "field %q is not filterable", k)
+		p.deprecated(f, k)
+		fields[i] = f
+	}
+	e := p.newExpr(expr{op: AND, paren: true})
+	for opName, opVal := range terms {
+		if opName == "fields" {
+			continue
+		}
+		op := Op(strings.TrimPrefix(opName, p.conf.OpPrefix))
+		expectStr(opName == p.op(op) && tupleOps[op], CodeInvalidOp, "", "can not apply op %q on %s", opName, p.op(TUPLE))
+		values, ok := opVal.([]interface{})
+		expect(ok && len(values) == len(fields), CodeInvalidValue, "", "values of %s must be an array of %d values", opName, len(fields))
+		t := p.newExpr(expr{op: op, tuple: make([]*expr, len(fields))})
+		for i, f := range fields {
+			expectStr(f.FilterOps[opName], CodeInvalidOp, f.Name, "can not apply op %q on field %q", opName, f.Name)
+			p.allowOp(f, op)
+			mustStr(p.validate(f, op, values[i]), f.Name, "invalid datatype or format for field %q", f.Name)
+			t.tuple[i] = p.predicate(f, nil, op, values[i])
+		}
+		e.add(t)
+	}
+	expectStr(len(e.children) > 0, CodeInvalidQuery, "", "missing operator for %s", p.op(TUPLE))
+	if len(e.children) == 1 {
+		return e.children[0]
+	}
+	return e
+}
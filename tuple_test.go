@@ -0,0 +1,70 @@
+package rql
+
+import "testing"
+
+func TestTuple(t *testing.T) {
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Year  int    `rql:"filter"`
+			Month int    `rql:"filter"`
+			Name  string `rql:"filter"`
+			Admin bool   `rql:"filter"`
+			Sort  int    `rql:"sort"`
+		}),
+		Log: t.Logf,
+	})
+	tests := []struct {
+		input string
+		want  *Params
+	}{
+		{
+			input: `{"filter": {"$tuple": {"fields": ["year", "month"], "$gte": [2024, 6]}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "(year, month) >= (?, ?)",
+				FilterArgs: []interface{}{2024, 6},
+			},
+		},
+		{
+			input: `{"filter": {"name": "a8m", "$tuple": {"fields": ["year", "month"], "$gte": [2024, 6], "$lt": [2025, 1]}}}`,
+			want: &Params{
+				Limit:      25,
+				FilterExp:  "name = ? AND ((year, month) >= (?, ?) AND (year, month) < (?, ?))",
+				FilterArgs: []interface{}{"a8m", 2024, 6, 2025, 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			out, err := p.Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertParams(t, out, tt.want)
+			b, err := p.Query(out).MarshalJSON()
+			if err != nil {
+				t.Fatalf("failed to marshal query: %v", err)
+			}
+			again, err := p.Parse(b)
+			if err != nil {
+				t.Fatalf("failed to parse formatted query %s: %v", b, err)
+			}
+			assertParams(t, again, tt.want)
+		})
+	}
+	for _, input := range []string{
+		`{"filter": {"$tuple": []}}`,
+		`{"filter": {"$tuple": {"$gte": [2024, 6]}}}`,
+		`{"filter": {"$tuple": {"fields": ["year", "month"]}}}`,
+		`{"filter": {"$tuple": {"fields": ["year", "month"], "$gte": [2024]}}}`,
+		`{"filter": {"$tuple": {"fields": ["year", "month"], "$gte": [2024, "6"]}}}`,
+		`{"filter": {"$tuple": {"fields": ["year", "month"], "$in": [2024, 6]}}}`,
+		`{"filter": {"$tuple": {"fields": ["year", "admin"], "$gt": [2024, true]}}}`,
+		`{"filter": {"$tuple": {"fields": ["year", "sort"], "$gt": [2024, 1]}}}`,
+		`{"filter": {"$tuple": {"fields": ["year", "unknown"], "$gt": [2024, 1]}}}`,
+	} {
+		if _, err := p.Parse([]byte(input)); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package rql
+
+import "sort"
+
+// Usage describes the fields and the operators that are used by a query. It allows making
+// authorization decisions after the query was parsed, without walking the query again.
+// For example:
+//
+//	params, err := p.Parse(b)
+//	if err != nil {
+//		return err
+//	}
+//	if u := params.Usage(); u.Filter["email"] != nil && !isAdmin(ctx) {
+//		return errForbidden
+//	}
+type Usage struct {
+	// Filter maps the fields that are used in the filter to the operators that are applied on
+	// them. Fields of relations are named by their path (e.g. "orders_total"), and relations
+	// are mapped to their operators (e.g. "$has"). Predicates that are added by the parser, like
+	// the soft-delete and the cursor conditions, are not included. See Search for the search term.
+	Filter map[string][]Op
+	// Ops are the operators that are used in the filter, including OR for disjunctions.
+	Ops []Op
+	// Sort and Select are the fields of the sort and the select clauses, including the defaults.
+	Sort   []string
+	Select []string
+	// Search reports if the query has a search term, that is matched against the searchable fields.
+	Search bool
+}
+
+// Fields returns the names of the fields that are used by the query, sorted.
+func (u *Usage) Fields() []string {
+	seen := make(map[string]bool)
+	for name := range u.Filter {
+		seen[name] = true
+	}
+	for _, name := range append(u.Sort[:len(u.Sort):len(u.Sort)], u.Select...) {
+		seen[name] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Usage returns the fields and the operators that are used by the query. Field aliases are
+// resolved to the names of the fields. Params that were not created by the parser have no usage.
+func (pr *Params) Usage() *Usage {
+	u := &Usage{Filter: make(map[string][]Op), Search: pr.search != ""}
+	p := pr.parser
+	if p == nil {
+		return u
+	}
+	ops := make(map[Op]bool)
+	if pr.filter != nil {
+		p.filterUsage(u, ops, pr.filter, "")
+	}
+	for op := range ops {
+		u.Ops = append(u.Ops, op)
+	}
+	sort.Slice(u.Ops, func(i, j int) bool { return u.Ops[i] < u.Ops[j] })
+	for _, s := range pr.sort {
+		name, _ := p.sortToken(s)
+		if m := sortExprToken.FindStringSubmatch(s); m != nil {
+			name = "expr(" + m[1] + ")"
+		}
+		u.Sort = append(u.Sort, p.usageName(name))
+	}
+	for _, s := range pr.selects {
+		u.Select = append(u.Select, p.usageName(s))
+	}
+	return u
+}
+
+// filterUsage adds the fields and the operators of the given filter to the usage.
+// prefix is the path of the relation that the filter belongs to.
+func (p *Parser) filterUsage(u *Usage, ops map[Op]bool, e *expr, prefix string) {
+	if e.search || e.implicit {
+		return
+	}
+	name, op := "", e.op
+	switch {
+	case e.rel != nil:
+		// the operator of "$count" nodes is the comparison of the count.
+		if name = prefix + e.rel.Name; op != HAS && op != NHAS {
+			op = COUNT
+		}
+		if e.sub != nil {
+			p.filterUsage(u, ops, e.sub, name+p.conf.FieldSep)
+		}
+	case e.field != nil && e.join != nil:
+		name = prefix + e.join.Name + p.conf.FieldSep + e.field.Name
+	case e.field != nil:
+		name = prefix + e.field.Name
+	case e.tuple != nil:
+		for _, c := range e.tuple {
+			p.filterUsage(u, ops, c, prefix)
+		}
+		return
+	default:
+		if e.op == OR && len(e.children) > 1 {
+			ops[OR] = true
+		}
+		for _, c := range e.children {
+			p.filterUsage(u, ops, c, prefix)
+		}
+		return
+	}
+	// the operator of date-part nodes is the part, and not the comparison of the part.
+	if e.field != nil && e.field.partOf != nil {
+		op = e.field.part
+	}
+	ops[op] = true
+	for _, o := range u.Filter[name] {
+		if o == op {
+			return
+		}
+	}
+	u.Filter[name] = append(u.Filter[name], op)
+}
+
+// usageName returns the name of the field that is registered under the given name or alias.
+// Other names, like computed fields or relation paths, are returned as is.
+func (p *Parser) usageName(name string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if f := p.get(name); f != nil {
+		return f.Name
+	}
+	return name
+}
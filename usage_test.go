@@ -0,0 +1,75 @@
+package rql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUsage(t *testing.T) {
+	type Order struct {
+		Total int `rql:"filter"`
+	}
+	p := MustNewParser(Config{
+		Model: new(struct {
+			ID    int    `rql:"filter,sort"`
+			Name  string `rql:"filter,sort,search"`
+			Email string `rql:"filter,alias=mail"`
+		}),
+		Relations: map[string]Relation{
+			"orders": {Model: new(Order), Table: "orders", On: "orders.user_id = users.id"},
+		},
+		DefaultSort: []string{"id"},
+		CursorKey:   []byte("secret"),
+		Log:         t.Logf,
+	})
+	out, err := p.Parse([]byte(`{
+		"filter": {
+			"mail": {"$like": "%@example.com"},
+			"$or": [{"id": 1}, {"id": {"$in": [2, 3]}}],
+			"orders": {"$has": true, "$where": {"total": {"$gt": 10}}}
+		},
+		"select": ["mail", "name"],
+		"search": "a8m"
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u := out.Usage()
+	want := &Usage{
+		Filter: map[string][]Op{
+			"email":        {LIKE},
+			"id":           {EQ, IN},
+			"orders":       {HAS},
+			"orders_total": {GT},
+		},
+		Ops:    []Op{EQ, GT, HAS, IN, LIKE, OR},
+		Sort:   []string{"id"},
+		Select: []string{"email", "name"},
+		Search: true,
+	}
+	if !reflect.DeepEqual(u, want) {
+		t.Fatalf("usage:\n\tgot: %+v\n\twant %+v", u, want)
+	}
+	if got := u.Fields(); !reflect.DeepEqual(got, []string{"email", "id", "name", "orders", "orders_total"}) {
+		t.Fatalf("unexpected fields: %v", got)
+	}
+	token, err := p.EncodeCursor(out, 1)
+	if err != nil {
+		t.Fatalf("failed to encode cursor: %v", err)
+	}
+	out, err = p.Parse([]byte(`{"filter": {"orders": {"$count": {"$gt": 1}}}, "after": "` + token + `"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = &Usage{
+		Filter: map[string][]Op{"orders": {COUNT}},
+		Ops:    []Op{COUNT},
+		Sort:   []string{"id"},
+	}
+	if u := out.Usage(); !reflect.DeepEqual(u, want) {
+		t.Fatalf("usage:\n\tgot: %+v\n\twant %+v", u, want)
+	}
+	if u := (&Params{}).Usage(); len(u.Filter) != 0 || u.Sort != nil {
+		t.Fatalf("expect empty usage for params that were not parsed: %+v", u)
+	}
+}
@@ -0,0 +1,56 @@
+package rql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Preparer is the interface implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Preparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// Verify prepares, but does not execute, a SELECT statement built from the given table and
+// the params, and returns the error returned by the database, if any. It is useful for catching
+// mismatches between the model tags and the actual schema at startup or in CI. For example:
+//
+//	params, err := parser.Parse([]byte(`{"filter": {"name": "a8m"}, "sort": ["-age"]}`))
+//	if err != nil {
+//		return err
+//	}
+//	if err := params.Verify(ctx, db, "users"); err != nil {
+//		log.Fatalf("schema mismatch: %v", err)
+//	}
+//
+// Note that some drivers (e.g. MySQL with client-side interpolation) do not send the statement
+// to the database on prepare, and therefore cannot detect such mismatches.
+func (p *Params) Verify(ctx context.Context, db Preparer, table string) error {
+	stmt, err := db.PrepareContext(ctx, p.verifyStmt(table))
+	if err != nil {
+		return fmt.Errorf("rql: verify table %q: %w", table, err)
+	}
+	return stmt.Close()
+}
+
+// verifyStmt returns the statement that is prepared by Verify.
+func (p *Params) verifyStmt(table string) string {
+	var b strings.Builder
+	b.WriteString("SELECT 1 FROM ")
+	b.WriteString(table)
+	b.WriteString(p.TableHint)
+	for _, j := range p.Joins {
+		b.WriteByte(' ')
+		b.WriteString(j.String())
+	}
+	if p.FilterExp != "" {
+		b.WriteString(" WHERE ")
+		b.WriteString(p.FilterExp)
+	}
+	if p.Sort != "" {
+		b.WriteString(" ORDER BY ")
+		b.WriteString(p.Sort)
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package rql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// verifyDriver is a fake driver that fails to prepare statements that reference unknown columns.
+type verifyDriver struct {
+	columns []string
+	queries []string
+}
+
+func (d *verifyDriver) Open(string) (driver.Conn, error)             { return &verifyConn{d}, nil }
+func (d *verifyDriver) Connect(context.Context) (driver.Conn, error) { return &verifyConn{d}, nil }
+func (d *verifyDriver) Driver() driver.Driver                        { return d }
+
+func (d *verifyDriver) known(column string) bool {
+	for _, c := range d.columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+type verifyConn struct{ d *verifyDriver }
+
+func (c *verifyConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.queries = append(c.d.queries, query)
+	for _, w := range strings.Fields(query) {
+		if strings.HasPrefix(w, "col_") && !c.d.known(w) {
+			return nil, errors.New("no such column: " + w)
+		}
+	}
+	return &verifyStmt{}, nil
+}
+func (c *verifyConn) Close() error              { return nil }
+func (c *verifyConn) Begin() (driver.Tx, error) { return nil, errors.New("not implemented") }
+
+type verifyStmt struct{}
+
+func (s *verifyStmt) Close() error  { return nil }
+func (s *verifyStmt) NumInput() int { return -1 }
+func (s *verifyStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("unexpected exec")
+}
+func (s *verifyStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("unexpected query")
+}
+
+func TestVerify(t *testing.T) {
+	d := &verifyDriver{columns: []string{"col_Name"}}
+	db := sql.OpenDB(d)
+	defer db.Close()
+	p := MustNewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter,sort"`
+			Age  int    `rql:"filter,sort"`
+		}),
+		ColumnFn: func(s string) string { return "col_" + s },
+		Log:      t.Logf,
+	})
+	params, err := p.Parse([]byte(`{"filter": {"col_Name": "a8m"}, "sort": ["-col_Name"]}`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if err := params.Verify(context.Background(), db, "users"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "SELECT 1 FROM users WHERE col_Name = ? ORDER BY col_Name desc"; d.queries[0] != want {
+		t.Fatalf("statement:\n\tgot: %q\n\twant %q", d.queries[0], want)
+	}
+	for _, input := range []string{
+		`{"filter": {"col_Age": 1}}`,
+		`{"sort": ["col_Age"]}`,
+	} {
+		params, err := p.Parse([]byte(input))
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		if err := params.Verify(context.Background(), db, "users"); err == nil {
+			t.Fatalf("expect error for input: %s", input)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package rql
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// For returns a parser that accepts only the fields that belong to the given API version.
+// Fields are assigned to versions using the "since" and "until" options (until is exclusive).
+// For example:
+//
+//	type User struct {
+//		Name     string `rql:"filter,until=v2"`
+//		FullName string `rql:"filter,since=v2"`
+//	}
+//
+//	v1, v2 := p.For("v1"), p.For("v2") // v1 accepts "name", and v2 accepts "full_name".
+//
+// Fields without these options belong to all versions. The parser that was returned from
+// NewParser accepts all fields. The returned parsers are cached, and share the configuration
+// of p.
+func (p *Parser) For(version string) *Parser {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if v, ok := p.versions.Load(version); ok {
+		return v.(*Parser)
+	}
+	vp := &Parser{
+		Config:      p.conf.clone(),
+		conf:        p.conf,
+		fields:      make(map[string]*field, len(p.fields)),
+		relations:   p.relations,
+		versions:    &sync.Map{},
+		presets:     p.presets,
+		windows:     p.windows,
+		extraOps:    p.extraOps,
+		opAliases:   p.opAliases,
+		disabledOps: p.disabledOps,
+		keys:        p.keys,
+		folded:      p.folded,
+		softDelete:  p.softDelete,
+		ops:         p.ops,
+		skipped:     p.skipped,
+	}
+	for name, f := range p.fields {
+		if f.Since != "" && compareVersion(version, f.Since) < 0 || f.Until != "" && compareVersion(version, f.Until) >= 0 {
+			continue
+		}
+		vp.fields[name] = f
+	}
+	vp.initSchema()
+	v, _ := p.versions.LoadOrStore(version, vp)
+	return v.(*Parser)
+}
+
+// compareVersion compares two versions, like "v1" and "v1.2". The numeric parts are compared
+// by their value, and other parts are compared lexicographically. It returns -1, 0 or 1.
+func compareVersion(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		if x == y {
+			continue
+		}
+		n, err1 := strconv.Atoi(x)
+		m, err2 := strconv.Atoi(y)
+		switch {
+		case (x == "" || err1 == nil) && (y == "" || err2 == nil):
+			// a missing part is equal to zero. For example, "v1" == "v1.0".
+			if n < m {
+				return -1
+			}
+			if n > m {
+				return 1
+			}
+		case x < y:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package rql
+
+import "testing"
+
+func TestParserFor(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Age      string `rql:"filter"`
+			Name     string `rql:"filter,until=v2"`
+			FullName string `rql:"filter,since=v2"`
+			Nickname string `rql:"filter,since=v1.5,until=v3"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		version string
+		field   string
+		wantErr bool
+	}{
+		{"v1", "age", false},
+		{"v1", "name", false},
+		{"v1", "full_name", true},
+		{"v1", "nickname", true},
+		{"v1.5", "nickname", false},
+		{"v2", "name", true},
+		{"v2", "full_name", false},
+		{"v2.0", "full_name", false},
+		{"v2.10", "nickname", false},
+		{"v3", "nickname", true},
+		{"v3", "age", false},
+	}
+	for _, tt := range tests {
+		_, err := p.For(tt.version).Parse([]byte(`{"filter": {"` + tt.field + `": "1"}}`))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s/%s: want error: %v, got: %v", tt.version, tt.field, tt.wantErr, err)
+		}
+	}
+	if p.For("v1") != p.For("v1") {
+		t.Error("expect versioned parsers to be cached")
+	}
+	if _, err := p.Parse([]byte(`{"filter": {"name": "a", "full_name": "b"}}`)); err != nil {
+		t.Errorf("expect the base parser to accept all fields: %v", err)
+	}
+	_, err = NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter,since=v2,until=v1"`
+		}),
+	})
+	if err == nil {
+		t.Error("expect error for invalid version range")
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1", "v1", 0},
+		{"v1", "v1.0", 0},
+		{"v1", "v2", -1},
+		{"v10", "v9", 1},
+		{"v1.2", "v1.10", -1},
+		{"2", "v2", 0},
+		{"v1-beta", "v1-alpha", 1},
+	}
+	for _, tt := range tests {
+		if got := compareVersion(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersion(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
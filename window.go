@@ -0,0 +1,73 @@
+package rql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TotalCountColumn is the column of the total count of rows. See ParseOptions.TotalCount.
+const TotalCountColumn = "total_count"
+
+// initWindows validates and renders the window expressions of the parser.
+func (p *Parser) initWindows() error {
+	if len(p.conf.Windows) == 0 {
+		return nil
+	}
+	p.windows = make(map[string]string, len(p.conf.Windows))
+	for name, w := range p.conf.Windows {
+		if _, ok := p.fields[name]; ok {
+			return fmt.Errorf("rql: window %q conflicts with a field with the same name", name)
+		}
+		if _, ok := p.conf.Computed[name]; ok {
+			return fmt.Errorf("rql: window %q conflicts with a computed field with the same name", name)
+		}
+		if w.Func == "" {
+			return fmt.Errorf("rql: window %q: missing function", name)
+		}
+		var over []string
+		if len(w.PartitionBy) > 0 {
+			cols := make([]string, len(w.PartitionBy))
+			for i, fn := range w.PartitionBy {
+				f, ok := p.fields[fn]
+				if !ok {
+					return fmt.Errorf("rql: window %q: unrecognized partition field %q", name, fn)
+				}
+				cols[i] = p.tableColumn(f)
+			}
+			over = append(over, "PARTITION BY "+strings.Join(cols, ", "))
+		}
+		if len(w.OrderBy) > 0 {
+			cols := make([]string, len(w.OrderBy))
+			for i, fn := range w.OrderBy {
+				if fn == "" {
+					return fmt.Errorf("rql: window %q: empty order field", name)
+				}
+				var dir string
+				if name, d := p.sortToken(fn); d != "" && name != "" {
+					dir, fn = " "+d, name
+				}
+				f, ok := p.fields[fn]
+				if !ok {
+					return fmt.Errorf("rql: window %q: unrecognized order field %q", name, fn)
+				}
+				cols[i] = p.tableColumn(f) + dir
+			}
+			over = append(over, "ORDER BY "+strings.Join(cols, ", "))
+		}
+		p.windows[name] = w.Func + " OVER (" + strings.Join(over, " ") + ") AS " + p.colName(name)
+	}
+	return nil
+}
+
+// total appends the total count of rows to the given select expression. All
+// columns (of the table alias, if there is one) are selected if it is empty.
+func (p *parseState) total(sel string) string {
+	expect(p.conf.Dialect != DialectCQL, CodeInvalidQuery, "", "total count is not supported by the CQL dialect")
+	if sel == "" {
+		sel = "*"
+		if p.alias != "" {
+			sel = p.alias + ".*"
+		}
+	}
+	return sel + ", COUNT(*) OVER() AS " + TotalCountColumn
+}
@@ -0,0 +1,87 @@
+package rql
+
+import "testing"
+
+func TestWindows(t *testing.T) {
+	model := new(struct {
+		Name  string `rql:"filter"`
+		City  string `rql:"filter"`
+		Score int    `rql:"filter,sort"`
+	})
+	p, err := NewParser(Config{
+		Model: model,
+		Windows: map[string]Window{
+			"rank_in_city": {Func: "ROW_NUMBER()", PartitionBy: []string{"city"}, OrderBy: []string{"-score", "name"}},
+			"total":        {Func: "SUM(score)"},
+		},
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	out, err := p.Parse([]byte(`{"select": ["name", "rank_in_city", "total"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "name, ROW_NUMBER() OVER (PARTITION BY city ORDER BY score desc, name) AS rank_in_city, SUM(score) OVER () AS total"
+	if out.Select != want {
+		t.Fatalf("select:\n\tgot: %q\n\twant: %q", out.Select, want)
+	}
+	if _, err := p.Parse([]byte(`{"filter": {"rank_in_city": 1}}`)); err == nil {
+		t.Fatal("expect windows to be rejected in filter")
+	}
+	if _, err := p.ParseWithOptions([]byte(`{"select": ["total"]}`), ParseOptions{AllowedFields: []string{"name"}}); err == nil {
+		t.Fatal("expect windows to respect the allowed fields")
+	}
+	for _, w := range []map[string]Window{
+		{"name": {Func: "RANK()"}},
+		{"w": {PartitionBy: []string{"city"}}},
+		{"w": {Func: "RANK()", PartitionBy: []string{"email"}}},
+		{"w": {Func: "RANK()", OrderBy: []string{"-email"}}},
+	} {
+		if _, err := NewParser(Config{Model: model, Windows: w}); err == nil {
+			t.Errorf("expect error for windows: %v", w)
+		}
+	}
+}
+
+func TestTotalCount(t *testing.T) {
+	p, err := NewParser(Config{
+		Model: new(struct {
+			Name string `rql:"filter"`
+			Age  int    `rql:"filter,sort"`
+		}),
+		Log: t.Logf,
+	})
+	if err != nil {
+		t.Fatalf("failed to build parser: %v", err)
+	}
+	tests := []struct {
+		input string
+		opts  ParseOptions
+		want  string
+	}{
+		{`{"select": ["name", "age"]}`, ParseOptions{TotalCount: true}, "name, age, COUNT(*) OVER() AS total_count"},
+		{`{"filter": {"age": {"$gt": 18}}, "limit": 10}`, ParseOptions{TotalCount: true}, "*, COUNT(*) OVER() AS total_count"},
+		{`{}`, ParseOptions{TotalCount: true, Alias: "u"}, "u.*, COUNT(*) OVER() AS total_count"},
+		{`{"select": ["name"]}`, ParseOptions{}, "name"},
+	}
+	for _, tt := range tests {
+		out, err := p.ParseWithOptions([]byte(tt.input), tt.opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.Select != tt.want {
+			t.Errorf("select:\n\tgot: %q\n\twant: %q", out.Select, tt.want)
+		}
+	}
+	cql := MustNewParser(Config{
+		Model: new(struct {
+			ID int `rql:"filter,partition"`
+		}),
+		Dialect: DialectCQL,
+	})
+	if _, err := cql.ParseWithOptions([]byte(`{}`), ParseOptions{TotalCount: true}); err == nil {
+		t.Fatal("expect total count to be rejected by the CQL dialect")
+	}
+}